@@ -8,6 +8,39 @@ type WizInsights struct {
 	WizClientId string `mapstructure:"wiz-client-id"`
 	WizClientSecret string `mapstructure:"wiz-client-secret"`
 	WizAuthEndpoint string `mapstructure:"wiz-auth-endpoint"`
+	WizRequestsPerSecond int `mapstructure:"wiz-requests-per-second"`
+	SyncCredentials bool `mapstructure:"sync-credentials"`
+	SyncExcessiveAccess bool `mapstructure:"sync-excessive-access"`
+	SyncIdentityRiskSummary bool `mapstructure:"sync-identity-risk-summary"`
+	SyncControls bool `mapstructure:"sync-controls"`
+	SyncEffectiveAccess bool `mapstructure:"sync-effective-access"`
+	EffectiveAccessResourceTypes []string `mapstructure:"effective-access-resource-types"`
+	SyncSecretFindings bool `mapstructure:"sync-secret-findings"`
+	SyncThreatDetections bool `mapstructure:"sync-threat-detections"`
+	SyncPrincipalActivity bool `mapstructure:"sync-principal-activity"`
+	PrincipalActivityBucketMinutes int `mapstructure:"principal-activity-bucket-minutes"`
+	SyncAuditLog bool `mapstructure:"sync-audit-log"`
+	AuditLogLookbackDays int `mapstructure:"audit-log-lookback-days"`
+	EventLookbackDays int `mapstructure:"event-lookback-days"`
+	EventBackfill bool `mapstructure:"event-backfill"`
+	EventBackfillSliceHours int `mapstructure:"event-backfill-slice-hours"`
+	EventCursorRecoveryHours int `mapstructure:"event-cursor-recovery-hours"`
+	InsightReconcileMinutes int `mapstructure:"insight-reconcile-minutes"`
+	RecordValidation string `mapstructure:"record-validation"`
+	IssueActiveOnly bool `mapstructure:"issue-active-only"`
+	IssueFrameworks []string `mapstructure:"issue-frameworks"`
+	IssueIncludeTags []string `mapstructure:"issue-include-tags"`
+	IssueExcludeTags []string `mapstructure:"issue-exclude-tags"`
+	InsightOwnerTag string `mapstructure:"insight-owner-tag"`
+	IssueSyncShardBy string `mapstructure:"issue-sync-shard-by"`
+	IssueSyncConcurrency int `mapstructure:"issue-sync-concurrency"`
+	IssueSyncReport bool `mapstructure:"issue-sync-report"`
+	IssueSyncReportId string `mapstructure:"issue-sync-report-id"`
+	IssueSyncReportTimeoutMinutes int `mapstructure:"issue-sync-report-timeout-minutes"`
+	WebhookListenAddress string `mapstructure:"webhook-listen-address"`
+	WebhookSecret string `mapstructure:"webhook-secret"`
+	WebhookBufferDir string `mapstructure:"webhook-buffer-dir"`
+	WebhookReconcileMinutes int `mapstructure:"webhook-reconcile-minutes"`
 }
 
 func (c *WizInsights) findFieldByTag(tagValue string) (any, bool) {
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"fmt"
+
 	"github.com/conductorone/baton-sdk/pkg/field"
 )
 
@@ -34,10 +36,244 @@ var (
 		field.WithPlaceholder("https://auth.app.wiz.io/oauth/token"),
 	)
 
-	ConfigurationFields = []field.SchemaField{wizAPIURL, wizClientID, wizClientSecret, wizAuthEndpoint}
+	wizRequestsPerSecond = field.IntField(
+		"wiz-requests-per-second",
+		field.WithDisplayName("Wiz API rate limit (requests per second)"),
+		field.WithDescription("Maximum number of Wiz API requests per second, shared by every sync including parallel issue shards; 0 disables the limit"),
+		field.WithDefaultValue(0),
+	)
+
+	// Optional sync configuration fields.
+	syncCredentials = field.BoolField(
+		"sync-credentials",
+		field.WithDisplayName("Sync credentials"),
+		field.WithDescription("Sync Wiz access keys as credentials linked to their owning identity (requires the read:resources scope)"),
+		field.WithDefaultValue(false),
+	)
+	syncExcessiveAccess = field.BoolField(
+		"sync-excessive-access",
+		field.WithDisplayName("Sync excessive access findings"),
+		field.WithDescription("Sync Wiz excessive access (CIEM) findings as least-privilege insights on the affected identity"),
+		field.WithDefaultValue(false),
+	)
+	syncIdentityRiskSummary = field.BoolField(
+		"sync-identity-risk-summary",
+		field.WithDisplayName("Sync identity risk summaries"),
+		field.WithDescription("Sync one security insight per identity that aggregates its open issues into a normalized risk score (requires the read:resources scope)"),
+		field.WithDefaultValue(false),
+	)
+	syncControls = field.BoolField(
+		"sync-controls",
+		field.WithDisplayName("Sync controls"),
+		field.WithDescription("Sync Wiz controls and cloud configuration rules, the rules that raise issues, as resources (requires the read:controls and read:cloud_configuration scopes)"),
+		field.WithDefaultValue(false),
+	)
+	syncEffectiveAccess = field.BoolField(
+		"sync-effective-access",
+		field.WithDisplayName("Sync effective access"),
+		field.WithDescription("Sync identities' effective access to sensitive cloud resources as grants (requires the read:resources scope)"),
+		field.WithDefaultValue(false),
+	)
+	effectiveAccessResourceTypes = field.StringSliceField(
+		"effective-access-resource-types",
+		field.WithDisplayName("Effective access resource types"),
+		field.WithDescription("Wiz entity types of the cloud resources to sync effective access for"),
+		field.WithDefaultValue([]string{"BUCKET", "DATABASE", "DB_SERVER", "ENCRYPTION_KEY", "SECRET_CONTAINER", "ACCESS_ROLE"}),
+	)
+	syncSecretFindings = field.BoolField(
+		"sync-secret-findings",
+		field.WithDisplayName("Sync secret findings"),
+		field.WithDescription("Sync Wiz secret findings (exposed cloud keys, tokens and passwords) as security insights (requires the read:security_scans scope)"),
+		field.WithDefaultValue(false),
+	)
+	syncThreatDetections = field.BoolField(
+		"sync-threat-detections",
+		field.WithDisplayName("Sync threat detections"),
+		field.WithDescription("Enable an event feed of Wiz Defend threat detections whose actor is an identity (requires the read:detections and read:resources scopes)"),
+		field.WithDefaultValue(false),
+	)
+	syncPrincipalActivity = field.BoolField(
+		"sync-principal-activity",
+		field.WithDisplayName("Sync principal activity"),
+		field.WithDescription("Enable an event feed of identities' cloud activity as usage events (requires the read:cloud_events_cloud and read:resources scopes)"),
+		field.WithDefaultValue(false),
+	)
+	principalActivityBucketMinutes = field.IntField(
+		"principal-activity-bucket-minutes",
+		field.WithDisplayName("Principal activity bucket (minutes)"),
+		field.WithDescription("Size of the time bucket, in minutes, that an identity's cloud activity is aggregated into"),
+		field.WithDefaultValue(60),
+	)
+	syncAuditLog = field.BoolField(
+		"sync-audit-log",
+		field.WithDisplayName("Sync Wiz audit log"),
+		field.WithDescription("Enable an event feed of actions in the Wiz console and API, such as logins, role changes and issue status changes, and sync the Wiz users and service accounts that act in it (requires the admin:audit, read:users and read:service_accounts scopes)"),
+		field.WithDefaultValue(false),
+	)
+	auditLogLookbackDays = field.IntField(
+		"audit-log-lookback-days",
+		field.WithDisplayName("Audit log initial lookback (days)"),
+		field.WithDescription("How far back, in days, the audit log event feed starts when it has no stored progress; 0 starts from now"),
+		field.WithDefaultValue(7),
+	)
+	eventLookbackDays = field.IntField(
+		"event-lookback-days",
+		field.WithDisplayName("Event feed initial lookback (days)"),
+		field.WithDescription("How far back, in days, an event feed starts when it has no stored progress; 0 starts from now"),
+		field.WithDefaultValue(30),
+	)
+	eventBackfill = field.BoolField(
+		"event-backfill",
+		field.WithDisplayName("Backfill issue events"),
+		field.WithDescription("Walk the initial lookback of the issues event feed in bounded time slices, reporting progress, instead of a single query"),
+		field.WithDefaultValue(false),
+	)
+	eventBackfillSliceHours = field.IntField(
+		"event-backfill-slice-hours",
+		field.WithDisplayName("Backfill slice size (hours)"),
+		field.WithDescription("Size, in hours, of each time slice walked by an issue event backfill"),
+		field.WithDefaultValue(24),
+	)
+	eventCursorRecoveryHours = field.IntField(
+		"event-cursor-recovery-hours",
+		field.WithDisplayName("Event cursor recovery window (hours)"),
+		field.WithDescription("How far back, in hours, an event feed restarts when its stored cursor cannot be read"),
+		field.WithDefaultValue(24),
+	)
+	recordValidation = field.StringField(
+		"record-validation",
+		field.WithDisplayName("Record validation"),
+		field.WithDescription("How to handle Wiz issues missing fields a security insight needs: lenient skips them with a warning, strict fails the sync"),
+		field.WithDefaultValue("lenient"),
+	)
+	issueActiveOnly = field.BoolField(
+		"issue-active-only",
+		field.WithDisplayName("Only sync active issues"),
+		field.WithDescription("Only sync open and in-progress issues, reporting resolved and rejected issues as removed; by default issues in every status are synced"),
+		field.WithDefaultValue(false),
+	)
+	issueFrameworks = field.StringSliceField(
+		"issue-frameworks",
+		field.WithDisplayName("Issue frameworks"),
+		field.WithDescription("Only sync issues whose rule maps to one of these security frameworks (requires the read:security_frameworks scope). Each value matches every framework whose name starts with it, ignoring case, so NIST selects every NIST framework and CIS every CIS benchmark; leave empty to sync every issue"),
+	)
+	issueIncludeTags = field.StringSliceField(
+		"issue-include-tags",
+		field.WithDisplayName("Include tags"),
+		field.WithDescription("Only sync issues whose entity has one of these cloud tags, as key=value or a key alone to match any value (for example env=prod); leave empty to sync every issue"),
+	)
+	issueExcludeTags = field.StringSliceField(
+		"issue-exclude-tags",
+		field.WithDisplayName("Exclude tags"),
+		field.WithDescription("Skip issues whose entity has any of these cloud tags, as key=value or a key alone to match any value"),
+	)
+	insightOwnerTag = field.StringField(
+		"insight-owner-tag",
+		field.WithDisplayName("Owner tag"),
+		field.WithDescription("Cloud tag whose value is the email of the user an issue insight is targeted at instead of the entity, for example owner; leave empty to always target the entity"),
+	)
+	issueSyncShardBy = field.StringField(
+		"issue-sync-shard-by",
+		field.WithDisplayName("Issue sync shards"),
+		field.WithDescription("Split the full issue sync into shards that are listed in parallel: severity, or project (requires the read:projects scope); leave empty to list issues with a single cursor"),
+	)
+	issueSyncConcurrency = field.IntField(
+		"issue-sync-concurrency",
+		field.WithDisplayName("Issue sync concurrency"),
+		field.WithDescription("Maximum number of issue sync shards listed at the same time"),
+		field.WithDefaultValue(4),
+	)
+	issueSyncReport = field.BoolField(
+		"issue-sync-report",
+		field.WithDisplayName("Sync issues from a report"),
+		field.WithDescription("Export issues for a full sync with a Wiz issues report instead of paging the GraphQL API, falling back to the API if the report fails (requires the create:reports and read:reports scopes)"),
+		field.WithDefaultValue(false),
+	)
+	issueSyncReportID = field.StringField(
+		"issue-sync-report-id",
+		field.WithDisplayName("Issues report ID"),
+		field.WithDescription("ID of an existing Wiz issues report to rerun for each full sync; leave empty to create a report on the first sync and rerun it, found by name, in later syncs"),
+	)
+	issueSyncReportTimeoutMinutes = field.IntField(
+		"issue-sync-report-timeout-minutes",
+		field.WithDisplayName("Issues report timeout (minutes)"),
+		field.WithDescription("How long, in minutes, to wait for an issues report before falling back to the GraphQL API"),
+		field.WithDefaultValue(30),
+	)
+	webhookListenAddress = field.StringField(
+		"webhook-listen-address",
+		field.WithDisplayName("Webhook listen address"),
+		field.WithDescription("Address of an embedded HTTP listener for Wiz automation rule webhooks, for example :8080; leave empty to only poll for issue changes"),
+	)
+	webhookSecret = field.StringField(
+		"webhook-secret",
+		field.WithIsSecret(true),
+		field.WithDisplayName("Webhook secret"),
+		field.WithDescription("Shared secret that Wiz webhooks must present as a bearer token or in the X-Wiz-Webhook-Secret header"),
+	)
+	webhookBufferDir = field.StringField(
+		"webhook-buffer-dir",
+		field.WithDisplayName("Webhook buffer directory"),
+		field.WithDescription("Directory where received webhook notifications are buffered until the event feed emits them"),
+		field.WithDefaultValue("wiz-webhook-buffer"),
+	)
+	insightReconcileMinutes = field.IntField(
+		"insight-reconcile-minutes",
+		field.WithDisplayName("Insight reconcile interval (minutes)"),
+		field.WithDescription("How often, in minutes, the issues event feed checks the issues it reported against Wiz and removes insights for issues that no longer exist or no longer match the configured filters; 0 disables it"),
+		field.WithDefaultValue(60),
+	)
+	webhookReconcileMinutes = field.IntField(
+		"webhook-reconcile-minutes",
+		field.WithDisplayName("Webhook reconcile interval (minutes)"),
+		field.WithDescription("How often, in minutes, the issues event feed polls Wiz to reconcile webhook notifications that never arrived"),
+		field.WithDefaultValue(60),
+	)
+
+	ConfigurationFields = []field.SchemaField{
+		wizAPIURL,
+		wizClientID,
+		wizClientSecret,
+		wizAuthEndpoint,
+		wizRequestsPerSecond,
+		syncCredentials,
+		syncExcessiveAccess,
+		syncIdentityRiskSummary,
+		syncControls,
+		syncEffectiveAccess,
+		effectiveAccessResourceTypes,
+		syncSecretFindings,
+		syncThreatDetections,
+		syncPrincipalActivity,
+		principalActivityBucketMinutes,
+		syncAuditLog,
+		auditLogLookbackDays,
+		eventLookbackDays,
+		eventBackfill,
+		eventBackfillSliceHours,
+		eventCursorRecoveryHours,
+		insightReconcileMinutes,
+		recordValidation,
+		issueActiveOnly,
+		issueFrameworks,
+		issueIncludeTags,
+		issueExcludeTags,
+		insightOwnerTag,
+		issueSyncShardBy,
+		issueSyncConcurrency,
+		issueSyncReport,
+		issueSyncReportID,
+		issueSyncReportTimeoutMinutes,
+		webhookListenAddress,
+		webhookSecret,
+		webhookBufferDir,
+		webhookReconcileMinutes,
+	}
 
 	// FieldRelationships defines relationships between the ConfigurationFields that can be automatically validated.
-	FieldRelationships = []field.SchemaFieldRelationship{}
+	FieldRelationships = []field.SchemaFieldRelationship{
+		field.FieldsRequiredTogether(webhookListenAddress, webhookSecret),
+	}
 )
 
 //go:generate go run -tags=generate ./gen
@@ -48,3 +284,24 @@ var Config = field.NewConfiguration(
 	field.WithIconUrl("/static/app-icons/wiz.svg"),
 	field.WithHelpUrl("/docs/baton/wiz"),
 )
+
+// ValidateConfig checks the values of the fields that the schema cannot
+// constrain: sizes, limits and timeouts that must be positive. Their defaults
+// are, but a value of 0 would stall the feed or the sync that uses it.
+func ValidateConfig(c *WizInsights) error {
+	positive := []struct {
+		field field.SchemaField
+		value int
+	}{
+		{principalActivityBucketMinutes, c.PrincipalActivityBucketMinutes},
+		{eventBackfillSliceHours, c.EventBackfillSliceHours},
+		{issueSyncConcurrency, c.IssueSyncConcurrency},
+		{issueSyncReportTimeoutMinutes, c.IssueSyncReportTimeoutMinutes},
+	}
+	for _, p := range positive {
+		if p.value <= 0 {
+			return fmt.Errorf("invalid %s %d: must be greater than 0", p.field.FieldName, p.value)
+		}
+	}
+	return nil
+}
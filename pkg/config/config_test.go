@@ -49,3 +49,39 @@ func TestValidateConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateConfigRejectsNonPositiveSizes(t *testing.T) {
+	valid := func() *WizInsights {
+		return &WizInsights{
+			PrincipalActivityBucketMinutes: 60,
+			EventBackfillSliceHours:        24,
+			IssueSyncConcurrency:           4,
+			IssueSyncReportTimeoutMinutes:  30,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		mutate  func(c *WizInsights)
+		wantErr string
+	}{
+		{name: "defaults", mutate: func(*WizInsights) {}},
+		{name: "zero activity bucket", mutate: func(c *WizInsights) { c.PrincipalActivityBucketMinutes = 0 }, wantErr: "principal-activity-bucket-minutes"},
+		{name: "negative backfill slice", mutate: func(c *WizInsights) { c.EventBackfillSliceHours = -1 }, wantErr: "event-backfill-slice-hours"},
+		{name: "zero concurrency", mutate: func(c *WizInsights) { c.IssueSyncConcurrency = 0 }, wantErr: "issue-sync-concurrency"},
+		{name: "zero report timeout", mutate: func(c *WizInsights) { c.IssueSyncReportTimeoutMinutes = 0 }, wantErr: "issue-sync-report-timeout-minutes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid()
+			tt.mutate(c)
+			err := ValidateConfig(c)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const auditLogEventFeedID = "wiz_audit_log_feed"
+
+// auditIssueActions are audited actions that change the status of issues, for
+// example resolving or rejecting them.
+var auditIssueActions = []string{"UpdateIssue", "UpdateIssues"}
+
+// auditWizUserActions are audited actions that create, change (for example
+// their role) or delete Wiz users and service accounts.
+var auditWizUserActions = []string{
+	"CreateUser",
+	"UpdateUser",
+	"DeleteUser",
+	"CreateServiceAccount",
+	"UpdateServiceAccount",
+	"DeleteServiceAccount",
+}
+
+// auditParameterFields are the action parameters copied into event details.
+// Mutation arguments can carry secrets or free text, such as notes and
+// service account credentials, so only the fields that identify the changed
+// objects and the change itself are kept.
+var auditParameterFields = []string{"id", "ids", "status", "resolutionReason", "role", "type"}
+
+// auditParameterContainers are the action parameters that nest the mutation's
+// arguments, searched for auditParameterFields.
+var auditParameterContainers = []string{"input", "where", "patch"}
+
+// auditLogEventFeed implements connectorbuilder.EventFeed by polling the Wiz
+// audit log. Every audited action is reported as a USAGE event with the acting
+// Wiz user or service account, synced by wizUserBuilder, as the actor.
+// Successful actions that change issues the connector syncs are also reported
+// as RESOURCE_CHANGE events. Changes to Wiz users are only reported as usage.
+type auditLogEventFeed struct {
+	connector *Connector
+}
+
+func newAuditLogEventFeed(connector *Connector) *auditLogEventFeed {
+	return &auditLogEventFeed{connector: connector}
+}
+
+func (e *auditLogEventFeed) EventFeedMetadata(_ context.Context) *v2.EventFeedMetadata {
+	return v2.EventFeedMetadata_builder{
+		Id: auditLogEventFeedID,
+		SupportedEventTypes: []v2.EventType{
+			v2.EventType_EVENT_TYPE_USAGE,
+			v2.EventType_EVENT_TYPE_RESOURCE_CHANGE,
+		},
+	}.Build()
+}
+
+func (e *auditLogEventFeed) ListEvents(
+	ctx context.Context,
+	earliestEvent *timestamppb.Timestamp,
+	pToken *pagination.StreamToken,
+) ([]*v2.Event, *pagination.StreamState, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	// The audit log has its own lookback, since it is usually retained for
+	// less time than issues.
+	opts := e.connector.cursorOptions
+	opts.InitialLookback = e.connector.auditLogLookback
+	cursor, annos := decodeStreamCursor[feedCursor](ctx, pToken, earliestEvent, opts)
+
+	l.Debug("wiz-audit-log-feed: querying audit log",
+		zap.String("since", cursor.Since.Format(time.RFC3339)),
+		zap.String("page_cursor", cursor.PageEndCursor))
+
+	var pageCursor *string
+	if cursor.PageEndCursor != "" {
+		pageCursor = &cursor.PageEndCursor
+	}
+
+	entriesResp, err := e.connector.client.ListAuditLogEntriesSince(ctx, cursor.Since, pageCursor)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	syncedIssues, err := e.syncedIssues(ctx, entriesResp.Nodes)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var events []*v2.Event
+	for _, entry := range entriesResp.Nodes {
+		if entry.Timestamp.After(cursor.LatestSeen) {
+			cursor.LatestSeen = entry.Timestamp
+		}
+
+		// System actions have no principal to attribute them to.
+		if entry.Actor() == nil {
+			continue
+		}
+
+		entryEvents, err := newAuditLogEvents(entry, syncedIssues)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		events = append(events, entryEvents...)
+	}
+
+	// If EndCursor is empty despite HasNextPage, stop paginating to avoid an infinite loop.
+	hasMore := entriesResp.PageInfo.HasNextPage && entriesResp.PageInfo.EndCursor != ""
+	if hasMore {
+		cursor.PageEndCursor = entriesResp.PageInfo.EndCursor
+	} else {
+		cursor.Since = cursor.LatestSeen
+		cursor.PageEndCursor = ""
+	}
+
+	nextCursor, err := encodeStreamCursor(cursor)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	l.Debug("wiz-audit-log-feed: processed audit log entries",
+		zap.Int("count", len(events)),
+		zap.Bool("has_more", hasMore))
+
+	return events, &pagination.StreamState{Cursor: nextCursor, HasMore: hasMore}, annos, nil
+}
+
+// syncedIssues returns the IDs of the issues changed by successful actions in a
+// page of audit log entries that the connector may have synced as insights:
+// issues related to a principal that the configured filters select, like the
+// issues feed. Their status is left to the targeted sync the change triggers.
+func (e *auditLogEventFeed) syncedIssues(ctx context.Context, entries []wiz.AuditLogEntry) (map[string]bool, error) {
+	var ids []string
+	for _, entry := range entries {
+		if entry.Actor() != nil && entry.Status == wiz.AuditLogStatusSuccess && slices.Contains(auditIssueActions, entry.Action) {
+			ids = append(ids, auditTargetIDs(entry.ActionParameters)...)
+		}
+	}
+	synced := make(map[string]bool, len(ids))
+	if len(ids) == 0 {
+		return synced, nil
+	}
+
+	slices.Sort(ids)
+	issues, err := e.connector.client.GetIssues(ctx, slices.Compact(ids))
+	if err != nil {
+		return nil, fmt.Errorf("baton-wiz-insights: failed to get issues changed in the audit log: %w", err)
+	}
+	for _, issue := range issues {
+		if wiz.IsPrincipalIssue(issue) && e.connector.issueSync.Filter.matches(issue) {
+			synced[issue.ID] = true
+		}
+	}
+	return synced, nil
+}
+
+// newAuditLogEvents builds the events for an audit log entry: a USAGE event by
+// the acting principal, plus a RESOURCE_CHANGE event for each synced issue
+// changed by a successful action. The action, its outcome and the allowlisted
+// parameters are carried in a details struct annotation on every event.
+func newAuditLogEvents(entry wiz.AuditLogEntry, syncedIssues map[string]bool) ([]*v2.Event, error) {
+	actor, err := newWizUserResource(*entry.Actor(), entry.User == nil)
+	if err != nil {
+		return nil, err
+	}
+
+	details, err := structpb.NewStruct(map[string]interface{}{
+		"audit_log_entry_id": entry.ID,
+		"action":             entry.Action,
+		"status":             entry.Status,
+		"request_id":         entry.RequestID,
+		"source_ip":          entry.SourceIP,
+		"user_agent":         entry.UserAgent,
+		"action_parameters":  auditParameters(entry.ActionParameters),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("baton-wiz-insights: failed to build details for audit log entry %s: %w", entry.ID, err)
+	}
+
+	// The usage event targets the changed Wiz user when there is exactly one,
+	// and otherwise the actor itself.
+	usageTarget := actor
+	if slices.Contains(auditWizUserActions, entry.Action) {
+		if targetIDs := auditTargetIDs(entry.ActionParameters); len(targetIDs) == 1 {
+			usageTarget, err = resource.NewResource(targetIDs[0], wizUserResourceType, targetIDs[0])
+			if err != nil {
+				return nil, fmt.Errorf("baton-wiz-insights: failed to create target resource for audit log entry %s: %w", entry.ID, err)
+			}
+		}
+	}
+
+	events := []*v2.Event{
+		v2.Event_builder{
+			Id:         fmt.Sprintf("audit-%s", entry.ID),
+			OccurredAt: timestamppb.New(entry.Timestamp),
+			UsageEvent: v2.UsageEvent_builder{
+				TargetResource: usageTarget,
+				ActorResource:  actor,
+			}.Build(),
+			Annotations: annotations.New(details),
+		}.Build(),
+	}
+
+	if entry.Status != wiz.AuditLogStatusSuccess || !slices.Contains(auditIssueActions, entry.Action) {
+		return events, nil
+	}
+
+	for _, targetID := range auditTargetIDs(entry.ActionParameters) {
+		if !syncedIssues[targetID] {
+			continue
+		}
+		events = append(events, v2.Event_builder{
+			Id:         fmt.Sprintf("audit-%s-change-%s", entry.ID, targetID),
+			OccurredAt: timestamppb.New(entry.Timestamp),
+			ResourceChangeEvent: v2.ResourceChangeEvent_builder{
+				ResourceId: v2.ResourceId_builder{
+					ResourceType: issueResourceType.GetId(),
+					Resource:     targetID,
+				}.Build(),
+			}.Build(),
+			Annotations: annotations.New(details),
+		}.Build())
+	}
+
+	return events, nil
+}
+
+// auditTargetIDs returns the IDs of the objects an audited action changed. Wiz
+// records the mutation's arguments as the action parameters; the IDs are
+// found as id/ids at the top level or under input or where.
+func auditTargetIDs(params map[string]interface{}) []string {
+	var ids []string
+	collect := func(m map[string]interface{}) {
+		if id, ok := m["id"].(string); ok && id != "" {
+			ids = append(ids, id)
+		}
+		if values, ok := m["ids"].([]interface{}); ok {
+			for _, value := range values {
+				if id, ok := value.(string); ok && id != "" {
+					ids = append(ids, id)
+				}
+			}
+		}
+	}
+
+	collect(params)
+	for _, key := range []string{"input", "where"} {
+		if nested, ok := params[key].(map[string]interface{}); ok {
+			collect(nested)
+		}
+	}
+
+	slices.Sort(ids)
+	return slices.Compact(ids)
+}
+
+// auditParameters returns the allowlisted action parameters of an audited
+// action, including those under the containers that nest the mutation's
+// arguments, such as input.patch. Everything else is dropped.
+func auditParameters(params map[string]interface{}) map[string]interface{} {
+	kept := map[string]interface{}{}
+	for _, field := range auditParameterFields {
+		if value, ok := params[field]; ok {
+			kept[field] = value
+		}
+	}
+	for _, key := range auditParameterContainers {
+		if nested, ok := params[key].(map[string]interface{}); ok {
+			if picked := auditParameters(nested); len(picked) > 0 {
+				kept[key] = picked
+			}
+		}
+	}
+	return kept
+}
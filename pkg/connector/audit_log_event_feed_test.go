@@ -0,0 +1,192 @@
+package connector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestAuditTargetIDs(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]interface{}
+		ids    []string
+	}{
+		{
+			name:   "no parameters",
+			params: nil,
+		},
+		{
+			name:   "top-level id",
+			params: map[string]interface{}{"id": "a"},
+			ids:    []string{"a"},
+		},
+		{
+			name:   "ids under input",
+			params: map[string]interface{}{"input": map[string]interface{}{"ids": []interface{}{"b", "a", ""}}},
+			ids:    []string{"a", "b"},
+		},
+		{
+			name: "ids under where and input are merged and deduplicated",
+			params: map[string]interface{}{
+				"where": map[string]interface{}{"id": "a"},
+				"input": map[string]interface{}{"id": "a", "ids": []interface{}{"c"}},
+			},
+			ids: []string{"a", "c"},
+		},
+		{
+			name:   "non-string ids are ignored",
+			params: map[string]interface{}{"id": 42, "ids": []interface{}{true, "d"}},
+			ids:    []string{"d"},
+		},
+		{
+			name:   "ids in other arguments are ignored",
+			params: map[string]interface{}{"patch": map[string]interface{}{"id": "e"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.ids, auditTargetIDs(tt.params))
+		})
+	}
+}
+
+func TestNewAuditLogEvents(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	admin := &wiz.AuditLogActor{ID: "u1", Name: "Admin", Email: "admin@example.com"}
+
+	tests := []struct {
+		name    string
+		entry   wiz.AuditLogEntry
+		usage   string
+		changes []string
+		kept    map[string]interface{}
+	}{
+		{
+			name: "issue updates are changes with allowlisted parameters",
+			entry: wiz.AuditLogEntry{
+				ID: "e1", Action: "UpdateIssue", Status: wiz.AuditLogStatusSuccess, Timestamp: at, User: admin,
+				ActionParameters: map[string]interface{}{"input": map[string]interface{}{
+					"id":    "issue-1",
+					"patch": map[string]interface{}{"status": "RESOLVED", "note": "see ticket"},
+				}},
+			},
+			usage:   "u1",
+			changes: []string{"issue-1"},
+			kept: map[string]interface{}{"input": map[string]interface{}{
+				"id":    "issue-1",
+				"patch": map[string]interface{}{"status": "RESOLVED"},
+			}},
+		},
+		{
+			name: "only issues the connector syncs are changes",
+			entry: wiz.AuditLogEntry{
+				ID: "e4", Action: "UpdateIssues", Status: wiz.AuditLogStatusSuccess, Timestamp: at, User: admin,
+				ActionParameters: map[string]interface{}{"ids": []interface{}{"issue-1", "vm-issue"}},
+			},
+			usage:   "u1",
+			changes: []string{"issue-1"},
+			kept:    map[string]interface{}{"ids": []interface{}{"issue-1", "vm-issue"}},
+		},
+		{
+			name: "failed issue updates are only usage",
+			entry: wiz.AuditLogEntry{
+				ID: "e2", Action: "UpdateIssue", Status: "FAILED", Timestamp: at, User: admin,
+				ActionParameters: map[string]interface{}{"id": "issue-1"},
+			},
+			usage: "u1",
+			kept:  map[string]interface{}{"id": "issue-1"},
+		},
+		{
+			name: "Wiz user changes target the user without a resource change",
+			entry: wiz.AuditLogEntry{
+				ID: "e3", Action: "CreateServiceAccount", Status: wiz.AuditLogStatusSuccess, Timestamp: at, User: admin,
+				ActionParameters: map[string]interface{}{
+					"input":        map[string]interface{}{"id": "sa-1", "type": "THIRD_PARTY"},
+					"clientSecret": "s3cr3t",
+				},
+			},
+			usage: "sa-1",
+			kept:  map[string]interface{}{"input": map[string]interface{}{"id": "sa-1", "type": "THIRD_PARTY"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := newAuditLogEvents(tt.entry, map[string]bool{"issue-1": true})
+			require.NoError(t, err)
+			require.NotEmpty(t, events)
+
+			assert.Equal(t, tt.usage, events[0].GetUsageEvent().GetTargetResource().GetId().GetResource())
+
+			var changes []string
+			for _, event := range events[1:] {
+				assert.Equal(t, issueResourceType.GetId(), event.GetResourceChangeEvent().GetResourceId().GetResourceType())
+				changes = append(changes, event.GetResourceChangeEvent().GetResourceId().GetResource())
+			}
+			assert.Equal(t, tt.changes, changes)
+
+			details := &structpb.Struct{}
+			annos := annotations.Annotations(events[0].GetAnnotations())
+			ok, err := annos.Pick(details)
+			require.NoError(t, err)
+			require.True(t, ok)
+			assert.Equal(t, tt.kept, details.GetFields()["action_parameters"].GetStructValue().AsMap())
+		})
+	}
+}
+
+// auditLogClient serves one page of audit log entries and the details of issues.
+type auditLogClient struct {
+	detailsClient
+
+	entries []wiz.AuditLogEntry
+}
+
+func (c *auditLogClient) ListAuditLogEntriesSince(_ context.Context, _ time.Time, _ *string) (*wiz.AuditLogEntryConnection, error) {
+	return &wiz.AuditLogEntryConnection{Nodes: c.entries}, nil
+}
+
+func TestAuditLogEventFeedOnlyChangesSyncedIssues(t *testing.T) {
+	ctx := context.Background()
+
+	at := time.Now().UTC().Add(-time.Hour)
+	vmIssue := mappedIssue("vm")
+	vmIssue.EntitySnapshot.Type = "VIRTUAL_MACHINE"
+	client := &auditLogClient{
+		detailsClient: detailsClient{issues: map[string]wiz.Issue{
+			"prod": taggedIssue("prod", map[string]string{"env": "prod"}),
+			"dev":  taggedIssue("dev", map[string]string{"env": "dev"}),
+			"vm":   vmIssue,
+		}},
+		entries: []wiz.AuditLogEntry{{
+			ID: "e1", Action: "UpdateIssues", Status: wiz.AuditLogStatusSuccess, Timestamp: at,
+			User:             &wiz.AuditLogActor{ID: "u1", Name: "Admin"},
+			ActionParameters: map[string]interface{}{"ids": []interface{}{"prod", "dev", "vm", "gone"}},
+		}},
+	}
+	feed := newAuditLogEventFeed(&Connector{
+		client:    client,
+		issueSync: issueSyncOptions{Filter: issueFilter{ExcludeTags: []string{"env=dev"}}},
+	})
+
+	events, _, _, err := feed.ListEvents(ctx, timestamppb.New(at.Add(-time.Hour)), &pagination.StreamToken{})
+	require.NoError(t, err)
+
+	// Issues that are not related to a principal, that the filters do not
+	// select, or that no longer exist are not reported as changed.
+	var changes []string
+	for _, event := range events {
+		if change := event.GetResourceChangeEvent(); change != nil {
+			changes = append(changes, change.GetResourceId().GetResource())
+		}
+	}
+	assert.Equal(t, []string{"prod"}, changes)
+}
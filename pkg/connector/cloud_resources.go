@@ -0,0 +1,272 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+)
+
+// Effective access entitlements on cloud resources, ordered from least to most privileged.
+const (
+	readEntitlement  = "read"
+	writeEntitlement = "write"
+	adminEntitlement = "admin"
+)
+
+var cloudResourceEntitlements = []struct {
+	slug        string
+	displayName string
+	description string
+}{
+	{readEntitlement, "Read", "Can effectively read or list data in %s"},
+	{writeEntitlement, "Write", "Can effectively modify or delete data in %s"},
+	{adminEntitlement, "Admin", "Has effective administrative or high-privilege access to %s"},
+}
+
+// accessTypeEntitlements maps the Wiz access classification to the entitlement it grants.
+var accessTypeEntitlements = map[string]string{
+	"LIST":                   readEntitlement,
+	"READ":                   readEntitlement,
+	"DATA_READ":              readEntitlement,
+	"WRITE":                  writeEntitlement,
+	"DATA_WRITE":             writeEntitlement,
+	"DELETE":                 writeEntitlement,
+	"DATA_DELETE":            writeEntitlement,
+	"ADMIN":                  adminEntitlement,
+	"HIGH_PRIVILEGE":         adminEntitlement,
+	"PERMISSIONS_MANAGEMENT": adminEntitlement,
+}
+
+// cloudResourcesPageSize is the number of cloud resources returned per page.
+const cloudResourcesPageSize = 100
+
+type cloudResourceBuilder struct {
+	client        wiz.Client
+	resourceTypes []string
+
+	// principals are the IDs of the identities with open issues, and
+	// resources the cloud resources they reach, sorted by ID. Both are
+	// collected once per sync, on first use, and paged over from memory.
+	principals map[string]bool
+	resources  []*v2.Resource
+}
+
+func (c *cloudResourceBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
+	return cloudResourceResourceType
+}
+
+// List returns cloud resources of the allowlisted Wiz entity types that
+// identities Wiz flagged with open issues have effective access to, one page
+// at a time. The resources are collected on the first page, so that a
+// resource reached by several identities is listed once; the page token is
+// the offset of the next page in them.
+func (c *cloudResourceBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, attr resource.SyncOpAttrs) ([]*v2.Resource, *resource.SyncOpResults, error) {
+	offset := 0
+	if token := attr.PageToken.Token; token != "" {
+		var err error
+		if offset, err = strconv.Atoi(token); err != nil {
+			return nil, nil, fmt.Errorf("baton-wiz-insights: invalid cloud resources page token: %w", err)
+		}
+	}
+
+	// A sync starts with an empty token; a resumed sync collects the same
+	// resources again in a new process.
+	if offset == 0 || c.resources == nil {
+		c.principals = nil
+		if err := c.collectResources(ctx); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	offset = min(offset, len(c.resources))
+	end := min(offset+cloudResourcesPageSize, len(c.resources))
+
+	syncResults := &resource.SyncOpResults{}
+	if end < len(c.resources) {
+		syncResults.NextPageToken = strconv.Itoa(end)
+	}
+	return c.resources[offset:end], syncResults, nil
+}
+
+// collectResources collects the distinct cloud resources that the identities
+// with open issues have effective access to, sorted by ID. Without resource
+// types to list, nothing is collected rather than querying every type.
+func (c *cloudResourceBuilder) collectResources(ctx context.Context) error {
+	c.resources = []*v2.Resource{}
+	if len(c.resourceTypes) == 0 {
+		return nil
+	}
+
+	principals, err := c.flaggedPrincipals(ctx)
+	if err != nil {
+		return err
+	}
+	ids := slices.Sorted(maps.Keys(principals))
+
+	seen := make(map[string]bool)
+	for batch := range slices.Chunk(ids, cloudResourcesPageSize) {
+		var cursor *string
+		for {
+			resp, err := c.client.ListCloudResources(ctx, c.resourceTypes, batch, cursor)
+			if err != nil {
+				return fmt.Errorf("baton-wiz-insights: failed to list cloud resources: %w", err)
+			}
+
+			for _, access := range resp.Nodes {
+				entity := access.Resource
+				if entity.ID == "" || seen[entity.ID] {
+					continue
+				}
+				seen[entity.ID] = true
+
+				var opts []resource.ResourceOption
+				if externalID := entity.ExternalID(); externalID != "" {
+					opts = append(opts, resource.WithDescription(externalID))
+				}
+
+				cloudResource, err := resource.NewResource(
+					entity.Name,
+					cloudResourceResourceType,
+					entity.ID,
+					opts...,
+				)
+				if err != nil {
+					return fmt.Errorf("baton-wiz-insights: failed to create cloud resource for entity %s: %w", entity.ID, err)
+				}
+				c.resources = append(c.resources, cloudResource)
+			}
+
+			// If EndCursor is empty despite HasNextPage, stop paginating to avoid an infinite loop.
+			if !resp.PageInfo.HasNextPage || resp.PageInfo.EndCursor == "" {
+				break
+			}
+			cursor = &resp.PageInfo.EndCursor
+		}
+	}
+
+	slices.SortFunc(c.resources, func(a, b *v2.Resource) int {
+		return strings.Compare(a.GetId().GetResource(), b.GetId().GetResource())
+	})
+	return nil
+}
+
+// flaggedPrincipals returns the IDs of the identities that open issues are
+// about, listing the open issues on first use.
+func (c *cloudResourceBuilder) flaggedPrincipals(ctx context.Context) (map[string]bool, error) {
+	if c.principals != nil {
+		return c.principals, nil
+	}
+
+	principals := make(map[string]bool)
+	var cursor *string
+	for {
+		resp, err := c.client.ListIssues(ctx, wiz.IssueScope{ActiveOnly: true}, cursor)
+		if err != nil {
+			return nil, fmt.Errorf("baton-wiz-insights: failed to list flagged identities: %w", err)
+		}
+
+		for _, issue := range resp.Nodes {
+			if wiz.IsPrincipalIssue(issue) && issue.EntitySnapshot.ID != "" {
+				principals[issue.EntitySnapshot.ID] = true
+			}
+		}
+
+		// If EndCursor is empty despite HasNextPage, stop paginating to avoid an infinite loop.
+		if !resp.PageInfo.HasNextPage || resp.PageInfo.EndCursor == "" {
+			break
+		}
+		cursor = &resp.PageInfo.EndCursor
+	}
+
+	c.principals = principals
+	return principals, nil
+}
+
+// Entitlements returns the read, write and admin effective access entitlements for a cloud resource.
+func (c *cloudResourceBuilder) Entitlements(_ context.Context, r *v2.Resource, _ resource.SyncOpAttrs) ([]*v2.Entitlement, *resource.SyncOpResults, error) {
+	entitlements := make([]*v2.Entitlement, 0, len(cloudResourceEntitlements))
+	for _, e := range cloudResourceEntitlements {
+		entitlements = append(entitlements, entitlement.NewPermissionEntitlement(
+			r,
+			e.slug,
+			entitlement.WithGrantableTo(identityResourceType),
+			entitlement.WithDisplayName(fmt.Sprintf("%s %s", r.GetDisplayName(), e.displayName)),
+			entitlement.WithDescription(fmt.Sprintf(e.description, r.GetDisplayName())),
+		))
+	}
+
+	return entitlements, nil, nil
+}
+
+// Grants returns a grant for each identity with open issues that has
+// effective access to the cloud resource, one per entitlement derived from the
+// Wiz access classification. Other identities are not synced as flagged, so
+// their access is left out.
+func (c *cloudResourceBuilder) Grants(ctx context.Context, r *v2.Resource, attr resource.SyncOpAttrs) ([]*v2.Grant, *resource.SyncOpResults, error) {
+	principals, err := c.flaggedPrincipals(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var grants []*v2.Grant
+
+	var cursor *string
+	if attr.PageToken.Token != "" {
+		cursor = &attr.PageToken.Token
+	}
+
+	resp, err := c.client.ListEffectiveAccess(ctx, r.GetId().GetResource(), cursor)
+	if err != nil {
+		return nil, nil, fmt.Errorf("baton-wiz-insights: failed to list effective access for %s: %w", r.GetId().GetResource(), err)
+	}
+
+	for _, access := range resp.Nodes {
+		if !principals[access.Principal.ID] {
+			continue
+		}
+		principalID := identityResourceID(access.Principal.ID)
+		for _, slug := range accessEntitlements(access.AccessTypes) {
+			grants = append(grants, grant.NewGrant(r, slug, principalID))
+		}
+	}
+
+	// If EndCursor is empty despite HasNextPage, stop paginating to avoid an infinite loop.
+	syncResults := &resource.SyncOpResults{}
+	if resp.PageInfo.HasNextPage && resp.PageInfo.EndCursor != "" {
+		syncResults.NextPageToken = resp.PageInfo.EndCursor
+	}
+
+	return grants, syncResults, nil
+}
+
+func newCloudResourceBuilder(client wiz.Client, resourceTypes []string) *cloudResourceBuilder {
+	return &cloudResourceBuilder{client: client, resourceTypes: resourceTypes}
+}
+
+// accessEntitlements returns the distinct entitlement slugs for a set of Wiz
+// access classifications, in entitlement order. Unknown classifications are ignored.
+func accessEntitlements(accessTypes []string) []string {
+	granted := make(map[string]bool)
+	for _, accessType := range accessTypes {
+		if slug, ok := accessTypeEntitlements[strings.ToUpper(accessType)]; ok {
+			granted[slug] = true
+		}
+	}
+
+	var slugs []string
+	for _, e := range cloudResourceEntitlements {
+		if granted[e.slug] {
+			slugs = append(slugs, e.slug)
+		}
+	}
+	return slugs
+}
@@ -0,0 +1,216 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessEntitlements(t *testing.T) {
+	tests := []struct {
+		name        string
+		accessTypes []string
+		slugs       []string
+	}{
+		{
+			name: "no access types",
+		},
+		{
+			name:        "read classifications",
+			accessTypes: []string{"LIST", "DATA_READ"},
+			slugs:       []string{readEntitlement},
+		},
+		{
+			name:        "slugs are distinct and in entitlement order",
+			accessTypes: []string{"HIGH_PRIVILEGE", "DATA_WRITE", "READ", "DELETE"},
+			slugs:       []string{readEntitlement, writeEntitlement, adminEntitlement},
+		},
+		{
+			name:        "classifications are matched ignoring case",
+			accessTypes: []string{"permissions_management"},
+			slugs:       []string{adminEntitlement},
+		},
+		{
+			name:        "unknown classifications are ignored",
+			accessTypes: []string{"EXECUTE", "WRITE"},
+			slugs:       []string{writeEntitlement},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.slugs, accessEntitlements(tt.accessTypes))
+		})
+	}
+}
+
+// flaggedAccessClient serves active issues one per page, and the effective
+// access of the principals a query is scoped to in pages of two.
+type flaggedAccessClient struct {
+	wiz.Client
+
+	issues []wiz.Issue
+	access []wiz.EffectiveAccess
+	scopes [][]string
+}
+
+func pageOffset(cursor *string) (int, error) {
+	offset := 0
+	if cursor != nil {
+		if _, err := fmt.Sscanf(*cursor, "%d", &offset); err != nil {
+			return 0, err
+		}
+	}
+	return offset, nil
+}
+
+func (c *flaggedAccessClient) ListIssues(_ context.Context, scope wiz.IssueScope, cursor *string) (*wiz.IssueConnection, error) {
+	if !scope.ActiveOnly {
+		return nil, fmt.Errorf("cloud resources must only list active issues")
+	}
+	offset, err := pageOffset(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &wiz.IssueConnection{Nodes: c.issues[offset : offset+1]}
+	if offset+1 < len(c.issues) {
+		resp.PageInfo = wiz.PageInfo{HasNextPage: true, EndCursor: fmt.Sprint(offset + 1)}
+	}
+	return resp, nil
+}
+
+func (c *flaggedAccessClient) ListCloudResources(_ context.Context, _, principalIDs []string, cursor *string) (*wiz.EffectiveAccessConnection, error) {
+	if cursor == nil {
+		c.scopes = append(c.scopes, principalIDs)
+	}
+	var matched []wiz.EffectiveAccess
+	for _, access := range c.access {
+		if slices.Contains(principalIDs, access.Principal.ID) {
+			matched = append(matched, access)
+		}
+	}
+	return pageOfAccess(matched, cursor)
+}
+
+func (c *flaggedAccessClient) ListEffectiveAccess(_ context.Context, resourceID string, cursor *string) (*wiz.EffectiveAccessConnection, error) {
+	var matched []wiz.EffectiveAccess
+	for _, access := range c.access {
+		if access.Resource.ID == resourceID {
+			matched = append(matched, access)
+		}
+	}
+	return pageOfAccess(matched, cursor)
+}
+
+// pageOfAccess serves effective access in pages of two.
+func pageOfAccess(access []wiz.EffectiveAccess, cursor *string) (*wiz.EffectiveAccessConnection, error) {
+	offset, err := pageOffset(cursor)
+	if err != nil {
+		return nil, err
+	}
+	end := min(offset+2, len(access))
+	resp := &wiz.EffectiveAccessConnection{Nodes: access[offset:end]}
+	if end < len(access) {
+		resp.PageInfo = wiz.PageInfo{HasNextPage: true, EndCursor: fmt.Sprint(end)}
+	}
+	return resp, nil
+}
+
+func newFlaggedAccessClient() *flaggedAccessClient {
+	principalIssue := func(id, entityID string) wiz.Issue {
+		issue := wiz.Issue{ID: id}
+		issue.EntitySnapshot.ID = entityID
+		issue.EntitySnapshot.Type = "USER_ACCOUNT"
+		return issue
+	}
+	resourceIssue := wiz.Issue{ID: "i3"}
+	resourceIssue.EntitySnapshot.ID = "vm"
+	resourceIssue.EntitySnapshot.Type = "VIRTUAL_MACHINE"
+
+	access := func(principal, resource string) wiz.EffectiveAccess {
+		return wiz.EffectiveAccess{
+			Principal:   wiz.GraphEntity{ID: principal},
+			Resource:    wiz.GraphEntity{ID: resource, Name: resource},
+			AccessTypes: []string{"READ"},
+		}
+	}
+
+	return &flaggedAccessClient{
+		issues: []wiz.Issue{principalIssue("i1", "alice"), resourceIssue, principalIssue("i2", "bob"), principalIssue("i4", "alice")},
+		access: []wiz.EffectiveAccess{
+			access("alice", "logs"),
+			access("alice", "logs"),
+			access("alice", "kms"),
+			access("bob", "logs"),
+			access("carol", "db"),
+			access("carol", "logs"),
+		},
+	}
+}
+
+func TestCloudResourceBuilderListsResourcesOfFlaggedIdentities(t *testing.T) {
+	ctx := context.Background()
+
+	client := newFlaggedAccessClient()
+	builder := newCloudResourceBuilder(client, []string{"BUCKET", "ENCRYPTION_KEY"})
+
+	var resources []string
+	token := ""
+	for range 10 {
+		page, results, err := builder.List(ctx, nil, resource.SyncOpAttrs{PageToken: pagination.Token{Token: token}})
+		require.NoError(t, err)
+		for _, r := range page {
+			resources = append(resources, r.GetId().GetResource())
+		}
+		if token = results.NextPageToken; token == "" {
+			break
+		}
+	}
+
+	// Only principals with open issues are queried, and every resource they
+	// reach is listed once; carol is not flagged, so her database is not listed.
+	assert.Equal(t, [][]string{{"alice", "bob"}}, client.scopes)
+	assert.Equal(t, []string{"kms", "logs"}, resources)
+}
+
+func TestCloudResourceBuilderWithoutResourceTypesListsNothing(t *testing.T) {
+	client := newFlaggedAccessClient()
+	builder := newCloudResourceBuilder(client, nil)
+
+	resources, results, err := builder.List(context.Background(), nil, resource.SyncOpAttrs{})
+	require.NoError(t, err)
+	assert.Empty(t, resources)
+	assert.Empty(t, results.NextPageToken)
+	assert.Empty(t, client.scopes)
+}
+
+func TestCloudResourceBuilderGrantsFlaggedIdentities(t *testing.T) {
+	ctx := context.Background()
+
+	builder := newCloudResourceBuilder(newFlaggedAccessClient(), []string{"BUCKET"})
+	logs, err := resource.NewResource("logs", cloudResourceResourceType, "logs")
+	require.NoError(t, err)
+
+	var principals []string
+	token := ""
+	for range 10 {
+		grants, results, err := builder.Grants(ctx, logs, resource.SyncOpAttrs{PageToken: pagination.Token{Token: token}})
+		require.NoError(t, err)
+		for _, g := range grants {
+			principals = append(principals, g.GetPrincipal().GetId().GetResource())
+		}
+		if token = results.NextPageToken; token == "" {
+			break
+		}
+	}
+
+	// Carol can read the logs, but has no open issues.
+	assert.Equal(t, []string{"alice", "alice", "bob"}, principals)
+}
@@ -4,37 +4,163 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"strings"
+	"sync"
+	"time"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
 	"github.com/conductorone/baton-sdk/pkg/cli"
 	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
 	cfg "github.com/conductorone/baton-wiz-insights/pkg/config"
+	"github.com/conductorone/baton-wiz-insights/pkg/webhook"
 	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
 )
 
 type Connector struct {
-	client wiz.Client
+	client              wiz.Client
+	syncCredentials     bool
+	syncRiskSummaries   bool
+	syncControls        bool
+	syncExcessiveAccess bool
+	syncEffectiveAccess bool
+	syncSecretFindings  bool
+	syncDetections      bool
+	syncActivity        bool
+	syncAuditLog        bool
+
+	// activityBucket is the time bucket principal activity is aggregated into.
+	activityBucket time.Duration
+
+	// auditLogLookback is how far back the audit log feed starts when it has no stored progress.
+	auditLogLookback time.Duration
+
+	// cursorOptions configures where event feed cursors start and recover.
+	cursorOptions eventCursorOptions
+
+	// backfillSlice is the size of the time slices the issues event feed walks
+	// its initial lookback in, or zero when backfill is disabled.
+	backfillSlice time.Duration
+
+	// webhookBuffer holds notifications received by webhookServer. Both are nil
+	// when the webhook listener is disabled. The listener is only started by
+	// the issues event feed, so a one-shot sync never opens its port.
+	webhookBuffer    *webhook.Buffer
+	webhookServer    *webhook.Server
+	webhookMu        sync.Mutex
+	webhookStarted   bool
+	webhookReconcile time.Duration
+
+	// insightReconcile is how often the issues event feed prunes the insights
+	// it emitted that left the synced scope, or zero when disabled.
+	insightReconcile time.Duration
+
+	// issueSync configures how the full issue sync lists issues.
+	issueSync issueSyncOptions
+
+	// effectiveAccessResourceTypes is the allowlist of Wiz entity types synced as cloud resources.
+	effectiveAccessResourceTypes []string
 }
 
 // ResourceSyncers returns a ResourceSyncer for each resource type that should be synced from the upstream service.
 func (c *Connector) ResourceSyncers(ctx context.Context) []connectorbuilder.ResourceSyncerV2 {
-	return []connectorbuilder.ResourceSyncerV2{
-		newIssueBuilder(c.client),
+	syncers := []connectorbuilder.ResourceSyncerV2{
+		newIssueBuilder(c.client, c.issueSync),
+	}
+
+	if c.syncRiskSummaries {
+		syncers = append(syncers, newIdentityRiskSummaryBuilder(c.client, c.issueSync.Filter))
+	}
+	if c.syncControls {
+		syncers = append(syncers, newControlBuilder(c.client))
+	}
+	if c.syncIdentities() {
+		syncers = append(syncers, newIdentityBuilder(c.client))
 	}
+	if c.syncCredentials {
+		syncers = append(syncers, newCredentialBuilder(c.client))
+	}
+	if c.syncExcessiveAccess {
+		syncers = append(syncers, newExcessiveAccessBuilder(c.client))
+	}
+	if c.syncEffectiveAccess {
+		syncers = append(syncers, newCloudResourceBuilder(c.client, c.effectiveAccessResourceTypes))
+	}
+	if c.syncSecretFindings {
+		syncers = append(syncers, newSecretFindingBuilder(c.client))
+	}
+	if c.syncAuditLog {
+		syncers = append(syncers, newWizUserBuilder(c.client))
+	}
+
+	return syncers
+}
+
+// syncIdentities reports whether any enabled resource type or event feed
+// links to identity resources.
+func (c *Connector) syncIdentities() bool {
+	return c.syncCredentials || c.syncEffectiveAccess || c.syncDetections || c.syncActivity
 }
 
 // EventFeeds returns the event feeds supported by this connector.
 // This makes the Connector satisfy EventProviderV2 and the SDK
 // will automatically report CAPABILITY_EVENT_FEED_V2.
 func (c *Connector) EventFeeds(_ context.Context) []connectorbuilder.EventFeed {
-	return []connectorbuilder.EventFeed{
+	feeds := []connectorbuilder.EventFeed{
 		newIssuesEventFeed(c),
 	}
+
+	if c.syncExcessiveAccess {
+		feeds = append(feeds, newExcessiveAccessEventFeed(c))
+	}
+	if c.syncSecretFindings {
+		feeds = append(feeds, newSecretFindingsEventFeed(c))
+	}
+	if c.syncDetections {
+		feeds = append(feeds, newDetectionsEventFeed(c))
+	}
+	if c.syncActivity {
+		feeds = append(feeds, newPrincipalActivityEventFeed(c))
+	}
+	if c.syncAuditLog {
+		feeds = append(feeds, newAuditLogEventFeed(c))
+	}
+
+	return feeds
+}
+
+// startWebhook starts the webhook listener, if enabled, the first time it is
+// called. A listener that failed to start is retried on the next call.
+func (c *Connector) startWebhook() error {
+	c.webhookMu.Lock()
+	defer c.webhookMu.Unlock()
+
+	if c.webhookServer == nil || c.webhookStarted {
+		return nil
+	}
+	if err := c.webhookServer.Start(); err != nil {
+		return fmt.Errorf("baton-wiz-insights: %w", err)
+	}
+	c.webhookStarted = true
+	return nil
 }
 
-// Close releases any resources held by the connector's client.
+// Close stops the webhook listener and releases any resources held by the connector's client.
 func (c *Connector) Close() error {
+	c.webhookMu.Lock()
+	started := c.webhookStarted
+	c.webhookMu.Unlock()
+	if started {
+		if err := c.webhookServer.Close(); err != nil {
+			return fmt.Errorf("baton-wiz-insights: failed to stop webhook listener: %w", err)
+		}
+	}
+	if c.webhookBuffer != nil {
+		if err := c.webhookBuffer.Close(); err != nil {
+			return fmt.Errorf("baton-wiz-insights: failed to close webhook buffer: %w", err)
+		}
+	}
 	if c.client != nil {
 		return c.client.Close()
 	}
@@ -44,7 +170,10 @@ func (c *Connector) Close() error {
 // Asset takes an input AssetRef and attempts to fetch it using the connector's authenticated http client
 // It streams a response, always starting with a metadata object, following by chunked payloads for the asset.
 func (c *Connector) Asset(ctx context.Context, asset *v2.AssetRef) (string, io.ReadCloser, error) {
-	return "", nil, fmt.Errorf("baton-wiz-insights: asset retrieval not supported")
+	if strings.HasPrefix(asset.GetId(), remediationPolicyAssetPrefix) {
+		return remediationPolicyAsset(ctx, c.client, asset.GetId())
+	}
+	return "", nil, fmt.Errorf("baton-wiz-insights: asset retrieval not supported for %q", asset.GetId())
 }
 
 // Metadata returns metadata about the connector.
@@ -72,6 +201,18 @@ func New(ctx context.Context,
 	[]connectorbuilder.Opt,
 	error,
 ) {
+	if err := cfg.ValidateConfig(connectorConfig); err != nil {
+		return nil, nil, err
+	}
+
+	if !validIssueShardBy(connectorConfig.IssueSyncShardBy) {
+		return nil, nil, fmt.Errorf("invalid issue sync shards %q: must be %q or %q", connectorConfig.IssueSyncShardBy, issueShardBySeverity, issueShardByProject)
+	}
+
+	if !validRecordValidation(connectorConfig.RecordValidation) {
+		return nil, nil, fmt.Errorf("invalid record validation %q: must be %q or %q", connectorConfig.RecordValidation, recordValidationStrict, recordValidationLenient)
+	}
+
 	// Initialize the Wiz API client
 	client, err := wiz.NewClient(
 		ctx,
@@ -79,10 +220,70 @@ func New(ctx context.Context,
 		connectorConfig.WizClientId,
 		connectorConfig.WizClientSecret,
 		connectorConfig.WizAuthEndpoint,
+		connectorConfig.WizRequestsPerSecond,
 	)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create Wiz client: %w", err)
 	}
 
-	return &Connector{client: client}, nil, nil
+	var webhookBuffer *webhook.Buffer
+	var webhookServer *webhook.Server
+	if connectorConfig.WebhookListenAddress != "" {
+		webhookBuffer, err = webhook.OpenBuffer(connectorConfig.WebhookBufferDir)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open webhook buffer: %w", err)
+		}
+		webhookServer, err = webhook.NewServer(connectorConfig.WebhookListenAddress, connectorConfig.WebhookSecret, webhookBuffer, ctxzap.Extract(ctx))
+		if err != nil {
+			_ = webhookBuffer.Close()
+			return nil, nil, fmt.Errorf("failed to create webhook listener: %w", err)
+		}
+	}
+
+	var backfillSlice time.Duration
+	if connectorConfig.EventBackfill {
+		backfillSlice = time.Duration(connectorConfig.EventBackfillSliceHours) * time.Hour
+	}
+
+	return &Connector{
+		client:              client,
+		syncCredentials:     connectorConfig.SyncCredentials,
+		syncRiskSummaries:   connectorConfig.SyncIdentityRiskSummary,
+		syncControls:        connectorConfig.SyncControls,
+		syncExcessiveAccess: connectorConfig.SyncExcessiveAccess,
+		syncEffectiveAccess: connectorConfig.SyncEffectiveAccess,
+		syncSecretFindings:  connectorConfig.SyncSecretFindings,
+		syncDetections:      connectorConfig.SyncThreatDetections,
+		syncActivity:        connectorConfig.SyncPrincipalActivity,
+		syncAuditLog:        connectorConfig.SyncAuditLog,
+
+		activityBucket:   time.Duration(connectorConfig.PrincipalActivityBucketMinutes) * time.Minute,
+		auditLogLookback: time.Duration(connectorConfig.AuditLogLookbackDays) * 24 * time.Hour,
+		cursorOptions: eventCursorOptions{
+			InitialLookback: time.Duration(connectorConfig.EventLookbackDays) * 24 * time.Hour,
+			RecoveryWindow:  time.Duration(connectorConfig.EventCursorRecoveryHours) * time.Hour,
+		},
+		issueSync: issueSyncOptions{
+			ShardBy:       connectorConfig.IssueSyncShardBy,
+			Concurrency:   connectorConfig.IssueSyncConcurrency,
+			Report:        connectorConfig.IssueSyncReport,
+			ReportID:      connectorConfig.IssueSyncReportId,
+			ReportTimeout: time.Duration(connectorConfig.IssueSyncReportTimeoutMinutes) * time.Minute,
+			ActiveOnly:    connectorConfig.IssueActiveOnly,
+			Validation:    connectorConfig.RecordValidation,
+			Filter: issueFilter{
+				Frameworks:  connectorConfig.IssueFrameworks,
+				IncludeTags: connectorConfig.IssueIncludeTags,
+				ExcludeTags: connectorConfig.IssueExcludeTags,
+			},
+			OwnerTag:     connectorConfig.InsightOwnerTag,
+			LinkControls: connectorConfig.SyncControls,
+		},
+		backfillSlice:                backfillSlice,
+		webhookBuffer:                webhookBuffer,
+		webhookServer:                webhookServer,
+		webhookReconcile:             time.Duration(connectorConfig.WebhookReconcileMinutes) * time.Minute,
+		insightReconcile:             time.Duration(connectorConfig.InsightReconcileMinutes) * time.Minute,
+		effectiveAccessResourceTypes: connectorConfig.EffectiveAccessResourceTypes,
+	}, nil, nil
 }
@@ -0,0 +1,117 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+)
+
+// cloudConfigurationRulesTokenPrefix marks page tokens that list cloud
+// configuration rules, which are listed once every control has been listed.
+const cloudConfigurationRulesTokenPrefix = "cloud-configuration-rules:"
+
+type controlBuilder struct {
+	client wiz.Client
+}
+
+func (c *controlBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
+	return controlResourceType
+}
+
+// List returns Wiz controls, then cloud configuration rules, as control
+// resources, one page at a time.
+func (c *controlBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, attr resource.SyncOpAttrs) ([]*v2.Resource, *resource.SyncOpResults, error) {
+	token := attr.PageToken.Token
+	rules := strings.HasPrefix(token, cloudConfigurationRulesTokenPrefix)
+
+	var cursor *string
+	if token = strings.TrimPrefix(token, cloudConfigurationRulesTokenPrefix); token != "" {
+		cursor = &token
+	}
+
+	var resp *wiz.ControlConnection
+	var err error
+	if rules {
+		resp, err = c.client.ListCloudConfigurationRules(ctx, cursor)
+		if err != nil {
+			return nil, nil, fmt.Errorf("baton-wiz-insights: failed to list cloud configuration rules: %w", err)
+		}
+	} else {
+		resp, err = c.client.ListControls(ctx, cursor)
+		if err != nil {
+			return nil, nil, fmt.Errorf("baton-wiz-insights: failed to list controls: %w", err)
+		}
+	}
+
+	var resources []*v2.Resource
+	for _, control := range resp.Nodes {
+		controlResource, err := newControlResource(control)
+		if err != nil {
+			return nil, nil, err
+		}
+		resources = append(resources, controlResource)
+	}
+
+	// If EndCursor is empty despite HasNextPage, stop paginating to avoid an infinite loop.
+	syncResults := &resource.SyncOpResults{}
+	switch {
+	case resp.PageInfo.HasNextPage && resp.PageInfo.EndCursor != "":
+		if rules {
+			syncResults.NextPageToken = cloudConfigurationRulesTokenPrefix + resp.PageInfo.EndCursor
+		} else {
+			syncResults.NextPageToken = resp.PageInfo.EndCursor
+		}
+	case !rules:
+		syncResults.NextPageToken = cloudConfigurationRulesTokenPrefix
+	}
+
+	return resources, syncResults, nil
+}
+
+// Entitlements returns an empty slice for controls.
+func (c *controlBuilder) Entitlements(_ context.Context, _ *v2.Resource, _ resource.SyncOpAttrs) ([]*v2.Entitlement, *resource.SyncOpResults, error) {
+	return nil, nil, nil
+}
+
+// Grants returns an empty slice for controls.
+func (c *controlBuilder) Grants(_ context.Context, _ *v2.Resource, _ resource.SyncOpAttrs) ([]*v2.Grant, *resource.SyncOpResults, error) {
+	return nil, nil, nil
+}
+
+func newControlBuilder(client wiz.Client) *controlBuilder {
+	return &controlBuilder{client: client}
+}
+
+// newControlResource converts a Wiz control or cloud configuration rule into a
+// control resource, with its settings and framework mappings in the profile so
+// that changes to the rule show up between syncs.
+func newControlResource(control wiz.Control) (*v2.Resource, error) {
+	profile := map[string]interface{}{
+		"severity":   control.Severity,
+		"rule_type":  control.Type,
+		"enabled":    control.Enabled,
+		"frameworks": frameworkMappings(control.SecuritySubCategories),
+	}
+	if control.ScopeProject != nil {
+		profile["project_id"] = control.ScopeProject.ID
+		profile["project_name"] = control.ScopeProject.Name
+	}
+
+	opts := []resource.ResourceOption{
+		withInsightProfile(profile),
+	}
+	if control.Description != "" {
+		opts = append(opts, resource.WithDescription(control.Description))
+	}
+
+	controlResource, err := resource.NewResource(control.Name, controlResourceType, control.ID, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("baton-wiz-insights: failed to create control resource for %s: %w", control.ID, err)
+	}
+
+	return controlResource, nil
+}
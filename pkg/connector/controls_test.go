@@ -0,0 +1,125 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// controlsClient serves controls in pages of one, then cloud configuration rules.
+type controlsClient struct {
+	wiz.Client
+
+	controls []wiz.Control
+	rules    []wiz.Control
+}
+
+func controlPage(controls []wiz.Control, cursor *string) *wiz.ControlConnection {
+	offset := 0
+	if cursor != nil && *cursor == "next" {
+		offset = 1
+	}
+	resp := &wiz.ControlConnection{Nodes: controls[offset : offset+1]}
+	if offset+1 < len(controls) {
+		resp.PageInfo = wiz.PageInfo{HasNextPage: true, EndCursor: "next"}
+	}
+	return resp
+}
+
+func (c *controlsClient) ListControls(_ context.Context, cursor *string) (*wiz.ControlConnection, error) {
+	return controlPage(c.controls, cursor), nil
+}
+
+func (c *controlsClient) ListCloudConfigurationRules(_ context.Context, cursor *string) (*wiz.ControlConnection, error) {
+	return controlPage(c.rules, cursor), nil
+}
+
+func profileOf(t *testing.T, annos annotations.Annotations) map[string]*structpb.Value {
+	t.Helper()
+
+	profile := &structpb.Struct{}
+	ok, err := annos.Pick(profile)
+	require.NoError(t, err)
+	require.True(t, ok)
+	return profile.GetFields()
+}
+
+func TestControlBuilderListsControlsThenRules(t *testing.T) {
+	ctx := context.Background()
+
+	client := &controlsClient{
+		controls: []wiz.Control{
+			{ID: "c1", Name: "Admin without MFA", Severity: wiz.IssueSeverityHigh, Type: "SECURITY_GRAPH", Enabled: true,
+				ScopeProject: &wiz.Project{ID: "p1", Name: "Platform"}},
+			{ID: "c2", Name: "Stale service account", Severity: wiz.IssueSeverityLow, Type: "SECURITY_GRAPH"},
+		},
+		rules: []wiz.Control{
+			{ID: "r1", Name: "Access key rotated", Severity: wiz.IssueSeverityMedium, Type: wiz.ControlTypeCloudConfiguration, Enabled: true,
+				SecuritySubCategories: []wiz.SecuritySubCategory{{
+					Title:      "1.14 Ensure access keys are rotated",
+					ExternalID: "1.14",
+					Category: wiz.SecurityCategory{
+						Name:      "1 Identity and Access Management",
+						Framework: wiz.SecurityFramework{Name: "CIS AWS 2.0.0"},
+					},
+				}}},
+		},
+	}
+	builder := newControlBuilder(client)
+
+	var ids []string
+	var resources []*v2.Resource
+	token := ""
+	for {
+		page, results, err := builder.List(ctx, nil, resource.SyncOpAttrs{PageToken: pagination.Token{Token: token}})
+		require.NoError(t, err)
+		for _, r := range page {
+			ids = append(ids, r.GetId().GetResource())
+		}
+		resources = append(resources, page...)
+		if token = results.NextPageToken; token == "" {
+			break
+		}
+	}
+	assert.Equal(t, []string{"c1", "c2", "r1"}, ids)
+
+	profile := profileOf(t, resources[0].GetAnnotations())
+	assert.Equal(t, "p1", profile["project_id"].GetStringValue())
+	assert.True(t, profile["enabled"].GetBoolValue())
+
+	profile = profileOf(t, resources[2].GetAnnotations())
+	assert.Equal(t, wiz.ControlTypeCloudConfiguration, profile["rule_type"].GetStringValue())
+	frameworks := profile["frameworks"].GetListValue().GetValues()
+	require.Len(t, frameworks, 1)
+	assert.Equal(t, "CIS AWS 2.0.0", frameworks[0].GetStructValue().GetFields()["framework"].GetStringValue())
+}
+
+func TestIssueResourceLinksControl(t *testing.T) {
+	issue := wiz.Issue{
+		ID:             "i1",
+		Severity:       wiz.IssueSeverityHigh,
+		SourceRule:     wiz.SourceRule{ID: "c1", Name: "Admin without MFA"},
+		EntitySnapshot: wiz.EntitySnapshot{ID: "entity-1"},
+	}
+
+	insight, err := newIssueResource(issue, issueSyncOptions{LinkControls: true})
+	require.NoError(t, err)
+
+	profile := profileOf(t, insight.GetAnnotations())
+	assert.Equal(t, "c1", profile["control_id"].GetStringValue())
+	assert.Equal(t, controlResourceType.Id, insight.GetParentResourceId().GetResourceType())
+	assert.Equal(t, "c1", insight.GetParentResourceId().GetResource())
+
+	// Without synced controls, the parent would not exist.
+	insight, err = newIssueResource(issue, issueSyncOptions{})
+	require.NoError(t, err)
+	assert.Nil(t, insight.GetParentResourceId())
+}
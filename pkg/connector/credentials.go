@@ -0,0 +1,96 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+)
+
+type credentialBuilder struct {
+	client wiz.Client
+}
+
+func (c *credentialBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
+	return credentialResourceType
+}
+
+// List returns Wiz access keys as secret resources, one page at a time.
+// Each credential is linked to the identity that owns it.
+func (c *credentialBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, attr resource.SyncOpAttrs) ([]*v2.Resource, *resource.SyncOpResults, error) {
+	var resources []*v2.Resource
+
+	var cursor *string
+	if attr.PageToken.Token != "" {
+		cursor = &attr.PageToken.Token
+	}
+
+	resp, err := c.client.ListAccessKeys(ctx, cursor)
+	if err != nil {
+		return nil, nil, fmt.Errorf("baton-wiz-insights: failed to list access keys: %w", err)
+	}
+
+	for _, node := range resp.Nodes {
+		if len(node.Entities) == 0 {
+			continue
+		}
+		key := node.Entities[0]
+
+		var secretOpts []resource.SecretTraitOption
+		if createdAt := key.CreatedAt(); !createdAt.IsZero() {
+			secretOpts = append(secretOpts, resource.WithSecretCreatedAt(createdAt))
+		}
+		if lastUsedAt := key.LastUsedAt(); !lastUsedAt.IsZero() {
+			secretOpts = append(secretOpts, resource.WithSecretLastUsedAt(lastUsedAt))
+		}
+		if expiresAt := key.ExpiresAt(); !expiresAt.IsZero() {
+			secretOpts = append(secretOpts, resource.WithSecretExpiresAt(expiresAt))
+		}
+
+		// The owning principal is the second selected entity in the graph search.
+		if len(node.Entities) > 1 {
+			secretOpts = append(secretOpts, resource.WithSecretIdentityID(identityResourceID(node.Entities[1].ID)))
+		}
+
+		displayName := key.Name
+		if displayName == "" {
+			displayName = key.ExternalID()
+		}
+
+		credentialResource, err := resource.NewSecretResource(
+			displayName,
+			credentialResourceType,
+			key.ID,
+			secretOpts,
+		)
+		if err != nil {
+			return nil, nil, fmt.Errorf("baton-wiz-insights: failed to create credential resource for access key %s: %w", key.ID, err)
+		}
+
+		resources = append(resources, credentialResource)
+	}
+
+	// If EndCursor is empty despite HasNextPage, stop paginating to avoid an infinite loop.
+	syncResults := &resource.SyncOpResults{}
+	if resp.PageInfo.HasNextPage && resp.PageInfo.EndCursor != "" {
+		syncResults.NextPageToken = resp.PageInfo.EndCursor
+	}
+
+	return resources, syncResults, nil
+}
+
+// Entitlements returns an empty slice for credentials.
+func (c *credentialBuilder) Entitlements(_ context.Context, _ *v2.Resource, _ resource.SyncOpAttrs) ([]*v2.Entitlement, *resource.SyncOpResults, error) {
+	return nil, nil, nil
+}
+
+// Grants returns an empty slice for credentials.
+func (c *credentialBuilder) Grants(_ context.Context, _ *v2.Resource, _ resource.SyncOpAttrs) ([]*v2.Grant, *resource.SyncOpResults, error) {
+	return nil, nil, nil
+}
+
+func newCredentialBuilder(client wiz.Client) *credentialBuilder {
+	return &credentialBuilder{client: client}
+}
@@ -0,0 +1,98 @@
+package connector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// accessKeysClient serves a single page of access keys.
+type accessKeysClient struct {
+	wiz.Client
+
+	resp *wiz.GraphSearchConnection
+}
+
+func (c *accessKeysClient) ListAccessKeys(_ context.Context, _ *string) (*wiz.GraphSearchConnection, error) {
+	return c.resp, nil
+}
+
+func TestCredentialBuilderList(t *testing.T) {
+	ctx := context.Background()
+
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	key := wiz.GraphEntity{ID: "k1", Type: "ACCESS_KEY", Properties: map[string]interface{}{
+		"externalId":   "AKIAEXAMPLE",
+		"creationDate": created.Format(time.RFC3339),
+		"validBefore":  created.AddDate(1, 0, 0).Format(time.RFC3339),
+	}}
+	owner := wiz.GraphEntity{ID: "alice", Type: "USER_ACCOUNT"}
+
+	tests := []struct {
+		name     string
+		node     wiz.GraphSearchNode
+		display  string
+		identity string
+		next     string
+		pageInfo wiz.PageInfo
+	}{
+		{
+			name:     "keys are linked to their owner",
+			node:     wiz.GraphSearchNode{Entities: []wiz.GraphEntity{key, owner}},
+			display:  "AKIAEXAMPLE",
+			identity: "alice",
+		},
+		{
+			name:    "keys without an owner are not linked",
+			node:    wiz.GraphSearchNode{Entities: []wiz.GraphEntity{key}},
+			display: "AKIAEXAMPLE",
+		},
+		{
+			name:     "a next page without a cursor ends paging",
+			node:     wiz.GraphSearchNode{Entities: []wiz.GraphEntity{key}},
+			display:  "AKIAEXAMPLE",
+			pageInfo: wiz.PageInfo{HasNextPage: true},
+		},
+		{
+			name:     "the next page is returned as the page token",
+			node:     wiz.GraphSearchNode{Entities: []wiz.GraphEntity{key}},
+			display:  "AKIAEXAMPLE",
+			pageInfo: wiz.PageInfo{HasNextPage: true, EndCursor: "p2"},
+			next:     "p2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &accessKeysClient{resp: &wiz.GraphSearchConnection{
+				Nodes:    []wiz.GraphSearchNode{tt.node},
+				PageInfo: tt.pageInfo,
+			}}
+			builder := newCredentialBuilder(client)
+
+			resources, results, err := builder.List(ctx, nil, resource.SyncOpAttrs{PageToken: pagination.Token{}})
+			require.NoError(t, err)
+			require.Len(t, resources, 1)
+			assert.Equal(t, tt.next, results.NextPageToken)
+
+			r := resources[0]
+			assert.Equal(t, tt.display, r.GetDisplayName())
+
+			trait := &v2.SecretTrait{}
+			annos := annotations.Annotations(r.GetAnnotations())
+			ok, err := annos.Pick(trait)
+			require.NoError(t, err)
+			require.True(t, ok)
+			assert.Equal(t, created, trait.GetCreatedAt().AsTime())
+			assert.Equal(t, created.AddDate(1, 0, 0), trait.GetExpiresAt().AsTime())
+			assert.Equal(t, tt.identity, trait.GetIdentityId().GetResource())
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const detectionsEventFeedID = "wiz_detections_feed"
+
+// detectionsEventFeed implements connectorbuilder.EventFeed by polling Wiz
+// Defend threat detections whose primary actor is a principal. Each detection
+// is reported as a USAGE event with the principal as the actor.
+type detectionsEventFeed struct {
+	connector *Connector
+}
+
+func newDetectionsEventFeed(connector *Connector) *detectionsEventFeed {
+	return &detectionsEventFeed{connector: connector}
+}
+
+func (e *detectionsEventFeed) EventFeedMetadata(_ context.Context) *v2.EventFeedMetadata {
+	return v2.EventFeedMetadata_builder{
+		Id: detectionsEventFeedID,
+		SupportedEventTypes: []v2.EventType{
+			v2.EventType_EVENT_TYPE_USAGE,
+		},
+	}.Build()
+}
+
+func (e *detectionsEventFeed) ListEvents(
+	ctx context.Context,
+	earliestEvent *timestamppb.Timestamp,
+	pToken *pagination.StreamToken,
+) ([]*v2.Event, *pagination.StreamState, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	cursor, annos := decodeStreamCursor[feedCursor](ctx, pToken, earliestEvent, e.connector.cursorOptions)
+
+	l.Debug("wiz-detections-feed: querying detections",
+		zap.String("since", cursor.Since.Format(time.RFC3339)),
+		zap.String("page_cursor", cursor.PageEndCursor))
+
+	var pageCursor *string
+	if cursor.PageEndCursor != "" {
+		pageCursor = &cursor.PageEndCursor
+	}
+
+	detectionsResp, err := e.connector.client.ListDetectionsSince(ctx, cursor.Since, pageCursor)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var events []*v2.Event
+	for _, detection := range detectionsResp.Nodes {
+		if detection.CreatedAt.After(cursor.LatestSeen) {
+			cursor.LatestSeen = detection.CreatedAt
+		}
+
+		// Skip detections whose actor is not a principal; the API filter should
+		// already exclude them, but the actor is required to build the event.
+		if detection.PrimaryActor == nil || !wiz.IsPrincipalEntityType(detection.PrimaryActor.Type) {
+			continue
+		}
+
+		event, err := newDetectionEvent(detection, e.cloudResourceTypes())
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		events = append(events, event)
+	}
+
+	// If EndCursor is empty despite HasNextPage, stop paginating to avoid an infinite loop.
+	hasMore := detectionsResp.PageInfo.HasNextPage && detectionsResp.PageInfo.EndCursor != ""
+	if hasMore {
+		cursor.PageEndCursor = detectionsResp.PageInfo.EndCursor
+	} else {
+		cursor.Since = cursor.LatestSeen
+		cursor.PageEndCursor = ""
+	}
+
+	nextCursor, err := encodeStreamCursor(cursor)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	l.Debug("wiz-detections-feed: processed detections",
+		zap.Int("count", len(events)),
+		zap.Bool("has_more", hasMore))
+
+	return events, &pagination.StreamState{Cursor: nextCursor, HasMore: hasMore}, annos, nil
+}
+
+// cloudResourceTypes returns the Wiz entity types synced as cloud resources,
+// or nil when effective access is not synced.
+func (e *detectionsEventFeed) cloudResourceTypes() []string {
+	if !e.connector.syncEffectiveAccess {
+		return nil
+	}
+	return e.connector.effectiveAccessResourceTypes
+}
+
+// newDetectionEvent builds a USAGE event for a detection. The actor is the
+// principal named by the detection and the target is the resource it acted on
+// when it is of one of the synced cloud resource types, or the principal
+// itself otherwise. The detection rule, severity, MITRE techniques and the
+// resource acted on are carried as a security insight trait and a details
+// struct in the event annotations.
+func newDetectionEvent(detection wiz.Detection, cloudResourceTypes []string) (*v2.Event, error) {
+	actor := *detection.PrimaryActor
+
+	actorResource, err := newIdentityResource(actor)
+	if err != nil {
+		return nil, err
+	}
+
+	targetResource := actorResource
+	if detection.PrimaryResource != nil && slices.Contains(cloudResourceTypes, detection.PrimaryResource.Type) {
+		targetResource, err = resource.NewResource(detection.PrimaryResource.Name, cloudResourceResourceType, detection.PrimaryResource.ID)
+		if err != nil {
+			return nil, fmt.Errorf("baton-wiz-insights: failed to create target resource for detection %s: %w", detection.ID, err)
+		}
+	}
+
+	insight, err := resource.NewSecurityInsightTrait(
+		resource.WithIssue(detection.Rule.Name),
+		resource.WithIssueSeverity(detection.Severity),
+		resource.WithInsightObservedAt(detection.CreatedAt),
+		resource.WithInsightAppUserTarget(actor.Name, principalExternalID(actor.ExternalID(), actor.ID)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("baton-wiz-insights: failed to create security insight for detection %s: %w", detection.ID, err)
+	}
+
+	techniqueIDs := make([]interface{}, 0, len(detection.MitreTechniques))
+	techniqueNames := make([]interface{}, 0, len(detection.MitreTechniques))
+	for _, technique := range detection.MitreTechniques {
+		techniqueIDs = append(techniqueIDs, technique.ID)
+		techniqueNames = append(techniqueNames, technique.Name)
+	}
+
+	var resourceDetails map[string]interface{}
+	if detection.PrimaryResource != nil {
+		resourceDetails = map[string]interface{}{
+			"id":          detection.PrimaryResource.ID,
+			"name":        detection.PrimaryResource.Name,
+			"type":        detection.PrimaryResource.Type,
+			"external_id": detection.PrimaryResource.ExternalID(),
+		}
+	}
+
+	details, err := structpb.NewStruct(map[string]interface{}{
+		"detection_id":          detection.ID,
+		"description":           detection.Description,
+		"severity":              detection.Severity,
+		"rule_id":               detection.Rule.ID,
+		"rule_name":             detection.Rule.Name,
+		"mitre_technique_ids":   techniqueIDs,
+		"mitre_technique_names": techniqueNames,
+		"actor_id":              actor.ID,
+		"actor_external_id":     principalExternalID(actor.ExternalID(), actor.ID),
+		"actor_type":            actor.Type,
+		"resource":              resourceDetails,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("baton-wiz-insights: failed to build details for detection %s: %w", detection.ID, err)
+	}
+
+	return v2.Event_builder{
+		Id:         fmt.Sprintf("detection-%s", detection.ID),
+		OccurredAt: timestamppb.New(detection.CreatedAt),
+		UsageEvent: v2.UsageEvent_builder{
+			TargetResource: targetResource,
+			ActorResource:  actorResource,
+		}.Build(),
+		Annotations: annotations.New(insight, details),
+	}.Build(), nil
+}
@@ -0,0 +1,150 @@
+package connector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// detectionsClient serves fixed pages of detections by cursor, and records the
+// cursors queried.
+type detectionsClient struct {
+	wiz.Client
+
+	pages   map[string]*wiz.DetectionConnection
+	cursors []string
+}
+
+func (c *detectionsClient) ListDetectionsSince(_ context.Context, _ time.Time, cursor *string) (*wiz.DetectionConnection, error) {
+	key := ""
+	if cursor != nil {
+		key = *cursor
+	}
+	c.cursors = append(c.cursors, key)
+	return c.pages[key], nil
+}
+
+func TestDetectionsEventFeedPaging(t *testing.T) {
+	ctx := context.Background()
+
+	at := time.Now().UTC().Add(-time.Hour)
+	alice := &wiz.GraphEntity{ID: "alice", Type: "USER_ACCOUNT"}
+	detection := func(id string, actor *wiz.GraphEntity) wiz.Detection {
+		return wiz.Detection{ID: id, CreatedAt: at, Severity: "HIGH", Rule: wiz.DetectionRule{Name: "Impossible travel"}, PrimaryActor: actor}
+	}
+
+	tests := []struct {
+		name    string
+		pages   map[string]*wiz.DetectionConnection
+		ids     []string
+		cursors []string
+	}{
+		{
+			name: "pages are followed until the last one",
+			pages: map[string]*wiz.DetectionConnection{
+				"":   {Nodes: []wiz.Detection{detection("d1", alice)}, PageInfo: wiz.PageInfo{HasNextPage: true, EndCursor: "p2"}},
+				"p2": {Nodes: []wiz.Detection{detection("d2", alice)}},
+			},
+			ids:     []string{"detection-d1", "detection-d2"},
+			cursors: []string{"", "p2"},
+		},
+		{
+			name: "a next page without a cursor ends paging",
+			pages: map[string]*wiz.DetectionConnection{
+				"": {Nodes: []wiz.Detection{detection("d1", alice)}, PageInfo: wiz.PageInfo{HasNextPage: true}},
+			},
+			ids:     []string{"detection-d1"},
+			cursors: []string{""},
+		},
+		{
+			name: "detections without a principal actor are skipped",
+			pages: map[string]*wiz.DetectionConnection{
+				"": {Nodes: []wiz.Detection{
+					detection("d1", nil),
+					detection("d2", &wiz.GraphEntity{ID: "vm", Type: "VIRTUAL_MACHINE"}),
+					detection("d3", alice),
+				}},
+			},
+			ids:     []string{"detection-d3"},
+			cursors: []string{""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &detectionsClient{pages: tt.pages}
+			feed := newDetectionsEventFeed(&Connector{client: client})
+
+			var ids []string
+			token := &pagination.StreamToken{}
+			for range 10 {
+				events, state, _, err := feed.ListEvents(ctx, timestamppb.New(at.Add(-time.Hour)), token)
+				require.NoError(t, err)
+				for _, event := range events {
+					ids = append(ids, event.GetId())
+				}
+				if !state.HasMore {
+					break
+				}
+				token = &pagination.StreamToken{Cursor: state.Cursor}
+			}
+
+			assert.Equal(t, tt.ids, ids)
+			assert.Equal(t, tt.cursors, client.cursors)
+		})
+	}
+}
+
+func TestNewDetectionEventTarget(t *testing.T) {
+	alice := &wiz.GraphEntity{ID: "alice", Type: "USER_ACCOUNT"}
+	bucket := &wiz.GraphEntity{ID: "logs", Name: "logs", Type: "BUCKET"}
+
+	tests := []struct {
+		name          string
+		resource      *wiz.GraphEntity
+		resourceTypes []string
+		targetType    string
+		target        string
+	}{
+		{
+			name:       "no resource targets the actor",
+			targetType: identityResourceType.GetId(),
+			target:     "alice",
+		},
+		{
+			name:       "resources are not targeted without effective access",
+			resource:   bucket,
+			targetType: identityResourceType.GetId(),
+			target:     "alice",
+		},
+		{
+			name:          "resources of unsynced types are not targeted",
+			resource:      bucket,
+			resourceTypes: []string{"ENCRYPTION_KEY"},
+			targetType:    identityResourceType.GetId(),
+			target:        "alice",
+		},
+		{
+			name:          "resources of synced types are targeted",
+			resource:      bucket,
+			resourceTypes: []string{"BUCKET"},
+			targetType:    cloudResourceResourceType.GetId(),
+			target:        "logs",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := newDetectionEvent(wiz.Detection{ID: "d1", Rule: wiz.DetectionRule{Name: "Impossible travel"}, PrimaryActor: alice, PrimaryResource: tt.resource}, tt.resourceTypes)
+			require.NoError(t, err)
+
+			target := event.GetUsageEvent().GetTargetResource().GetId()
+			assert.Equal(t, tt.targetType, target.GetResourceType())
+			assert.Equal(t, tt.target, target.GetResource())
+		})
+	}
+}
@@ -8,13 +8,25 @@ import (
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
 	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
 	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/structpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 const eventFeedID = "wiz_issues_feed"
 
+// webhookDrainBatch is the number of buffered webhook notifications emitted per call.
+const webhookDrainBatch = 100
+
+// Kinds of issue change reported by the issues event feed.
+const (
+	issueChangeCreated       = "created"
+	issueChangeStatusChanged = "status-changed"
+	issueChangeResolved      = "resolved"
+)
+
 // issuesEventFeed implements connectorbuilder.EventFeed by polling
 // the issuesV2 GraphQL query filtered by statusChangedAt to only
 // return issues modified since the last check.
@@ -42,60 +54,133 @@ func (e *issuesEventFeed) ListEvents(
 ) ([]*v2.Event, *pagination.StreamState, annotations.Annotations, error) {
 	l := ctxzap.Extract(ctx)
 
+	// The webhook listener only runs while the feed is being listed, in service
+	// mode or an on-demand event feed, and not during a one-shot sync.
+	if err := e.connector.startWebhook(); err != nil {
+		return nil, nil, nil, err
+	}
+
 	// Decode cursor from the stream token. On first call, cursor is empty
 	// and we use earliestEvent as the start time.
-	cursor, err := decodeEventCursor(pToken, earliestEvent)
-	if err != nil {
-		return nil, nil, nil, err
+	cursor, annos := decodeEventCursor(ctx, pToken, earliestEvent, e.connector.cursorOptions)
+	if pToken == nil || pToken.Cursor == "" {
+		cursor.startBackfill(time.Now(), e.connector.backfillSlice)
+	}
+
+	// With webhooks enabled, emit buffered notifications between sweeps and
+	// only poll periodically to reconcile notifications that never arrived.
+	if e.connector.webhookBuffer != nil && cursor.PageEndCursor == "" {
+		events, hasMore, err := e.drainWebhook(ctx, cursor)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if len(events) > 0 || time.Since(cursor.LastPolled) < e.connector.webhookReconcile {
+			nextCursor, err := encodeStreamCursor(cursor)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			l.Debug("wiz-event-feed: processed webhook notifications",
+				zap.Int("count", len(events)),
+				zap.Bool("has_more", hasMore))
+			return events, &pagination.StreamState{Cursor: nextCursor, HasMore: hasMore}, annos, nil
+		}
+	}
+
+	// Before a sweep, periodically prune insights that left the synced scope.
+	if e.reconcileDue(cursor) {
+		events, err := e.reconcile(ctx, cursor)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if len(events) > 0 {
+			nextCursor, err := encodeStreamCursor(cursor)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			return events, &pagination.StreamState{Cursor: nextCursor, HasMore: true}, annos, nil
+		}
 	}
 
+	cursor.startSweep()
+	cursor.sliceWindow(time.Now())
+
 	l.Debug("wiz-event-feed: querying issues",
 		zap.String("since", cursor.Since.Format(time.RFC3339)),
+		zap.String("window_start", cursor.WindowStart.Format(time.RFC3339)),
+		zap.Time("until", cursor.Until),
+		zap.Duration("window_size", cursor.WindowSize),
 		zap.String("page_cursor", cursor.PageEndCursor))
 
-	// Query issuesV2 filtered by statusChangedAt >= cursor.Since
+	// Query issuesV2 filtered by statusChangedAt from the start of the sweep's
+	// window, which overlaps the previous sweep.
 	var pageCursor *string
 	if cursor.PageEndCursor != "" {
 		pageCursor = &cursor.PageEndCursor
 	}
 
-	issuesResp, err := e.connector.client.ListIssuesSince(ctx, cursor.Since, pageCursor)
+	var changesResp *wiz.IssueChangeConnection
+	var err error
+	if cursor.Until.IsZero() {
+		changesResp, err = e.connector.client.ListIssueChangesSince(ctx, cursor.WindowStart, pageCursor)
+	} else {
+		changesResp, err = e.connector.client.ListIssueChangesBetween(ctx, cursor.WindowStart, cursor.Until, pageCursor)
+	}
 	if err != nil {
 		return nil, nil, nil, err
 	}
 
-	// Convert each issue to a RESOURCE_CHANGE event
-	var events []*v2.Event
-	for _, issue := range issuesResp.Nodes {
-		event := v2.Event_builder{
-			Id:         fmt.Sprintf("issue-change-%s-%s", issue.StatusChangedAt.Format(time.RFC3339Nano), issue.ID),
-			OccurredAt: timestamppb.New(issue.StatusChangedAt),
-			ResourceChangeEvent: v2.ResourceChangeEvent_builder{
-				ResourceId: v2.ResourceId_builder{
-					ResourceType: issueResourceType.GetId(),
-					Resource:     issue.ID,
-				}.Build(),
-			}.Build(),
-		}.Build()
-		events = append(events, event)
+	// Keep the changes not emitted yet, then fetch the details of only those
+	// issues for the event payloads.
+	var changes []wiz.IssueChange
+	var previousStatuses []string
+	var ids []string
+	for _, change := range changesResp.Nodes {
+		if !cursor.observe(change.ID, change.StatusChangedAt) {
+			continue
+		}
+
+		changes = append(changes, change)
+		previousStatuses = append(previousStatuses, cursor.recordStatus(change.ID, change.Status))
+		ids = append(ids, change.ID)
+	}
+
+	details, err := e.issueDetails(ctx, ids)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 
-		// Track the latest statusChangedAt we've seen
-		if issue.StatusChangedAt.After(cursor.LatestSeen) {
-			cursor.LatestSeen = issue.StatusChangedAt
+	// Convert each change to a RESOURCE_CHANGE event
+	var events []*v2.Event
+	for n, change := range changes {
+		event, err := e.issueEvent(hydrateIssueChange(change, details), previousStatuses[n])
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if event != nil {
+			events = append(events, event)
 		}
 	}
 
 	// Build next cursor
-	hasMore := issuesResp.PageInfo.HasNextPage
-	if hasMore {
-		cursor.PageEndCursor = issuesResp.PageInfo.EndCursor
-	} else {
-		// Done with this sweep. Next call starts from the latest timestamp we saw.
-		cursor.Since = cursor.LatestSeen
-		cursor.PageEndCursor = ""
+	hasMore := cursor.advance(changesResp.PageInfo.HasNextPage, changesResp.PageInfo.EndCursor)
+	if !hasMore {
+		cursor.LastPolled = time.Now()
 	}
 
-	nextCursor, err := cursor.encode()
+	if cursor.backfilling() {
+		progress := cursor.backfillProgress()
+		l.Info("wiz-event-feed: backfilling issue events",
+			zap.String("position", cursor.Since.Format(time.RFC3339)),
+			zap.String("backfill_end", cursor.BackfillEnd.Format(time.RFC3339)),
+			zap.Float64("progress", progress))
+		annos.Append(&structpb.Struct{Fields: map[string]*structpb.Value{
+			"backfill_position": structpb.NewStringValue(cursor.Since.Format(time.RFC3339)),
+			"backfill_end":      structpb.NewStringValue(cursor.BackfillEnd.Format(time.RFC3339)),
+			"backfill_progress": structpb.NewNumberValue(progress),
+		}})
+	}
+
+	nextCursor, err := encodeStreamCursor(cursor)
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -109,5 +194,159 @@ func (e *issuesEventFeed) ListEvents(
 		zap.Int("count", len(events)),
 		zap.Bool("has_more", hasMore))
 
-	return events, streamState, nil, nil
+	return events, streamState, annos, nil
+}
+
+// drainWebhook emits buffered webhook notifications after the cursor's
+// position. Notifications the cursor has already moved past were acknowledged
+// when the cursor was handed back to the feed, so they are removed first.
+func (e *issuesEventFeed) drainWebhook(ctx context.Context, cursor *eventCursor) ([]*v2.Event, bool, error) {
+	buffer := e.connector.webhookBuffer
+
+	if err := buffer.Compact(cursor.WebhookSeq); err != nil {
+		// Compaction only reclaims space; the cursor still skips acknowledged notifications.
+		ctxzap.Extract(ctx).Warn("wiz-event-feed: failed to compact webhook buffer", zap.Error(err))
+	}
+
+	notifications, err := buffer.ReadAfter(cursor.WebhookSeq, webhookDrainBatch+1)
+	if err != nil {
+		return nil, false, fmt.Errorf("baton-wiz-insights: failed to read webhook buffer: %w", err)
+	}
+	hasMore := len(notifications) > webhookDrainBatch
+	if hasMore {
+		notifications = notifications[:webhookDrainBatch]
+	}
+
+	// Keep the changes not emitted yet, by polling or an earlier notification.
+	var changes []wiz.IssueChange
+	var previousStatuses []string
+	var ids []string
+	for _, n := range notifications {
+		cursor.WebhookSeq = n.Seq
+		if !cursor.markNotified(n.Issue.ID, n.Issue.StatusChangedAt) {
+			continue
+		}
+
+		changes = append(changes, wiz.IssueChange{
+			ID:              n.Issue.ID,
+			Status:          n.Issue.Status,
+			CreatedAt:       n.Issue.CreatedAt,
+			StatusChangedAt: n.Issue.StatusChangedAt,
+		})
+		previousStatuses = append(previousStatuses, cursor.recordStatus(n.Issue.ID, n.Issue.Status))
+		ids = append(ids, n.Issue.ID)
+	}
+
+	// Notifications carry whatever the automation rule's template renders, so
+	// the issues are fetched like polled changes. This applies the same
+	// principal filter, and gives the configured filters the entity tags and
+	// framework mappings to select issues by.
+	details, err := e.issueDetails(ctx, ids)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var events []*v2.Event
+	for n, change := range changes {
+		event, err := e.issueEvent(hydrateIssueChange(change, details), previousStatuses[n])
+		if err != nil {
+			return nil, false, err
+		}
+		if event != nil {
+			events = append(events, event)
+		}
+	}
+
+	return events, hasMore, nil
+}
+
+// issueDetails fetches the details of changed issues by ID. Issues that no
+// longer exist or are not related to a principal are left out.
+func (e *issuesEventFeed) issueDetails(ctx context.Context, ids []string) (map[string]wiz.Issue, error) {
+	details := make(map[string]wiz.Issue, len(ids))
+	if len(ids) == 0 {
+		return details, nil
+	}
+
+	issues, err := e.connector.client.GetIssues(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("baton-wiz-insights: failed to get details of changed issues: %w", err)
+	}
+	for _, issue := range issues {
+		details[issue.ID] = issue
+	}
+	return details, nil
+}
+
+// issueEvent builds the event for an issue change, applying the sync's
+// principal, status and filters first. Issues without details no longer exist
+// or are not related to a principal. Issues the filters select but whose
+// status is not synced are reported as removed. Other issues are skipped, so
+// the event is nil; if one of them had an insight that left the filters'
+// scope, reconciliation removes it.
+func (e *issuesEventFeed) issueEvent(issue wiz.Issue, previousStatus string) (*v2.Event, error) {
+	opts := e.connector.issueSync
+	if !wiz.IsPrincipalIssue(issue) || !opts.Filter.matches(issue) {
+		return nil, nil
+	}
+	return newIssueEvent(issue, previousStatus, opts.syncsStatus(issue.Status))
+}
+
+// hydrateIssueChange returns the issue a change is about, with its details if
+// they could be fetched. The change itself is authoritative for the status
+// and its timing, since the issue may have changed again since.
+func hydrateIssueChange(change wiz.IssueChange, details map[string]wiz.Issue) wiz.Issue {
+	issue := details[change.ID]
+	issue.ID = change.ID
+	issue.Status = change.Status
+	issue.CreatedAt = change.CreatedAt
+	issue.StatusChangedAt = change.StatusChangedAt
+	return issue
+}
+
+// newIssueEvent builds a RESOURCE_CHANGE event for an issue status change. The
+// kind of change and the previous and new status are carried in a details
+// struct annotation. Issues that are not synced, such as resolved issues when
+// only active issues are synced or issues the filters no longer select, have
+// left the synced scope and are marked as no longer existing.
+func newIssueEvent(issue wiz.Issue, previousStatus string, synced bool) (*v2.Event, error) {
+	var annos annotations.Annotations
+	if !synced {
+		annos.Update(&v2.ResourceDoesNotExist{})
+	}
+
+	kind := issueChangeStatusChanged
+	switch {
+	case !wiz.IsActiveIssueStatus(issue.Status):
+		kind = issueChangeResolved
+	case previousStatus == "" && !issue.StatusChangedAt.After(issue.CreatedAt):
+		// The status has not changed since the issue was created.
+		kind = issueChangeCreated
+	}
+
+	details, err := structpb.NewStruct(map[string]interface{}{
+		"change":          kind,
+		"previous_status": previousStatus,
+		"status":          issue.Status,
+		"severity":        issue.Severity,
+		"rule_id":         issue.SourceRule.ID,
+		"rule_name":       issue.SourceRule.Name,
+		"created_at":      issue.CreatedAt.Format(time.RFC3339),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("baton-wiz-insights: failed to build details for issue %s: %w", issue.ID, err)
+	}
+	annos.Update(details)
+
+	return v2.Event_builder{
+		Id:         fmt.Sprintf("issue-%s-%s-%s", kind, issue.StatusChangedAt.Format(time.RFC3339Nano), issue.ID),
+		OccurredAt: timestamppb.New(issue.StatusChangedAt),
+		ResourceChangeEvent: v2.ResourceChangeEvent_builder{
+			ResourceId: v2.ResourceId_builder{
+				ResourceType: issueResourceType.GetId(),
+				Resource:     issue.ID,
+			}.Build(),
+		}.Build(),
+		Annotations: annos,
+	}.Build(), nil
 }
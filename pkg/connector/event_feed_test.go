@@ -0,0 +1,488 @@
+package connector
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-wiz-insights/pkg/webhook"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestIssuesEventFeedDrainsWebhookBuffer(t *testing.T) {
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	buffer, err := webhook.OpenBuffer(dir)
+	require.NoError(t, err)
+
+	changedAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, issue := range []wiz.Issue{
+		{ID: "a", Status: wiz.IssueStatusOpen, CreatedAt: changedAt, StatusChangedAt: changedAt},
+		{ID: "a", Status: wiz.IssueStatusOpen, CreatedAt: changedAt, StatusChangedAt: changedAt},
+		{ID: "not-principal", Status: wiz.IssueStatusOpen, CreatedAt: changedAt, StatusChangedAt: changedAt},
+		{ID: "dev", Status: wiz.IssueStatusOpen, CreatedAt: changedAt, StatusChangedAt: changedAt},
+		{ID: "a", Status: wiz.IssueStatusResolved, CreatedAt: changedAt, StatusChangedAt: changedAt.Add(time.Hour)},
+	} {
+		_, err := buffer.Append(webhook.Notification{Issue: issue})
+		require.NoError(t, err)
+	}
+
+	// The client only serves issue details, so the test fails if the feed polls.
+	client := &detailsClient{issues: map[string]wiz.Issue{
+		"a":   taggedIssue("a", map[string]string{"env": "prod"}),
+		"dev": taggedIssue("dev", map[string]string{"env": "dev"}),
+	}}
+	feed := newIssuesEventFeed(&Connector{
+		client:           client,
+		issueSync:        issueSyncOptions{Filter: issueFilter{ExcludeTags: []string{"env=dev"}}},
+		webhookBuffer:    buffer,
+		webhookReconcile: time.Hour,
+	})
+
+	cursor := newEventCursor(changedAt)
+	cursor.LastPolled = time.Now()
+	token, err := encodeStreamCursor(cursor)
+	require.NoError(t, err)
+
+	events, state, _, err := feed.ListEvents(ctx, nil, &pagination.StreamToken{Cursor: token})
+	require.NoError(t, err)
+	require.False(t, state.HasMore)
+
+	// The duplicate notification is emitted once, and the resolution carries
+	// the previous status. Issues that are not related to a principal or that
+	// the filters do not select are skipped, like polled changes.
+	require.Len(t, events, 2)
+	assert.Equal(t, "issue-created-2025-01-01T12:00:00Z-a", events[0].GetId())
+	assert.Equal(t, "issue-resolved-2025-01-01T13:00:00Z-a", events[1].GetId())
+
+	next, annos := decodeEventCursor(ctx, &pagination.StreamToken{Cursor: state.Cursor}, nil, eventCursorOptions{})
+	require.Empty(t, annos)
+	assert.Equal(t, changedAt, next.Since, "webhook notifications must not move the polling watermark")
+	assert.Equal(t, "RESOLVED", next.Statuses[len(next.Statuses)-1].Status)
+
+	// Handing the cursor back acknowledges the drained notifications, which
+	// are then removed from the buffer, and nothing new is emitted.
+	events, _, _, err = feed.ListEvents(ctx, nil, &pagination.StreamToken{Cursor: state.Cursor})
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	reopened, err := webhook.OpenBuffer(dir)
+	require.NoError(t, err)
+	remaining, err := reopened.ReadAfter(0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, remaining)
+}
+
+func TestIssuesEventFeedReconcilesHistoryPastManyNotifications(t *testing.T) {
+	ctx := context.Background()
+
+	buffer, err := webhook.OpenBuffer(t.TempDir())
+	require.NoError(t, err)
+
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	notifiedAt := start.Add(time.Hour)
+	client := &changesClient{issues: map[string]wiz.Issue{"missed": mappedIssue("missed")}}
+	for n := range maxEmittedKeys + 50 {
+		id := fmt.Sprintf("n%d", n)
+		at := notifiedAt.Add(time.Duration(n) * time.Second)
+		_, err := buffer.Append(webhook.Notification{Issue: wiz.Issue{ID: id, Status: wiz.IssueStatusOpen, CreatedAt: at, StatusChangedAt: at}})
+		require.NoError(t, err)
+		client.issues[id] = mappedIssue(id)
+	}
+
+	// The webhook never delivered a change older than every notification.
+	client.changes = []wiz.IssueChange{
+		{ID: "missed", Status: wiz.IssueStatusOpen, CreatedAt: start, StatusChangedAt: start.Add(time.Minute)},
+	}
+	feed := newIssuesEventFeed(&Connector{
+		client:           client,
+		webhookBuffer:    buffer,
+		webhookReconcile: time.Hour,
+	})
+
+	cursor := newEventCursor(start)
+	cursor.LastPolled = time.Now()
+	token, err := encodeStreamCursor(cursor)
+	require.NoError(t, err)
+
+	notified := 0
+	for range 20 {
+		events, state, _, err := feed.ListEvents(ctx, nil, &pagination.StreamToken{Cursor: token})
+		require.NoError(t, err)
+		notified += len(events)
+		token = state.Cursor
+		if !state.HasMore {
+			break
+		}
+	}
+	assert.Equal(t, maxEmittedKeys+50, notified)
+
+	// Once reconciliation is due, polling still sweeps from the watermark.
+	cursor, _ = decodeEventCursor(ctx, &pagination.StreamToken{Cursor: token}, nil, eventCursorOptions{})
+	assert.Equal(t, start, cursor.Floor, "webhook notifications must not raise the floor")
+	cursor.LastPolled = time.Time{}
+	token, err = encodeStreamCursor(cursor)
+	require.NoError(t, err)
+
+	events, _, _, err := feed.ListEvents(ctx, nil, &pagination.StreamToken{Cursor: token})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "issue-status-changed-2025-01-01T12:01:00Z-missed", events[0].GetId())
+}
+
+func TestConnectorStartsWebhookListenerOnDemand(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	buffer, err := webhook.OpenBuffer(t.TempDir())
+	require.NoError(t, err)
+	server, err := webhook.NewServer(addr, "secret", buffer, zap.NewNop())
+	require.NoError(t, err)
+	c := &Connector{webhookBuffer: buffer, webhookServer: server}
+
+	// The listener is not open until the event feed starts it, once.
+	_, err = net.Dial("tcp", addr)
+	require.Error(t, err)
+	require.NoError(t, c.startWebhook())
+	require.NoError(t, c.startWebhook())
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	require.NoError(t, c.Close())
+	_, err = buffer.Append(webhook.Notification{})
+	assert.ErrorIs(t, err, webhook.ErrBufferClosed)
+}
+
+// detailsClient only serves the details of issues.
+type detailsClient struct {
+	wiz.Client
+
+	issues map[string]wiz.Issue
+}
+
+func (c *detailsClient) GetIssues(_ context.Context, ids []string) ([]wiz.Issue, error) {
+	var issues []wiz.Issue
+	for _, id := range ids {
+		if issue, ok := c.issues[id]; ok {
+			issues = append(issues, issue)
+		}
+	}
+	return issues, nil
+}
+
+// changesClient serves one page of issue changes and the details of issues.
+type changesClient struct {
+	wiz.Client
+
+	changes  []wiz.IssueChange
+	issues   map[string]wiz.Issue
+	hydrated [][]string
+}
+
+func (c *changesClient) ListIssueChangesSince(_ context.Context, _ time.Time, _ *string) (*wiz.IssueChangeConnection, error) {
+	return &wiz.IssueChangeConnection{Nodes: c.changes}, nil
+}
+
+func (c *changesClient) ListIssueChangesBetween(_ context.Context, _, _ time.Time, _ *string) (*wiz.IssueChangeConnection, error) {
+	return &wiz.IssueChangeConnection{Nodes: c.changes}, nil
+}
+
+func (c *changesClient) GetIssues(_ context.Context, ids []string) ([]wiz.Issue, error) {
+	c.hydrated = append(c.hydrated, ids)
+	var issues []wiz.Issue
+	for _, id := range ids {
+		if issue, ok := c.issues[id]; ok {
+			issues = append(issues, issue)
+		}
+	}
+	return issues, nil
+}
+
+func TestIssuesEventFeedHydratesChangedIssues(t *testing.T) {
+	ctx := context.Background()
+
+	changedAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	client := &changesClient{
+		changes: []wiz.IssueChange{
+			{ID: "a", Status: wiz.IssueStatusOpen, CreatedAt: changedAt, StatusChangedAt: changedAt},
+			{ID: "b", Status: wiz.IssueStatusResolved, CreatedAt: changedAt, StatusChangedAt: changedAt.Add(time.Minute)},
+		},
+		issues: map[string]wiz.Issue{
+			// The details are fetched after the issue changed again.
+			"a": {
+				ID: "a", Status: wiz.IssueStatusResolved, Severity: "HIGH",
+				SourceRule:     wiz.SourceRule{ID: "rule-1", Name: "User without MFA"},
+				EntitySnapshot: wiz.EntitySnapshot{ID: "alice", Type: "USER_ACCOUNT"},
+			},
+		},
+	}
+	feed := newIssuesEventFeed(&Connector{client: client})
+
+	cursor := newEventCursor(changedAt.Add(-time.Hour))
+	cursor.markEmitted("b", changedAt.Add(time.Minute))
+	token, err := encodeStreamCursor(cursor)
+	require.NoError(t, err)
+
+	events, _, _, err := feed.ListEvents(ctx, nil, &pagination.StreamToken{Cursor: token})
+	require.NoError(t, err)
+
+	// Only the issue not emitted yet is hydrated, and the change is
+	// authoritative for its status.
+	assert.Equal(t, [][]string{{"a"}}, client.hydrated)
+	require.Len(t, events, 1)
+	assert.Equal(t, "issue-created-2025-01-01T12:00:00Z-a", events[0].GetId())
+
+	details := &structpb.Struct{}
+	annos := annotations.Annotations(events[0].GetAnnotations())
+	ok, err := annos.Pick(details)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "OPEN", details.GetFields()["status"].GetStringValue())
+	assert.Equal(t, "User without MFA", details.GetFields()["rule_name"].GetStringValue())
+}
+
+func TestIssuesEventFeedPrunesEmittedIssues(t *testing.T) {
+	ctx := context.Background()
+
+	changedAt := time.Now().Add(-2 * time.Hour).Truncate(time.Second).UTC()
+	change := func(id, issueStatus string) wiz.IssueChange {
+		return wiz.IssueChange{ID: id, Status: issueStatus, CreatedAt: changedAt.Add(-time.Hour), StatusChangedAt: changedAt}
+	}
+	client := &changesClient{
+		changes: []wiz.IssueChange{
+			change("a", wiz.IssueStatusOpen),
+			change("b", wiz.IssueStatusOpen),
+			change("c", wiz.IssueStatusOpen),
+			change("d", wiz.IssueStatusInProgress),
+			change("e", wiz.IssueStatusResolved),
+		},
+		issues: map[string]wiz.Issue{
+			"a": taggedIssue("a", map[string]string{"env": "prod"}),
+			"c": taggedIssue("c", map[string]string{"env": "dev"}),
+			"d": {ID: "d", Status: wiz.IssueStatusResolved},
+		},
+	}
+	feed := newIssuesEventFeed(&Connector{
+		client:           client,
+		insightReconcile: time.Hour,
+		issueSync:        issueSyncOptions{ActiveOnly: true, Filter: issueFilter{ExcludeTags: []string{"env=dev"}}},
+	})
+
+	cursor := newEventCursor(time.Now().Add(-time.Hour))
+	token, err := encodeStreamCursor(cursor)
+	require.NoError(t, err)
+
+	events, state, _, err := feed.ListEvents(ctx, nil, &pagination.StreamToken{Cursor: token})
+	require.NoError(t, err)
+	assert.True(t, state.HasMore)
+
+	// Only issues whose last change was emitted as a synced insight are
+	// checked, and the ones that were deleted, resolved unseen, or filtered
+	// out are removed.
+	assert.Equal(t, [][]string{{"a", "b", "c", "d"}}, client.hydrated)
+	var reasons []string
+	for _, event := range events {
+		annos := annotations.Annotations(event.GetAnnotations())
+		assert.True(t, annos.Contains(&v2.ResourceDoesNotExist{}))
+		details := &structpb.Struct{}
+		_, err := annos.Pick(details)
+		require.NoError(t, err)
+		reasons = append(reasons, event.GetResourceChangeEvent().GetResourceId().GetResource()+":"+details.GetFields()["reason"].GetStringValue())
+	}
+	assert.Equal(t, []string{"b:not_found", "c:out_of_scope", "d:inactive"}, reasons)
+
+	// The check is complete, and the next call polls instead.
+	next, _ := decodeEventCursor(ctx, &pagination.StreamToken{Cursor: state.Cursor}, nil, eventCursorOptions{})
+	assert.True(t, next.ReconcileUntil.IsZero())
+	assert.False(t, next.LastReconciled.IsZero())
+	assert.False(t, feed.reconcileDue(next))
+
+	// Checking the same changes again reports the same events.
+	again, err := feed.reconcile(ctx, next)
+	require.NoError(t, err)
+	require.Len(t, again, len(events))
+	for i := range events {
+		assert.Equal(t, events[i].GetId(), again[i].GetId())
+	}
+}
+
+// reconcileClient serves pages of the issue changes between two times, and the
+// details of issues.
+type reconcileClient struct {
+	wiz.Client
+
+	pages    [][]wiz.IssueChange
+	issues   map[string]wiz.Issue
+	windows  [][2]time.Time
+	hydrated [][]string
+}
+
+func (c *reconcileClient) ListIssueChangesBetween(_ context.Context, since, until time.Time, after *string) (*wiz.IssueChangeConnection, error) {
+	c.windows = append(c.windows, [2]time.Time{since, until})
+	page := 0
+	if after != nil {
+		page, _ = strconv.Atoi(*after)
+	}
+	resp := &wiz.IssueChangeConnection{Nodes: c.pages[page]}
+	if page+1 < len(c.pages) {
+		resp.PageInfo = wiz.PageInfo{HasNextPage: true, EndCursor: strconv.Itoa(page + 1)}
+	}
+	return resp, nil
+}
+
+func (c *reconcileClient) GetIssues(_ context.Context, ids []string) ([]wiz.Issue, error) {
+	c.hydrated = append(c.hydrated, ids)
+	var issues []wiz.Issue
+	for _, id := range ids {
+		if issue, ok := c.issues[id]; ok {
+			issues = append(issues, issue)
+		}
+	}
+	return issues, nil
+}
+
+func TestIssuesEventFeedReconcilesWindowBelowWatermark(t *testing.T) {
+	ctx := context.Background()
+
+	since := time.Now().Add(-time.Hour).Truncate(time.Second).UTC()
+	client := &reconcileClient{issues: map[string]wiz.Issue{}}
+	for page := 0; page < 3; page++ {
+		var changes []wiz.IssueChange
+		for i := 0; i < 4; i++ {
+			id := fmt.Sprintf("issue-%d-%d", page, i)
+			changes = append(changes, wiz.IssueChange{ID: id, Status: wiz.IssueStatusOpen, StatusChangedAt: since.Add(-time.Hour)})
+			if i%2 == 0 {
+				client.issues[id] = wiz.Issue{ID: id, Status: wiz.IssueStatusOpen}
+			}
+		}
+		client.pages = append(client.pages, changes)
+	}
+	feed := newIssuesEventFeed(&Connector{client: client, insightReconcile: time.Hour})
+
+	// The check pages through the changes below the watermark, one page per
+	// call, and the cursor only keeps its bounds.
+	cursor := newEventCursor(since)
+	var pruned int
+	for feed.reconcileDue(cursor) {
+		events, err := feed.reconcile(ctx, cursor)
+		require.NoError(t, err)
+		pruned += len(events)
+		cursor = roundTrip(t, cursor)
+	}
+
+	assert.Len(t, client.hydrated, 3)
+	assert.Equal(t, 6, pruned)
+	for _, window := range client.windows {
+		assert.Equal(t, [2]time.Time{since.Add(-insightReconcileWindow), since}, window)
+	}
+	assert.True(t, cursor.ReconcileFrom.IsZero())
+	assert.Empty(t, cursor.ReconcilePage)
+}
+
+func TestEventCursorMigrationDropsTrackedInsights(t *testing.T) {
+	cursor := newEventCursor(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	cursor.recordStatus("a", wiz.IssueStatusOpen)
+
+	data, err := json.Marshal(cursor)
+	require.NoError(t, err)
+	var raw map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &raw))
+	raw["version"] = 2
+	raw["insights"] = []string{"a"}
+	raw["reconcile_offset"] = 1
+	data, err = json.Marshal(raw)
+	require.NoError(t, err)
+
+	migrated, err := parseEventCursor(base64.StdEncoding.EncodeToString(data))
+	require.NoError(t, err)
+	assert.Equal(t, eventCursorVersion, migrated.Version)
+	assert.Equal(t, cursor.Since, migrated.Since)
+
+	token, err := encodeStreamCursor(migrated)
+	require.NoError(t, err)
+	data, err = base64.StdEncoding.DecodeString(token)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "insights")
+}
+
+func TestIssuesEventFeedAppliesFiltersBeforeEmitting(t *testing.T) {
+	ctx := context.Background()
+
+	changedAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	change := func(id, issueStatus string) wiz.IssueChange {
+		return wiz.IssueChange{ID: id, Status: issueStatus, CreatedAt: changedAt.Add(-time.Hour), StatusChangedAt: changedAt}
+	}
+	client := &changesClient{
+		changes: []wiz.IssueChange{
+			change("prod", wiz.IssueStatusOpen),
+			change("dev", wiz.IssueStatusOpen),
+			change("resolved-prod", wiz.IssueStatusResolved),
+		},
+		issues: map[string]wiz.Issue{
+			"prod":          taggedIssue("prod", map[string]string{"env": "prod"}),
+			"dev":           taggedIssue("dev", map[string]string{"env": "dev"}),
+			"resolved-prod": taggedIssue("resolved-prod", map[string]string{"env": "prod"}),
+		},
+	}
+	feed := newIssuesEventFeed(&Connector{
+		client:    client,
+		issueSync: issueSyncOptions{ActiveOnly: true, Filter: issueFilter{IncludeTags: []string{"env=prod"}}},
+	})
+
+	cursor := newEventCursor(changedAt.Add(-time.Hour))
+	token, err := encodeStreamCursor(cursor)
+	require.NoError(t, err)
+
+	events, _, _, err := feed.ListEvents(ctx, nil, &pagination.StreamToken{Cursor: token})
+	require.NoError(t, err)
+
+	// The issue the filters do not select is skipped, and the resolved issue is
+	// removed.
+	removed := map[string]bool{}
+	for _, event := range events {
+		annos := annotations.Annotations(event.GetAnnotations())
+		removed[event.GetResourceChangeEvent().GetResourceId().GetResource()] = annos.Contains(&v2.ResourceDoesNotExist{})
+	}
+	assert.Equal(t, map[string]bool{"prod": false, "resolved-prod": true}, removed)
+}
+
+func TestNewIssueEventRemovesUnsyncedIssues(t *testing.T) {
+	changedAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	resolved := wiz.Issue{ID: "a", Status: wiz.IssueStatusResolved, CreatedAt: changedAt, StatusChangedAt: changedAt.Add(time.Hour)}
+
+	tests := []struct {
+		name    string
+		opts    issueSyncOptions
+		removed bool
+	}{
+		{name: "every status synced", opts: issueSyncOptions{}, removed: false},
+		{name: "only active issues synced", opts: issueSyncOptions{ActiveOnly: true}, removed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := newIssueEvent(resolved, wiz.IssueStatusOpen, tt.opts.syncsStatus(resolved.Status))
+			require.NoError(t, err)
+			assert.Equal(t, "issue-resolved-2025-01-01T13:00:00Z-a", event.GetId())
+
+			annos := annotations.Annotations(event.GetAnnotations())
+			assert.Equal(t, tt.removed, annos.Contains(&v2.ResourceDoesNotExist{}))
+		})
+	}
+}
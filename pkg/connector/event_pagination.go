@@ -1,61 +1,575 @@
 package connector
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"time"
 
+	"github.com/conductorone/baton-sdk/pkg/annotations"
 	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-// eventCursor tracks where we are in the issues polling loop.
-type eventCursor struct {
-	// Since is the statusChangedAt lower bound for the current query window.
+const (
+	// eventOverlapWindow is how far before the watermark each sweep starts
+	// querying, so changes that share the watermark timestamp or that commit
+	// late on Wiz's side are still picked up.
+	eventOverlapWindow = 5 * time.Minute
+
+	// eventCursorVersion is the current schema version of the event feed
+	// cursors. Bump it and register a migration in eventCursorMigrations
+	// whenever the meaning of an existing field changes or a new field needs a
+	// non-zero value.
+	eventCursorVersion = 3
+
+	// maxEmittedKeys bounds the number of already-emitted changes kept in the
+	// cursor to deduplicate the overlap window.
+	maxEmittedKeys = 1000
+
+	// defaultEventWindow is the initial size of a sweep's time window.
+	defaultEventWindow = 24 * time.Hour
+
+	// minEventWindow and maxEventWindow bound the adaptive window size.
+	minEventWindow = time.Minute
+	maxEventWindow = 7 * 24 * time.Hour
+
+	// eventWindowHighVolume and eventWindowLowVolume are the number of changes
+	// in a window above which the next window is halved, and below which it is
+	// doubled, keeping each window to a few pages of results.
+	eventWindowHighVolume = 2000
+	eventWindowLowVolume  = 250
+
+	// maxTrackedStatuses bounds the number of issue statuses kept in the cursor
+	// to report the status an issue changed from.
+	maxTrackedStatuses = 1000
+)
+
+// emittedKey identifies a single status change that has already been emitted.
+type emittedKey struct {
+	ID              string    `json:"id"`
+	StatusChangedAt time.Time `json:"status_changed_at"`
+}
+
+// issueStatus is the last status emitted for an issue.
+type issueStatus struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// feedCursor is the position of an event feed that polls for the changes
+// since a watermark, one page at a time. It is the whole cursor of the feeds
+// that need nothing more, and is embedded in the cursors of the feeds that
+// keep more state.
+type feedCursor struct {
+	// Version is the schema version the cursor was encoded with. Cursors
+	// encoded before versioning was introduced decode as version 0.
+	Version int `json:"version"`
+
+	// Since is the watermark: every change before it has been emitted.
 	Since time.Time `json:"since"`
 
-	// PageEndCursor is the GraphQL pagination cursor within the current window.
+	// PageEndCursor is the GraphQL pagination cursor within the current query.
 	PageEndCursor string `json:"page_end_cursor,omitempty"`
 
-	// LatestSeen is the most recent statusChangedAt we encountered.
-	// When we finish a sweep (no more pages), this becomes the next Since.
+	// LatestSeen is the most recent change we encountered.
+	// When we finish a query (no more pages), this becomes the next Since.
 	LatestSeen time.Time `json:"latest_seen"`
 }
 
-func decodeEventCursor(token *pagination.StreamToken, defaultStart *timestamppb.Timestamp) (*eventCursor, error) {
-	cursor := &eventCursor{}
+// eventCursor tracks where we are in the issues polling loop.
+//
+// Each sweep queries from WindowStart, which trails the watermark (Since) by
+// eventOverlapWindow. Changes in the overlap that were already emitted are
+// recorded in Emitted and skipped, so every status change is emitted exactly
+// once regardless of whether Wiz treats the lower bound as inclusive.
+type eventCursor struct {
+	feedCursor
 
-	if token != nil && token.Cursor != "" {
-		data, err := base64.StdEncoding.DecodeString(token.Cursor)
-		if err != nil {
-			return nil, fmt.Errorf("baton-wiz-insights: decode event cursor: %w", err)
-		}
-		if err := json.Unmarshal(data, cursor); err != nil {
-			return nil, fmt.Errorf("baton-wiz-insights: unmarshal event cursor: %w", err)
-		}
-		// Guard against zero LatestSeen from old or malformed tokens.
-		if cursor.LatestSeen.IsZero() {
-			cursor.LatestSeen = cursor.Since
+	// WindowStart is the statusChangedAt lower bound of the current sweep. It is
+	// fixed for the whole sweep so the GraphQL page cursor stays valid.
+	WindowStart time.Time `json:"window_start,omitempty"`
+
+	// Floor is the earliest statusChangedAt the next sweep may query from. It
+	// is raised when keys are pruned from Emitted, since changes before it can
+	// no longer be deduplicated.
+	Floor time.Time `json:"floor,omitempty"`
+
+	// Emitted holds the changes already emitted at or after the next window start.
+	Emitted []emittedKey `json:"emitted,omitempty"`
+
+	// Statuses holds the last emitted status of recently changed issues, least
+	// recently changed first. Issues not in it have an unknown previous status.
+	Statuses []issueStatus `json:"statuses,omitempty"`
+
+	// Until is the exclusive upper bound of the current query window. It is
+	// zero for open-ended windows.
+	Until time.Time `json:"until,omitempty"`
+
+	// WindowSize is the size of the next closed window. It adapts to the
+	// number of changes seen in each window, so a burst of changes is walked in
+	// small windows instead of one deeply paginated query.
+	WindowSize time.Duration `json:"window_size"`
+
+	// WindowCount is the number of changes returned so far in the current window.
+	WindowCount int `json:"window_count,omitempty"`
+
+	// BackfillStart and BackfillEnd bound the history a backfill walks in
+	// closed time slices. BackfillEnd is zero once the backfill is complete.
+	BackfillStart time.Time `json:"backfill_start,omitempty"`
+	BackfillEnd   time.Time `json:"backfill_end,omitempty"`
+
+	// BackfillSlice is the configured size of backfill slices. Windows may
+	// shrink below it during a backfill, but never grow past it.
+	BackfillSlice time.Duration `json:"backfill_slice,omitempty"`
+
+	// Notified holds the changes already emitted from webhook notifications,
+	// which polling may not have reached yet. They are kept apart from
+	// Emitted so that they never raise Floor: history the feed has not polled
+	// must still be swept to reconcile the notifications that never arrived.
+	Notified []emittedKey `json:"notified,omitempty"`
+
+	// WebhookSeq is the sequence number of the last webhook notification
+	// emitted. Notifications up to it are removed from the buffer once a
+	// cursor carrying it is handed back to the feed.
+	WebhookSeq int64 `json:"webhook_seq,omitempty"`
+
+	// LastPolled is when polling last caught up with now. With webhooks
+	// enabled, polling only runs periodically to reconcile missed notifications.
+	LastPolled time.Time `json:"last_polled,omitempty"`
+
+	// LastReconciled is when insights were last fully checked against Wiz.
+	// ReconcileFrom and ReconcileUntil bound the changes of the check in
+	// progress, and ReconcilePage is its GraphQL pagination cursor; they are
+	// zero between checks.
+	LastReconciled time.Time `json:"last_reconciled,omitempty"`
+	ReconcileFrom  time.Time `json:"reconcile_from,omitempty"`
+	ReconcileUntil time.Time `json:"reconcile_until,omitempty"`
+	ReconcilePage  string    `json:"reconcile_page,omitempty"`
+}
+
+// activityCursor tracks where the principal activity feed is in the bucket
+// being queried.
+type activityCursor struct {
+	feedCursor
+
+	// Activity holds the running aggregates of the bucket being queried,
+	// until its last page has been read or they are reported early as part
+	// ActivityPart of the bucket.
+	Activity     []activityBucket `json:"activity,omitempty"`
+	ActivityPart int              `json:"activity_part,omitempty"`
+}
+
+// streamCursor is the cursor of an event feed, encoded in its stream token.
+type streamCursor interface {
+	// reset makes the cursor that of a feed with no stored progress,
+	// starting at start.
+	reset(start time.Time)
+
+	// upgrade validates a decoded cursor and migrates it to the current version.
+	upgrade() error
+}
+
+// eventCursorOptions configures where event cursors start.
+type eventCursorOptions struct {
+	// InitialLookback is how far back a feed with no stored progress starts.
+	InitialLookback time.Duration
+
+	// RecoveryWindow is how far back a feed restarts when its cursor cannot be read.
+	RecoveryWindow time.Duration
+}
+
+// decodeEventCursor decodes the cursor of the issues feed from a stream token.
+func decodeEventCursor(
+	ctx context.Context,
+	token *pagination.StreamToken,
+	defaultStart *timestamppb.Timestamp,
+	opts eventCursorOptions,
+) (*eventCursor, annotations.Annotations) {
+	return decodeStreamCursor[eventCursor](ctx, token, defaultStart, opts)
+}
+
+// decodeStreamCursor decodes the cursor of an event feed from a stream token.
+// On the first call the token is empty and the cursor starts at defaultStart,
+// or the configured initial lookback before now. A token that cannot be
+// decoded or migrated does not block the feed: the cursor restarts the
+// configured recovery window before now and a warning annotation is returned.
+func decodeStreamCursor[C any, P interface {
+	*C
+	streamCursor
+}](
+	ctx context.Context,
+	token *pagination.StreamToken,
+	defaultStart *timestamppb.Timestamp,
+	opts eventCursorOptions,
+) (P, annotations.Annotations) {
+	if token == nil || token.Cursor == "" {
+		if defaultStart == nil {
+			defaultStart = timestamppb.New(time.Now().Add(-opts.InitialLookback))
 		}
-		return cursor, nil
+		return newStreamCursor[C, P](defaultStart.AsTime()), nil
 	}
 
-	// First call -- use the provided earliest event time, or default to 30 days ago.
-	if defaultStart != nil {
-		cursor.Since = defaultStart.AsTime()
-	} else {
-		cursor.Since = time.Now().Add(-30 * 24 * time.Hour)
+	cursor, err := parseStreamCursor[C, P](token.Cursor)
+	if err != nil {
+		restart := time.Now().Add(-opts.RecoveryWindow)
+		ctxzap.Extract(ctx).Warn("wiz-event-feed: discarding unreadable event cursor",
+			zap.Error(err),
+			zap.String("restart_from", restart.Format(time.RFC3339)))
+
+		var annos annotations.Annotations
+		annos.Update(newWarningAnnotation(
+			"event_cursor_reset",
+			fmt.Sprintf("event cursor could not be read (%s); restarting from %s", err, restart.Format(time.RFC3339)),
+		))
+		return newStreamCursor[C, P](restart), annos
 	}
-	cursor.LatestSeen = cursor.Since
 
 	return cursor, nil
 }
 
-func (c *eventCursor) encode() (string, error) {
-	data, err := json.Marshal(c)
+// newStreamCursor returns a cursor for a feed with no stored progress, starting at start.
+func newStreamCursor[C any, P interface {
+	*C
+	streamCursor
+}](start time.Time) P {
+	cursor := P(new(C))
+	cursor.reset(start)
+	return cursor
+}
+
+// newEventCursor returns a cursor of the issues feed with no stored progress, starting at start.
+func newEventCursor(start time.Time) *eventCursor {
+	return newStreamCursor[eventCursor](start)
+}
+
+// parseStreamCursor decodes an encoded cursor and migrates it to the current version.
+func parseStreamCursor[C any, P interface {
+	*C
+	streamCursor
+}](encoded string) (P, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("baton-wiz-insights: decode event cursor: %w", err)
+	}
+
+	cursor := P(new(C))
+	if err := json.Unmarshal(data, cursor); err != nil {
+		return nil, fmt.Errorf("baton-wiz-insights: unmarshal event cursor: %w", err)
+	}
+	if err := cursor.upgrade(); err != nil {
+		return nil, err
+	}
+	return cursor, nil
+}
+
+// parseEventCursor decodes an encoded cursor of the issues feed and migrates it to the current version.
+func parseEventCursor(encoded string) (*eventCursor, error) {
+	return parseStreamCursor[eventCursor](encoded)
+}
+
+// encodeStreamCursor encodes the cursor of an event feed for its stream token.
+func encodeStreamCursor(cursor streamCursor) (string, error) {
+	data, err := json.Marshal(cursor)
 	if err != nil {
 		return "", fmt.Errorf("baton-wiz-insights: marshal event cursor: %w", err)
 	}
 	return base64.StdEncoding.EncodeToString(data), nil
 }
+
+func (c *feedCursor) reset(start time.Time) {
+	*c = feedCursor{
+		Version:    eventCursorVersion,
+		Since:      start,
+		LatestSeen: start,
+	}
+}
+
+// upgrade validates the position. Feeds that only keep a position have
+// nothing to migrate between versions.
+func (c *feedCursor) upgrade() error {
+	if c.Version > eventCursorVersion {
+		return fmt.Errorf("baton-wiz-insights: unsupported event cursor version %d", c.Version)
+	}
+	if c.Since.IsZero() {
+		return fmt.Errorf("baton-wiz-insights: event cursor has no start time")
+	}
+	// Guard against zero LatestSeen from old or malformed tokens.
+	if c.LatestSeen.IsZero() {
+		c.LatestSeen = c.Since
+	}
+	c.Version = eventCursorVersion
+	return nil
+}
+
+func (c *eventCursor) reset(start time.Time) {
+	*c = eventCursor{WindowSize: defaultEventWindow}
+	c.feedCursor.reset(start)
+	// Nothing has been emitted yet, so there is nothing to overlap with.
+	c.Floor = c.Since
+}
+
+func (c *eventCursor) upgrade() error {
+	if c.Version > eventCursorVersion {
+		return fmt.Errorf("baton-wiz-insights: unsupported event cursor version %d", c.Version)
+	}
+	for c.Version < eventCursorVersion {
+		migrate, ok := eventCursorMigrations[c.Version]
+		if !ok {
+			return fmt.Errorf("baton-wiz-insights: no migration from event cursor version %d", c.Version)
+		}
+		migrate(c)
+		c.Version++
+	}
+	return c.feedCursor.upgrade()
+}
+
+// eventCursorMigrations upgrade a cursor of the issues feed from the version
+// it is keyed by to the next one.
+var eventCursorMigrations = map[int]func(*eventCursor){
+	0: migrateEventCursorV0,
+	1: migrateEventCursorV1,
+	2: migrateEventCursorV2,
+}
+
+// migrateEventCursorV0 upgrades unversioned cursors. The oldest of them have
+// no emitted keys or floor; everything before their watermark was already
+// emitted, so the next sweep must not overlap it.
+func migrateEventCursorV0(c *eventCursor) {
+	if c.Floor.IsZero() && len(c.Emitted) == 0 {
+		c.Floor = c.Since
+	}
+}
+
+// migrateEventCursorV1 adds the adaptive window size.
+func migrateEventCursorV1(c *eventCursor) {
+	c.WindowSize = defaultEventWindow
+}
+
+// migrateEventCursorV2 used to start tracking the insights to reconcile.
+// Reconciliation now lists the changes below the watermark instead, so there
+// is nothing left to migrate.
+func migrateEventCursorV2(*eventCursor) {}
+
+// startSweep fixes the lower bound of a new sweep. It is a no-op while paging
+// through a sweep that is already in progress.
+func (c *eventCursor) startSweep() {
+	if c.PageEndCursor != "" {
+		return
+	}
+	c.WindowStart = c.Since.Add(-eventOverlapWindow)
+	if c.Floor.After(c.WindowStart) {
+		c.WindowStart = c.Floor
+	}
+}
+
+// startBackfill makes the cursor walk the history from its start up to now in
+// closed windows starting at the given size, reporting progress as it goes.
+// It is a no-op if slice is zero or the history fits in a single slice.
+func (c *eventCursor) startBackfill(now time.Time, slice time.Duration) {
+	if slice <= 0 || !now.After(c.Since.Add(slice)) {
+		return
+	}
+	c.BackfillStart = c.Since
+	c.BackfillEnd = now
+	c.BackfillSlice = slice
+	c.WindowSize = slice
+}
+
+// backfilling reports whether the cursor is walking a backfill.
+func (c *eventCursor) backfilling() bool {
+	return !c.BackfillEnd.IsZero()
+}
+
+// sliceWindow bounds a new sweep to a closed window of WindowSize. Once the
+// window would reach now, or the end of a backfill, the sweep is left
+// open-ended instead and any backfill is complete. It is a no-op while paging
+// through a sweep.
+func (c *eventCursor) sliceWindow(now time.Time) {
+	if c.PageEndCursor != "" {
+		return
+	}
+
+	end := now
+	if c.backfilling() {
+		end = c.BackfillEnd
+	}
+
+	c.Until = c.Since.Add(c.WindowSize)
+	if !c.Until.Before(end) {
+		c.BackfillEnd = time.Time{}
+		c.BackfillSlice = 0
+		c.Until = time.Time{}
+	}
+}
+
+// adaptWindow resizes the next window from the number of changes seen in the
+// window that just finished. During a backfill, quiet windows grow no larger
+// than the configured slice, so the backfill keeps reporting its progress in
+// steps of at most one slice.
+func (c *eventCursor) adaptWindow(closed bool) {
+	limit := maxEventWindow
+	if c.backfilling() && c.BackfillSlice > 0 {
+		limit = min(limit, c.BackfillSlice)
+	}
+
+	switch {
+	case c.WindowCount > eventWindowHighVolume:
+		c.WindowSize /= 2
+	case c.WindowCount < eventWindowLowVolume && closed:
+		// Only grow after a closed window; an open-ended window is quiet
+		// because it reached now, not because the window is too small.
+		c.WindowSize *= 2
+	}
+	c.WindowSize = max(minEventWindow, min(c.WindowSize, limit))
+	c.WindowCount = 0
+}
+
+// backfillProgress returns the fraction of the backfill that has been walked.
+func (c *eventCursor) backfillProgress() float64 {
+	total := c.BackfillEnd.Sub(c.BackfillStart)
+	if total <= 0 {
+		return 1
+	}
+	return float64(c.Since.Sub(c.BackfillStart)) / float64(total)
+}
+
+// observe records a status change returned by the current sweep and reports
+// whether it should be emitted. Changes before the window start or already
+// emitted are skipped.
+func (c *eventCursor) observe(id string, statusChangedAt time.Time) bool {
+	c.WindowCount++
+	if statusChangedAt.After(c.LatestSeen) {
+		c.LatestSeen = statusChangedAt
+	}
+
+	if statusChangedAt.Before(c.WindowStart) {
+		return false
+	}
+
+	return c.markEmitted(id, statusChangedAt)
+}
+
+// markEmitted records a polled status change as emitted and reports whether
+// it had not been emitted before, by polling or from a webhook notification.
+func (c *eventCursor) markEmitted(id string, statusChangedAt time.Time) bool {
+	key := emittedKey{ID: id, StatusChangedAt: statusChangedAt}
+	if c.emitted(key) {
+		return false
+	}
+
+	c.Emitted = append(c.Emitted, key)
+	return true
+}
+
+// markNotified records a status change learned from a webhook notification
+// as emitted and reports whether it had not been emitted before. Unlike
+// observe, it does not move the watermark or Floor, so changes learned about
+// out of band do not cause polling to skip ahead. Past maxEmittedKeys the
+// oldest notifications are forgotten, so polling may emit them once more.
+func (c *eventCursor) markNotified(id string, statusChangedAt time.Time) bool {
+	key := emittedKey{ID: id, StatusChangedAt: statusChangedAt}
+	if c.emitted(key) {
+		return false
+	}
+
+	c.Notified = append(c.Notified, key)
+	if excess := len(c.Notified) - maxEmittedKeys; excess > 0 {
+		slices.SortFunc(c.Notified, func(a, b emittedKey) int {
+			return a.StatusChangedAt.Compare(b.StatusChangedAt)
+		})
+		c.Notified = slices.Delete(c.Notified, 0, excess)
+	}
+	return true
+}
+
+// emitted reports whether a status change has been emitted, by polling or
+// from a webhook notification.
+func (c *eventCursor) emitted(key emittedKey) bool {
+	matches := func(k emittedKey) bool {
+		return k.ID == key.ID && k.StatusChangedAt.Equal(key.StatusChangedAt)
+	}
+	return slices.ContainsFunc(c.Emitted, matches) || slices.ContainsFunc(c.Notified, matches)
+}
+
+// recordStatus remembers the status an issue changed to and returns the status
+// it had before, or an empty string if it is not known.
+func (c *eventCursor) recordStatus(id, status string) string {
+	var previous string
+	if i := slices.IndexFunc(c.Statuses, func(s issueStatus) bool { return s.ID == id }); i >= 0 {
+		previous = c.Statuses[i].Status
+		c.Statuses = slices.Delete(c.Statuses, i, i+1)
+	}
+
+	c.Statuses = append(c.Statuses, issueStatus{ID: id, Status: status})
+	if excess := len(c.Statuses) - maxTrackedStatuses; excess > 0 {
+		c.Statuses = slices.Delete(c.Statuses, 0, excess)
+	}
+	return previous
+}
+
+// advance moves the cursor to the next page, or to the next sweep once the
+// current one is exhausted. If endCursor is empty despite hasNextPage, the
+// sweep is treated as finished to avoid an infinite loop. It reports whether
+// there is more to fetch right away: another page, or the window after a
+// closed one.
+func (c *eventCursor) advance(hasNextPage bool, endCursor string) bool {
+	hasMore := hasNextPage && endCursor != ""
+	if hasMore {
+		c.PageEndCursor = endCursor
+		c.prune()
+		return true
+	}
+
+	// Done with this sweep. The next one starts from the latest timestamp we
+	// saw, or from the end of a closed window, which has been fully walked.
+	closed := !c.Until.IsZero()
+	c.Since = c.LatestSeen
+	if c.Until.After(c.Since) {
+		c.Since = c.Until
+	}
+	c.PageEndCursor = ""
+	c.Until = time.Time{}
+	c.adaptWindow(closed)
+	c.prune()
+	return closed
+}
+
+// prune drops emitted and notified keys that no future sweep can return, then
+// enforces maxEmittedKeys on polled keys by dropping the oldest of them and
+// raising Floor past them.
+// Raising Floor only affects later sweeps; the sweep in progress keeps its
+// window so none of its remaining pages are skipped.
+func (c *eventCursor) prune() {
+	// No future sweep queries from before the latest change minus the overlap.
+	cutoff := c.LatestSeen.Add(-eventOverlapWindow)
+	if c.Floor.After(cutoff) {
+		cutoff = c.Floor
+	}
+	c.Emitted = slices.DeleteFunc(c.Emitted, func(k emittedKey) bool {
+		return k.StatusChangedAt.Before(cutoff)
+	})
+	c.Notified = slices.DeleteFunc(c.Notified, func(k emittedKey) bool {
+		return k.StatusChangedAt.Before(cutoff)
+	})
+
+	if len(c.Emitted) <= maxEmittedKeys {
+		return
+	}
+
+	slices.SortFunc(c.Emitted, func(a, b emittedKey) int {
+		return a.StatusChangedAt.Compare(b.StatusChangedAt)
+	})
+
+	// Drop every key at or before the newest timestamp that has to go, so that
+	// no key sharing a timestamp with a dropped key is kept on its own.
+	dropped := c.Emitted[len(c.Emitted)-maxEmittedKeys-1].StatusChangedAt
+	c.Floor = dropped.Add(time.Nanosecond)
+	c.Emitted = slices.DeleteFunc(c.Emitted, func(k emittedKey) bool {
+		return k.StatusChangedAt.Before(c.Floor)
+	})
+}
@@ -0,0 +1,478 @@
+package connector
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// sweep runs one full sweep over pages of (id, statusChangedAt) changes and
+// returns the IDs that would be emitted.
+func sweep(t *testing.T, cursor *eventCursor, pages ...[]emittedKey) []string {
+	t.Helper()
+
+	var emitted []string
+	for i, page := range pages {
+		cursor.startSweep()
+		for _, change := range page {
+			if cursor.observe(change.ID, change.StatusChangedAt) {
+				emitted = append(emitted, change.ID)
+			}
+		}
+
+		last := i == len(pages)-1
+		endCursor := ""
+		if !last {
+			endCursor = fmt.Sprintf("page-%d", i+1)
+		}
+		hasMore := cursor.advance(!last, endCursor)
+		require.Equal(t, !last, hasMore)
+	}
+	return emitted
+}
+
+// roundTrip encodes and decodes the cursor, as happens between ListEvents calls and across restarts.
+func roundTrip(t *testing.T, cursor *eventCursor) *eventCursor {
+	t.Helper()
+
+	token, err := encodeStreamCursor(cursor)
+	require.NoError(t, err)
+
+	decoded, annos := decodeEventCursor(context.Background(), &pagination.StreamToken{Cursor: token}, nil, eventCursorOptions{RecoveryWindow: time.Hour})
+	require.Empty(t, annos)
+	return decoded
+}
+
+func newTestCursor(t *testing.T, start time.Time) *eventCursor {
+	t.Helper()
+
+	cursor, annos := decodeEventCursor(context.Background(), nil, timestamppb.New(start), eventCursorOptions{})
+	require.Empty(t, annos)
+	return cursor
+}
+
+func TestEventCursorFirstSweepStartsAtEarliestEvent(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	cursor := newTestCursor(t, start)
+
+	emitted := sweep(t, cursor, []emittedKey{
+		{ID: "before", StatusChangedAt: start.Add(-time.Second)},
+		{ID: "at-start", StatusChangedAt: start},
+		{ID: "after", StatusChangedAt: start.Add(time.Second)},
+	})
+
+	assert.Equal(t, []string{"at-start", "after"}, emitted)
+	assert.Equal(t, start.Add(time.Second), cursor.Since)
+}
+
+func TestEventCursorWatermarkBoundary(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	watermark := start.Add(time.Minute)
+	cursor := newTestCursor(t, start)
+
+	emitted := sweep(t, cursor, []emittedKey{
+		{ID: "a", StatusChangedAt: watermark},
+	})
+	require.Equal(t, []string{"a"}, emitted)
+	cursor = roundTrip(t, cursor)
+
+	// An inclusive lower bound returns the change at the watermark again, along
+	// with a different issue that shares the same timestamp.
+	emitted = sweep(t, cursor, []emittedKey{
+		{ID: "a", StatusChangedAt: watermark},
+		{ID: "b", StatusChangedAt: watermark},
+	})
+	assert.Equal(t, []string{"b"}, emitted)
+}
+
+func TestEventCursorLateCommitInsideOverlap(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	watermark := start.Add(time.Hour)
+	cursor := newTestCursor(t, start)
+
+	sweep(t, cursor, []emittedKey{{ID: "a", StatusChangedAt: watermark}})
+	cursor = roundTrip(t, cursor)
+
+	// A change that committed late on Wiz's side, with a timestamp behind the
+	// watermark but inside the overlap window, is emitted once.
+	late := emittedKey{ID: "late", StatusChangedAt: watermark.Add(-eventOverlapWindow / 2)}
+	assert.Equal(t, []string{"late"}, sweep(t, cursor, []emittedKey{late, {ID: "a", StatusChangedAt: watermark}}))
+
+	cursor = roundTrip(t, cursor)
+	assert.Empty(t, sweep(t, cursor, []emittedKey{late}))
+}
+
+func TestEventCursorChangeOutsideOverlapIsSkipped(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	watermark := start.Add(time.Hour)
+	cursor := newTestCursor(t, start)
+
+	sweep(t, cursor, []emittedKey{{ID: "a", StatusChangedAt: watermark}})
+	cursor = roundTrip(t, cursor)
+
+	assert.Equal(t, watermark.Add(-eventOverlapWindow), func() time.Time {
+		cursor.startSweep()
+		return cursor.WindowStart
+	}())
+	assert.Empty(t, sweep(t, cursor, []emittedKey{
+		{ID: "old", StatusChangedAt: watermark.Add(-eventOverlapWindow - time.Nanosecond)},
+	}))
+}
+
+func TestEventCursorNewStatusChangeOfSameIssue(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	cursor := newTestCursor(t, start)
+
+	sweep(t, cursor, []emittedKey{{ID: "a", StatusChangedAt: start.Add(time.Minute)}})
+	cursor = roundTrip(t, cursor)
+
+	emitted := sweep(t, cursor, []emittedKey{
+		{ID: "a", StatusChangedAt: start.Add(time.Minute)},
+		{ID: "a", StatusChangedAt: start.Add(2 * time.Minute)},
+	})
+	assert.Equal(t, []string{"a"}, emitted)
+	assert.Equal(t, start.Add(2*time.Minute), cursor.Since)
+}
+
+func TestEventCursorSharedTimestampAcrossPages(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	ts := start.Add(time.Minute)
+	cursor := newTestCursor(t, start)
+
+	emitted := sweep(t, cursor,
+		[]emittedKey{{ID: "a", StatusChangedAt: ts}, {ID: "b", StatusChangedAt: ts}},
+		[]emittedKey{{ID: "b", StatusChangedAt: ts}, {ID: "c", StatusChangedAt: ts}},
+	)
+	assert.Equal(t, []string{"a", "b", "c"}, emitted)
+}
+
+func TestEventCursorWindowFixedAcrossPages(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	cursor := newTestCursor(t, start)
+	sweep(t, cursor, []emittedKey{{ID: "a", StatusChangedAt: start.Add(time.Hour)}})
+
+	cursor.startSweep()
+	windowStart := cursor.WindowStart
+	cursor.observe("b", start.Add(2*time.Hour))
+	require.True(t, cursor.advance(true, "page-1"))
+
+	cursor = roundTrip(t, cursor)
+	cursor.startSweep()
+	assert.Equal(t, windowStart, cursor.WindowStart)
+	assert.Equal(t, "page-1", cursor.PageEndCursor)
+}
+
+func TestEventCursorEmptyEndCursorEndsSweep(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	cursor := newTestCursor(t, start)
+
+	cursor.startSweep()
+	cursor.observe("a", start.Add(time.Minute))
+	assert.False(t, cursor.advance(true, ""))
+	assert.Empty(t, cursor.PageEndCursor)
+	assert.Equal(t, start.Add(time.Minute), cursor.Since)
+}
+
+func TestEventCursorEmittedKeysAreBounded(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	cursor := newTestCursor(t, start)
+
+	// More changes than the cursor can remember, all inside the overlap window.
+	var page []emittedKey
+	for i := 0; i < maxEmittedKeys+10; i++ {
+		page = append(page, emittedKey{ID: fmt.Sprintf("issue-%d", i), StatusChangedAt: start.Add(time.Duration(i) * time.Millisecond)})
+	}
+	require.Len(t, sweep(t, cursor, page), len(page))
+	assert.Len(t, cursor.Emitted, maxEmittedKeys)
+
+	// The oldest changes were forgotten, so the next sweep starts after them
+	// instead of emitting them again.
+	oldestKept := page[len(page)-maxEmittedKeys].StatusChangedAt
+	assert.True(t, cursor.Floor.After(page[len(page)-maxEmittedKeys-1].StatusChangedAt))
+	assert.False(t, cursor.Floor.After(oldestKept))
+
+	cursor = roundTrip(t, cursor)
+	assert.Empty(t, sweep(t, cursor, page))
+}
+
+func TestEventCursorBoundDropsWholeTimestamp(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	cursor := newTestCursor(t, start)
+
+	// The key at the limit shares its timestamp with older keys; all of them go.
+	var page []emittedKey
+	for i := 0; i < maxEmittedKeys+1; i++ {
+		ts := start.Add(time.Duration(i) * time.Millisecond)
+		if i < 5 {
+			ts = start
+		}
+		page = append(page, emittedKey{ID: fmt.Sprintf("issue-%d", i), StatusChangedAt: ts})
+	}
+	sweep(t, cursor, page)
+
+	assert.Len(t, cursor.Emitted, maxEmittedKeys-4)
+	assert.Equal(t, start.Add(time.Nanosecond), cursor.Floor)
+
+	cursor = roundTrip(t, cursor)
+	assert.Empty(t, sweep(t, cursor, page))
+}
+
+func TestEventCursorMigratesUnversionedCursor(t *testing.T) {
+	since := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// A cursor encoded before versioning, with no latest_seen or overlap state.
+	legacy := base64.StdEncoding.EncodeToString([]byte(`{"since":"2025-01-01T12:00:00Z","page_end_cursor":"abc"}`))
+
+	cursor, annos := decodeEventCursor(context.Background(), &pagination.StreamToken{Cursor: legacy}, nil, eventCursorOptions{RecoveryWindow: time.Hour})
+	require.Empty(t, annos)
+	assert.Equal(t, eventCursorVersion, cursor.Version)
+	assert.Equal(t, since, cursor.Since)
+	assert.Equal(t, since, cursor.LatestSeen)
+	assert.Equal(t, since, cursor.Floor)
+	assert.Equal(t, "abc", cursor.PageEndCursor)
+	assert.Equal(t, defaultEventWindow, cursor.WindowSize)
+}
+
+func TestFeedCursorsHoldOnlyTheirOwnState(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		cursor streamCursor
+		absent []string
+	}{
+		{name: "paged feed", cursor: newStreamCursor[feedCursor](start), absent: []string{"window_size", "activity"}},
+		{name: "activity feed", cursor: newStreamCursor[activityCursor](start), absent: []string{"window_size"}},
+		{name: "issues feed", cursor: newEventCursor(start), absent: []string{"activity"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := encodeStreamCursor(tt.cursor)
+			require.NoError(t, err)
+			data, err := base64.StdEncoding.DecodeString(token)
+			require.NoError(t, err)
+			var raw map[string]interface{}
+			require.NoError(t, json.Unmarshal(data, &raw))
+			assert.Contains(t, raw, "since")
+			for _, key := range tt.absent {
+				assert.NotContains(t, raw, key)
+			}
+		})
+	}
+
+	// A cursor stored by a feed before the split keeps its position.
+	token, err := encodeStreamCursor(newEventCursor(start))
+	require.NoError(t, err)
+	cursor, annos := decodeStreamCursor[feedCursor](context.Background(), &pagination.StreamToken{Cursor: token}, nil, eventCursorOptions{})
+	require.Empty(t, annos)
+	assert.Equal(t, start, cursor.Since)
+}
+
+func TestEventCursorRecoversFromUnreadableCursor(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "not base64", token: "not a cursor!"},
+		{name: "not json", token: base64.StdEncoding.EncodeToString([]byte("{"))},
+		{name: "future version", token: base64.StdEncoding.EncodeToString([]byte(`{"version":999,"since":"2025-01-01T12:00:00Z"}`))},
+		{name: "missing start", token: base64.StdEncoding.EncodeToString([]byte(`{"version":1}`))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := time.Now()
+			cursor, annos := decodeEventCursor(context.Background(), &pagination.StreamToken{Cursor: tt.token}, nil, eventCursorOptions{RecoveryWindow: 6 * time.Hour})
+
+			require.Len(t, annos, 1)
+			assert.WithinRange(t, cursor.Since, before.Add(-6*time.Hour), time.Now().Add(-6*time.Hour))
+			assert.Equal(t, cursor.Since, cursor.Floor)
+			assert.Empty(t, cursor.PageEndCursor)
+		})
+	}
+}
+
+func TestEventCursorRecordStatus(t *testing.T) {
+	cursor := newTestCursor(t, time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+
+	assert.Empty(t, cursor.recordStatus("a", "OPEN"))
+	assert.Equal(t, "OPEN", cursor.recordStatus("a", "IN_PROGRESS"))
+
+	cursor = roundTrip(t, cursor)
+	assert.Equal(t, "IN_PROGRESS", cursor.recordStatus("a", "RESOLVED"))
+
+	// The least recently changed issues are forgotten first.
+	for i := 0; i < maxTrackedStatuses; i++ {
+		cursor.recordStatus(fmt.Sprintf("issue-%d", i), "OPEN")
+	}
+	assert.Len(t, cursor.Statuses, maxTrackedStatuses)
+	assert.Empty(t, cursor.recordStatus("a", "OPEN"))
+	assert.Equal(t, "OPEN", cursor.recordStatus(fmt.Sprintf("issue-%d", maxTrackedStatuses-1), "RESOLVED"))
+}
+
+func TestEventCursorInitialLookback(t *testing.T) {
+	before := time.Now()
+	cursor, annos := decodeEventCursor(context.Background(), nil, nil, eventCursorOptions{InitialLookback: 180 * 24 * time.Hour})
+	require.Empty(t, annos)
+	assert.WithinRange(t, cursor.Since, before.Add(-180*24*time.Hour), time.Now().Add(-180*24*time.Hour))
+
+	// A zero lookback only reports changes from now on.
+	before = time.Now()
+	cursor, _ = decodeEventCursor(context.Background(), nil, nil, eventCursorOptions{})
+	assert.WithinRange(t, cursor.Since, before, time.Now())
+
+	// An explicit earliest event takes precedence over the lookback.
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	cursor, _ = decodeEventCursor(context.Background(), nil, timestamppb.New(start), eventCursorOptions{InitialLookback: time.Hour})
+	assert.Equal(t, start, cursor.Since)
+}
+
+func TestEventCursorBackfillSlices(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start.Add(60 * time.Hour)
+	slice := 24 * time.Hour
+
+	cursor := newTestCursor(t, start)
+	cursor.startBackfill(now, slice)
+	require.True(t, cursor.backfilling())
+
+	// Each slice is a closed window; finishing one moves straight on to the next.
+	var windows [][2]time.Time
+	for cursor.backfilling() {
+		cursor.startSweep()
+		cursor.sliceWindow(now)
+		windows = append(windows, [2]time.Time{cursor.WindowStart, cursor.Until})
+
+		if !cursor.Until.IsZero() {
+			cursor.observe("a", cursor.Until.Add(-time.Hour))
+		}
+		hasMore := cursor.advance(false, "")
+		cursor = roundTrip(t, cursor)
+		if cursor.backfilling() {
+			require.True(t, hasMore)
+			assert.InDelta(t, float64(cursor.Since.Sub(start))/float64(now.Sub(start)), cursor.backfillProgress(), 0.0001)
+		} else {
+			require.False(t, hasMore)
+		}
+	}
+
+	assert.Equal(t, [][2]time.Time{
+		{start, start.Add(24 * time.Hour)},
+		// The first slice was quiet, but windows do not grow past the slice
+		// during a backfill.
+		{start.Add(24*time.Hour - eventOverlapWindow), start.Add(48 * time.Hour)},
+		// The next slice would reach the end of the backfill, so it is left open-ended.
+		{start.Add(48*time.Hour - eventOverlapWindow), {}},
+	}, windows)
+}
+
+func TestEventCursorBackfillWindowShrinksBelowSlice(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start.Add(30 * 24 * time.Hour)
+	slice := 24 * time.Hour
+
+	cursor := newTestCursor(t, start)
+	cursor.startBackfill(now, slice)
+
+	// A busy slice halves the next window, and quiet windows then grow back
+	// to the slice but no further.
+	cursor.startSweep()
+	cursor.sliceWindow(now)
+	for i := 0; i <= eventWindowHighVolume; i++ {
+		cursor.observe(fmt.Sprintf("issue-%d", i), cursor.Since.Add(time.Duration(i)*time.Millisecond))
+	}
+	cursor.advance(false, "")
+	assert.Equal(t, slice/2, cursor.WindowSize)
+
+	for i := 0; i < 5; i++ {
+		cursor.startSweep()
+		cursor.sliceWindow(now)
+		cursor.advance(false, "")
+		cursor = roundTrip(t, cursor)
+	}
+	assert.Equal(t, slice, cursor.WindowSize)
+
+	// Once the backfill is complete, windows may grow past the slice again.
+	cursor.BackfillEnd = time.Time{}
+	cursor.BackfillSlice = 0
+	cursor.startSweep()
+	cursor.sliceWindow(now)
+	cursor.advance(false, "")
+	assert.Equal(t, 2*slice, cursor.WindowSize)
+}
+
+func TestEventCursorBackfillNotNeeded(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cursor := newTestCursor(t, start)
+	cursor.startBackfill(start.Add(time.Hour), 24*time.Hour)
+	assert.False(t, cursor.backfilling())
+
+	cursor.startBackfill(start.Add(48*time.Hour), 0)
+	assert.False(t, cursor.backfilling())
+}
+
+func TestEventCursorAdaptiveWindow(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start.Add(30 * 24 * time.Hour)
+
+	cursor := newTestCursor(t, start)
+	require.Equal(t, defaultEventWindow, cursor.WindowSize)
+
+	// runWindow sweeps one window that returns the given number of changes.
+	runWindow := func(changes int) (time.Time, time.Time, bool) {
+		cursor.startSweep()
+		cursor.sliceWindow(now)
+		windowStart, until := cursor.WindowStart, cursor.Until
+
+		for i := 0; i < changes; i++ {
+			cursor.observe(fmt.Sprintf("issue-%d", i), cursor.Since.Add(time.Duration(i)*time.Millisecond))
+		}
+		hasMore := cursor.advance(false, "")
+		cursor = roundTrip(t, cursor)
+		return windowStart, until, hasMore
+	}
+
+	// A busy window halves the next one, and the cursor keeps going right away.
+	_, until, hasMore := runWindow(eventWindowHighVolume + 1)
+	assert.Equal(t, start.Add(defaultEventWindow), until)
+	assert.True(t, hasMore)
+	assert.Equal(t, defaultEventWindow/2, cursor.WindowSize)
+
+	windowStart, until, _ := runWindow(eventWindowLowVolume)
+	assert.Equal(t, start.Add(defaultEventWindow-eventOverlapWindow), windowStart)
+	assert.Equal(t, start.Add(defaultEventWindow+defaultEventWindow/2), until)
+	assert.Equal(t, defaultEventWindow/2, cursor.WindowSize)
+
+	// A quiet window doubles the next one, up to the maximum.
+	for i := 0; i < 10; i++ {
+		runWindow(0)
+	}
+	assert.Equal(t, maxEventWindow, cursor.WindowSize)
+
+	// The window never shrinks below the minimum.
+	cursor.WindowSize = minEventWindow
+	runWindow(eventWindowHighVolume + 1)
+	assert.Equal(t, minEventWindow, cursor.WindowSize)
+}
+
+func TestEventCursorWindowOpensAtNow(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	cursor := newTestCursor(t, now.Add(-time.Hour))
+
+	cursor.startSweep()
+	cursor.sliceWindow(now)
+	assert.True(t, cursor.Until.IsZero())
+
+	// A quiet open-ended window does not grow the next one.
+	assert.False(t, cursor.advance(false, ""))
+	assert.Equal(t, defaultEventWindow, cursor.WindowSize)
+}
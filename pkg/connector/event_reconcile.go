@@ -0,0 +1,165 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// issueChangePruned is the kind of change reported when reconciliation finds
+// that an insight the feed emitted is no longer synced.
+const issueChangePruned = "pruned"
+
+// Reasons an insight is pruned by reconciliation.
+const (
+	// pruneReasonNotFound is an issue that no longer exists, or whose entity
+	// is no longer a principal, for example because the entity was deleted.
+	pruneReasonNotFound = "not_found"
+	// pruneReasonInactive is an issue resolved or rejected without the feed
+	// seeing the change, when only active issues are synced.
+	pruneReasonInactive = "inactive"
+	// pruneReasonOutOfScope is an issue the configured filters no longer select.
+	pruneReasonOutOfScope = "out_of_scope"
+)
+
+// insightReconcileWindow is how far below the watermark reconciliation looks
+// for insights the feed emitted. Insights of issues that last changed before
+// it, and insights only created by a full sync, are pruned by the next full
+// sync instead.
+const insightReconcileWindow = 7 * 24 * time.Hour
+
+// reconcileDue reports whether the insights emitted by the feed should be
+// reconciled against Wiz before the next sweep: a check is in progress, or the
+// last one finished at least the reconcile interval ago.
+func (e *issuesEventFeed) reconcileDue(cursor *eventCursor) bool {
+	interval := e.connector.insightReconcile
+	if interval <= 0 || cursor.PageEndCursor != "" {
+		return false
+	}
+	return !cursor.ReconcileUntil.IsZero() || time.Since(cursor.LastReconciled) >= interval
+}
+
+// reconcile checks the next page of the insights the feed emitted as synced,
+// and emits a removal event for each one that no longer exists, is no longer
+// active, or is no longer selected by the configured filters. An issue that
+// leaves the filters' scope has no status change for the feed to report, so
+// without reconciliation its insight would linger until the next full sync.
+//
+// Rather than keeping the IDs of the insights it emitted, the feed lists the
+// issues that changed within insightReconcileWindow below its watermark, the
+// changes it has already emitted, so the cursor only holds the bounds of the
+// check. The check pages through them one page per call, and is complete once
+// the last page has been checked. Issues the feed never emitted as synced may
+// be reported as removed too; the event ID only depends on the issue and its
+// last change, so repeated checks report each of them once.
+func (e *issuesEventFeed) reconcile(ctx context.Context, cursor *eventCursor) ([]*v2.Event, error) {
+	now := time.Now()
+	if cursor.ReconcileUntil.IsZero() {
+		cursor.ReconcileUntil = cursor.Since
+		cursor.ReconcileFrom = cursor.Since.Add(-insightReconcileWindow)
+	}
+
+	var pageCursor *string
+	if cursor.ReconcilePage != "" {
+		pageCursor = &cursor.ReconcilePage
+	}
+	changesResp, err := e.connector.client.ListIssueChangesBetween(ctx, cursor.ReconcileFrom, cursor.ReconcileUntil, pageCursor)
+	if err != nil {
+		return nil, fmt.Errorf("baton-wiz-insights: failed to list emitted issues to reconcile: %w", err)
+	}
+
+	// Issues whose last change left the synced statuses were already reported
+	// as removed when the feed emitted that change.
+	var changes []wiz.IssueChange
+	var ids []string
+	for _, change := range changesResp.Nodes {
+		if e.connector.issueSync.syncsStatus(change.Status) {
+			changes = append(changes, change)
+			ids = append(ids, change.ID)
+		}
+	}
+
+	found := make(map[string]wiz.Issue, len(ids))
+	if len(ids) > 0 {
+		issues, err := e.connector.client.GetIssues(ctx, ids)
+		if err != nil {
+			return nil, fmt.Errorf("baton-wiz-insights: failed to reconcile emitted issues: %w", err)
+		}
+		for _, issue := range issues {
+			found[issue.ID] = issue
+		}
+	}
+
+	var events []*v2.Event
+	for _, change := range changes {
+		issue, ok := found[change.ID]
+		reason := ""
+		switch {
+		case !ok:
+			reason = pruneReasonNotFound
+		case !e.connector.issueSync.syncsStatus(issue.Status):
+			reason = pruneReasonInactive
+		case !e.connector.issueSync.selects(issue):
+			reason = pruneReasonOutOfScope
+		default:
+			continue
+		}
+
+		event, err := newIssuePrunedEvent(change, reason, now)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	// If EndCursor is empty despite HasNextPage, stop paginating to avoid an infinite loop.
+	complete := !changesResp.PageInfo.HasNextPage || changesResp.PageInfo.EndCursor == ""
+	if complete {
+		cursor.ReconcileFrom = time.Time{}
+		cursor.ReconcileUntil = time.Time{}
+		cursor.ReconcilePage = ""
+		cursor.LastReconciled = now
+	} else {
+		cursor.ReconcilePage = changesResp.PageInfo.EndCursor
+	}
+
+	ctxzap.Extract(ctx).Info("wiz-event-feed: reconciled emitted issues",
+		zap.Int("checked", len(ids)),
+		zap.Int("pruned", len(events)),
+		zap.Bool("complete", complete))
+	return events, nil
+}
+
+// newIssuePrunedEvent builds a RESOURCE_CHANGE event marking the insight of an
+// issue as no longer existing, with the reason in a details struct annotation.
+// The event is identified by the issue's last change rather than the time it
+// was pruned, so that reconciling the same change again reports the same event.
+func newIssuePrunedEvent(change wiz.IssueChange, reason string, prunedAt time.Time) (*v2.Event, error) {
+	details, err := structpb.NewStruct(map[string]interface{}{
+		"change": issueChangePruned,
+		"reason": reason,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("baton-wiz-insights: failed to build details for issue %s: %w", change.ID, err)
+	}
+
+	return v2.Event_builder{
+		Id:         fmt.Sprintf("issue-%s-%s-%s", issueChangePruned, change.StatusChangedAt.Format(time.RFC3339Nano), change.ID),
+		OccurredAt: timestamppb.New(prunedAt),
+		ResourceChangeEvent: v2.ResourceChangeEvent_builder{
+			ResourceId: v2.ResourceId_builder{
+				ResourceType: issueResourceType.GetId(),
+				Resource:     change.ID,
+			}.Build(),
+		}.Build(),
+		Annotations: annotations.New(&v2.ResourceDoesNotExist{}, details),
+	}.Build(), nil
+}
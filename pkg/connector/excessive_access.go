@@ -0,0 +1,140 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+)
+
+// remediationPolicyAssetPrefix prefixes asset IDs that reference the
+// recommended replacement policy of an excessive access finding.
+const remediationPolicyAssetPrefix = "excessive-access-policy:"
+
+type excessiveAccessBuilder struct {
+	client wiz.Client
+}
+
+func (e *excessiveAccessBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
+	return excessiveAccessResourceType
+}
+
+// List returns open Wiz excessive access findings as security insight resources, one page at a time.
+func (e *excessiveAccessBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, attr resource.SyncOpAttrs) ([]*v2.Resource, *resource.SyncOpResults, error) {
+	var resources []*v2.Resource
+
+	var cursor *string
+	if attr.PageToken.Token != "" {
+		cursor = &attr.PageToken.Token
+	}
+
+	resp, err := e.client.ListExcessiveAccessFindings(ctx, cursor)
+	if err != nil {
+		return nil, nil, fmt.Errorf("baton-wiz-insights: failed to list excessive access findings: %w", err)
+	}
+
+	for _, finding := range resp.Nodes {
+		insightResource, err := newExcessiveAccessResource(finding)
+		if err != nil {
+			return nil, nil, err
+		}
+		resources = append(resources, insightResource)
+	}
+
+	// If EndCursor is empty despite HasNextPage, stop paginating to avoid an infinite loop.
+	syncResults := &resource.SyncOpResults{}
+	if resp.PageInfo.HasNextPage && resp.PageInfo.EndCursor != "" {
+		syncResults.NextPageToken = resp.PageInfo.EndCursor
+	}
+
+	return resources, syncResults, nil
+}
+
+// Entitlements returns an empty slice for excessive access insights.
+func (e *excessiveAccessBuilder) Entitlements(_ context.Context, _ *v2.Resource, _ resource.SyncOpAttrs) ([]*v2.Entitlement, *resource.SyncOpResults, error) {
+	return nil, nil, nil
+}
+
+// Grants returns an empty slice for excessive access insights.
+func (e *excessiveAccessBuilder) Grants(_ context.Context, _ *v2.Resource, _ resource.SyncOpAttrs) ([]*v2.Grant, *resource.SyncOpResults, error) {
+	return nil, nil, nil
+}
+
+func newExcessiveAccessBuilder(client wiz.Client) *excessiveAccessBuilder {
+	return &excessiveAccessBuilder{client: client}
+}
+
+// newExcessiveAccessResource converts an excessive access finding into a
+// security insight targeted at the principal holding the unused permissions.
+func newExcessiveAccessResource(finding wiz.ExcessiveAccessFinding) (*v2.Resource, error) {
+	// Match issue insights: prefer the entity's external ID, then the provider
+	// unique ID reported on the finding, then the Wiz entity ID.
+	principalName := ""
+	targetID := finding.Principal.CloudProviderUniqueID
+	if entity := finding.Principal.GraphEntity; entity != nil {
+		principalName = entity.Name
+		if entity.ExternalID() != "" || targetID == "" {
+			targetID = principalExternalID(entity.ExternalID(), entity.ID)
+		}
+	}
+
+	insightOpts := []resource.SecurityInsightTraitOption{
+		resource.WithIssue(finding.Name),
+		resource.WithIssueSeverity(finding.Severity),
+		resource.WithInsightObservedAt(finding.UpdatedAt),
+		resource.WithInsightAppUserTarget(principalName, targetID),
+	}
+
+	profile := map[string]interface{}{
+		"status":                   finding.Status,
+		"cloud_platform":           finding.CloudPlatform,
+		"remediation_type":         finding.RemediationType,
+		"unused_permissions_count": finding.UnusedPermissionsCount,
+		"lookback_days":            finding.LookbackDays,
+		"recommended_policy_name":  finding.BuiltInPolicyRemediationName,
+		"cloud_account_id":         finding.CloudAccount.ExternalID,
+		"cloud_account_name":       finding.CloudAccount.Name,
+		"cloud_provider":           finding.CloudAccount.CloudProvider,
+	}
+
+	opts := []resource.ResourceOption{
+		resource.WithSecurityInsightTrait(insightOpts...),
+		resource.WithDescription(fmt.Sprintf("%d unused permissions in %s (%s)",
+			finding.UnusedPermissionsCount, finding.CloudAccount.Name, finding.CloudAccount.ExternalID)),
+		withInsightProfile(profile),
+	}
+	if finding.RemediationPolicy != "" {
+		opts = append(opts, resource.WithAnnotation(v2.AssetRef_builder{
+			Id: remediationPolicyAssetPrefix + finding.ID,
+		}.Build()))
+	}
+
+	displayName := fmt.Sprintf("[%s] %s", finding.Severity, finding.Name)
+
+	insightResource, err := resource.NewResource(displayName, excessiveAccessResourceType, finding.ID, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("baton-wiz-insights: failed to create security insight resource for excessive access finding %s: %w", finding.ID, err)
+	}
+
+	return insightResource, nil
+}
+
+// remediationPolicyAsset returns the recommended replacement policy for the
+// excessive access finding referenced by the asset ID.
+func remediationPolicyAsset(ctx context.Context, client wiz.Client, assetID string) (string, io.ReadCloser, error) {
+	findingID := strings.TrimPrefix(assetID, remediationPolicyAssetPrefix)
+
+	finding, err := client.GetExcessiveAccessFinding(ctx, findingID)
+	if err != nil {
+		return "", nil, fmt.Errorf("baton-wiz-insights: failed to get excessive access finding %s: %w", findingID, err)
+	}
+	if finding.RemediationPolicy == "" {
+		return "", nil, fmt.Errorf("baton-wiz-insights: excessive access finding %s has no recommended policy", findingID)
+	}
+
+	return "application/json", io.NopCloser(strings.NewReader(finding.RemediationPolicy)), nil
+}
@@ -0,0 +1,117 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const excessiveAccessEventFeedID = "wiz_excessive_access_feed"
+
+// excessiveAccessEventFeed implements connectorbuilder.EventFeed by polling
+// excessive access findings updated since the last check. It reports findings
+// that were newly created, changed, or cleared.
+type excessiveAccessEventFeed struct {
+	connector *Connector
+}
+
+func newExcessiveAccessEventFeed(connector *Connector) *excessiveAccessEventFeed {
+	return &excessiveAccessEventFeed{connector: connector}
+}
+
+func (e *excessiveAccessEventFeed) EventFeedMetadata(_ context.Context) *v2.EventFeedMetadata {
+	return v2.EventFeedMetadata_builder{
+		Id: excessiveAccessEventFeedID,
+		SupportedEventTypes: []v2.EventType{
+			v2.EventType_EVENT_TYPE_RESOURCE_CHANGE,
+		},
+	}.Build()
+}
+
+func (e *excessiveAccessEventFeed) ListEvents(
+	ctx context.Context,
+	earliestEvent *timestamppb.Timestamp,
+	pToken *pagination.StreamToken,
+) ([]*v2.Event, *pagination.StreamState, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	cursor, annos := decodeStreamCursor[feedCursor](ctx, pToken, earliestEvent, e.connector.cursorOptions)
+
+	l.Debug("wiz-excessive-access-feed: querying findings",
+		zap.String("since", cursor.Since.Format(time.RFC3339)),
+		zap.String("page_cursor", cursor.PageEndCursor))
+
+	var pageCursor *string
+	if cursor.PageEndCursor != "" {
+		pageCursor = &cursor.PageEndCursor
+	}
+
+	findingsResp, err := e.connector.client.ListExcessiveAccessFindingsSince(ctx, cursor.Since, pageCursor)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var events []*v2.Event
+	for _, finding := range findingsResp.Nodes {
+		events = append(events, newExcessiveAccessEvent(finding, cursor.Since))
+
+		if finding.UpdatedAt.After(cursor.LatestSeen) {
+			cursor.LatestSeen = finding.UpdatedAt
+		}
+	}
+
+	// If EndCursor is empty despite HasNextPage, stop paginating to avoid an infinite loop.
+	hasMore := findingsResp.PageInfo.HasNextPage && findingsResp.PageInfo.EndCursor != ""
+	if hasMore {
+		cursor.PageEndCursor = findingsResp.PageInfo.EndCursor
+	} else {
+		cursor.Since = cursor.LatestSeen
+		cursor.PageEndCursor = ""
+	}
+
+	nextCursor, err := encodeStreamCursor(cursor)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	l.Debug("wiz-excessive-access-feed: processed findings",
+		zap.Int("count", len(events)),
+		zap.Bool("has_more", hasMore))
+
+	return events, &pagination.StreamState{Cursor: nextCursor, HasMore: hasMore}, annos, nil
+}
+
+// newExcessiveAccessEvent builds a RESOURCE_CHANGE event for a finding. Findings
+// first seen inside the polling window are reported as created; resolved
+// findings are reported as cleared and marked as no longer existing.
+func newExcessiveAccessEvent(finding wiz.ExcessiveAccessFinding, windowStart time.Time) *v2.Event {
+	kind := "changed"
+	var annos annotations.Annotations
+	switch {
+	case finding.Status == wiz.ExcessiveAccessStatusResolved:
+		kind = "cleared"
+		annos.Update(&v2.ResourceDoesNotExist{})
+	case finding.FirstSeenAt.After(windowStart):
+		kind = "created"
+	}
+
+	return v2.Event_builder{
+		Id:         fmt.Sprintf("excessive-access-%s-%s-%s", kind, finding.UpdatedAt.Format(time.RFC3339Nano), finding.ID),
+		OccurredAt: timestamppb.New(finding.UpdatedAt),
+		ResourceChangeEvent: v2.ResourceChangeEvent_builder{
+			ResourceId: v2.ResourceId_builder{
+				ResourceType: excessiveAccessResourceType.GetId(),
+				Resource:     finding.ID,
+			}.Build(),
+		}.Build(),
+		Annotations: annos,
+	}.Build()
+}
@@ -0,0 +1,138 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// excessiveAccessClient serves fixed pages of findings by cursor, and records
+// the cursors queried.
+type excessiveAccessClient struct {
+	wiz.Client
+
+	pages   map[string]*wiz.ExcessiveAccessFindingConnection
+	cursors []string
+}
+
+func (c *excessiveAccessClient) ListExcessiveAccessFindingsSince(_ context.Context, _ time.Time, cursor *string) (*wiz.ExcessiveAccessFindingConnection, error) {
+	key := ""
+	if cursor != nil {
+		key = *cursor
+	}
+	c.cursors = append(c.cursors, key)
+	return c.pages[key], nil
+}
+
+func TestExcessiveAccessEventFeedPaging(t *testing.T) {
+	ctx := context.Background()
+
+	at := time.Now().UTC().Add(-time.Hour)
+	finding := func(id string) wiz.ExcessiveAccessFinding {
+		return wiz.ExcessiveAccessFinding{ID: id, Status: "OPEN", FirstSeenAt: at.Add(-48 * time.Hour), UpdatedAt: at}
+	}
+
+	tests := []struct {
+		name    string
+		pages   map[string]*wiz.ExcessiveAccessFindingConnection
+		ids     []string
+		cursors []string
+	}{
+		{
+			name: "pages are followed until the last one",
+			pages: map[string]*wiz.ExcessiveAccessFindingConnection{
+				"":   {Nodes: []wiz.ExcessiveAccessFinding{finding("f1")}, PageInfo: wiz.PageInfo{HasNextPage: true, EndCursor: "p2"}},
+				"p2": {Nodes: []wiz.ExcessiveAccessFinding{finding("f2")}},
+			},
+			ids:     []string{"f1", "f2"},
+			cursors: []string{"", "p2"},
+		},
+		{
+			name: "a next page without a cursor ends paging",
+			pages: map[string]*wiz.ExcessiveAccessFindingConnection{
+				"": {Nodes: []wiz.ExcessiveAccessFinding{finding("f1")}, PageInfo: wiz.PageInfo{HasNextPage: true}},
+			},
+			ids:     []string{"f1"},
+			cursors: []string{""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &excessiveAccessClient{pages: tt.pages}
+			feed := newExcessiveAccessEventFeed(&Connector{client: client})
+
+			var ids []string
+			token := &pagination.StreamToken{}
+			for range 10 {
+				events, state, _, err := feed.ListEvents(ctx, timestamppb.New(at.Add(-time.Hour)), token)
+				require.NoError(t, err)
+				for _, event := range events {
+					ids = append(ids, event.GetResourceChangeEvent().GetResourceId().GetResource())
+				}
+				if !state.HasMore {
+					break
+				}
+				token = &pagination.StreamToken{Cursor: state.Cursor}
+			}
+
+			assert.Equal(t, tt.ids, ids)
+			assert.Equal(t, tt.cursors, client.cursors)
+		})
+	}
+}
+
+func TestNewExcessiveAccessEvent(t *testing.T) {
+	windowStart := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		status      string
+		firstSeenAt time.Time
+		kind        string
+		removed     bool
+	}{
+		{
+			name:        "first seen in the window",
+			status:      "OPEN",
+			firstSeenAt: windowStart.Add(time.Minute),
+			kind:        "created",
+		},
+		{
+			name:        "first seen before the window",
+			status:      "OPEN",
+			firstSeenAt: windowStart.Add(-time.Hour),
+			kind:        "changed",
+		},
+		{
+			name:        "resolved",
+			status:      wiz.ExcessiveAccessStatusResolved,
+			firstSeenAt: windowStart.Add(time.Minute),
+			kind:        "cleared",
+			removed:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updatedAt := windowStart.Add(time.Hour)
+			event := newExcessiveAccessEvent(wiz.ExcessiveAccessFinding{
+				ID:          "f1",
+				Status:      tt.status,
+				FirstSeenAt: tt.firstSeenAt,
+				UpdatedAt:   updatedAt,
+			}, windowStart)
+
+			assert.Equal(t, fmt.Sprintf("excessive-access-%s-%s-f1", tt.kind, updatedAt.Format(time.RFC3339Nano)), event.GetId())
+			annos := annotations.Annotations(event.GetAnnotations())
+			assert.Equal(t, tt.removed, annos.Contains(&v2.ResourceDoesNotExist{}))
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+)
+
+// frameworkMappings converts the framework subcategories a rule maps to into
+// profile values.
+func frameworkMappings(subCategories []wiz.SecuritySubCategory) []interface{} {
+	mappings := make([]interface{}, 0, len(subCategories))
+	for _, sub := range subCategories {
+		mappings = append(mappings, map[string]interface{}{
+			"framework":      sub.Category.Framework.Name,
+			"category":       sub.Category.Name,
+			"subcategory":    sub.Title,
+			"subcategory_id": sub.ExternalID,
+		})
+	}
+	return mappings
+}
+
+// issueFrameworks returns the names of the frameworks an issue's source rule
+// maps to, sorted and without duplicates.
+func issueFrameworks(issue wiz.Issue) []string {
+	var frameworks []string
+	for _, sub := range issue.SourceRule.SecuritySubCategories {
+		if name := sub.Category.Framework.Name; name != "" {
+			frameworks = append(frameworks, name)
+		}
+	}
+	slices.Sort(frameworks)
+	return slices.Compact(frameworks)
+}
+
+// inFrameworks reports whether an issue's source rule maps to any of the given
+// frameworks. A framework matches if its name starts with one of them,
+// ignoring case, so "NIST" selects every NIST framework. An empty list
+// selects every issue.
+func inFrameworks(issue wiz.Issue, frameworks []string) bool {
+	if len(frameworks) == 0 {
+		return true
+	}
+	for _, name := range issueFrameworks(issue) {
+		if frameworkMatches(name, frameworks) {
+			return true
+		}
+	}
+	return false
+}
+
+// frameworkMatches reports whether a framework name starts with one of the
+// given frameworks, ignoring case.
+func frameworkMatches(name string, frameworks []string) bool {
+	for _, framework := range frameworks {
+		if len(name) >= len(framework) && strings.EqualFold(name[:len(framework)], framework) {
+			return true
+		}
+	}
+	return false
+}
+
+// frameworkIDs resolves the configured framework names to the IDs of the Wiz
+// security frameworks they match, with the same prefix matching as
+// inFrameworks, so that issues can be filtered by framework in Wiz. It returns
+// an error if no framework matches, as the filter would select no issue.
+func frameworkIDs(ctx context.Context, client wiz.Client, frameworks []string) ([]string, error) {
+	var ids []string
+	var cursor *string
+	for {
+		resp, err := client.ListSecurityFrameworks(ctx, cursor)
+		if err != nil {
+			return nil, fmt.Errorf("baton-wiz-insights: failed to list security frameworks: %w", err)
+		}
+		for _, framework := range resp.Nodes {
+			if frameworkMatches(framework.Name, frameworks) {
+				ids = append(ids, framework.ID)
+			}
+		}
+		// If EndCursor is empty despite HasNextPage, stop paginating to avoid an infinite loop.
+		if !resp.PageInfo.HasNextPage || resp.PageInfo.EndCursor == "" {
+			break
+		}
+		cursor = &resp.PageInfo.EndCursor
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("baton-wiz-insights: no security framework matches issue frameworks %q", frameworks)
+	}
+	return ids, nil
+}
+
+// withFrameworkMappings fills in the framework mappings of issues read from a
+// report, which only have the ID and name of their source rule, by fetching
+// the issues' details by ID.
+func withFrameworkMappings(ctx context.Context, client wiz.Client, issues []wiz.Issue) ([]wiz.Issue, error) {
+	if len(issues) == 0 {
+		return issues, nil
+	}
+
+	ids := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		ids = append(ids, issue.ID)
+	}
+	details, err := client.GetIssues(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("baton-wiz-insights: failed to get framework mappings of issues: %w", err)
+	}
+
+	subCategories := make(map[string][]wiz.SecuritySubCategory, len(details))
+	for _, issue := range details {
+		subCategories[issue.ID] = issue.SourceRule.SecuritySubCategories
+	}
+	for n := range issues {
+		issues[n].SourceRule.SecuritySubCategories = subCategories[issues[n].ID]
+	}
+	return issues, nil
+}
@@ -0,0 +1,104 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mappedIssue(id string, frameworks ...string) wiz.Issue {
+	issue := wiz.Issue{
+		ID:             id,
+		Status:         wiz.IssueStatusOpen,
+		Severity:       wiz.IssueSeverityHigh,
+		SourceRule:     wiz.SourceRule{ID: "rule-" + id, Name: "User without MFA"},
+		EntitySnapshot: wiz.EntitySnapshot{ID: "entity-" + id, Type: "USER_ACCOUNT"},
+	}
+	for _, framework := range frameworks {
+		issue.SourceRule.SecuritySubCategories = append(issue.SourceRule.SecuritySubCategories, wiz.SecuritySubCategory{
+			Title:      "MFA is enabled",
+			ExternalID: "1.10",
+			Category: wiz.SecurityCategory{
+				Name:      "Identity and Access Management",
+				Framework: wiz.SecurityFramework{Name: framework},
+			},
+		})
+	}
+	return issue
+}
+
+func TestInFrameworks(t *testing.T) {
+	issue := mappedIssue("a", "CIS AWS 2.0.0", "NIST 800-53 Rev 5")
+
+	assert.True(t, inFrameworks(issue, nil))
+	assert.True(t, inFrameworks(issue, []string{"cis"}))
+	assert.True(t, inFrameworks(issue, []string{"SOC 2", "NIST"}))
+	assert.False(t, inFrameworks(issue, []string{"SOC 2"}))
+	assert.False(t, inFrameworks(mappedIssue("b"), []string{"CIS"}))
+}
+
+// frameworksClient serves a fixed list of security frameworks, and records the
+// frameworks that issues are listed for.
+type frameworksClient struct {
+	expiringCursorClient
+
+	frameworks   []wiz.SecurityFramework
+	frameworkIDs [][]string
+}
+
+func (c *frameworksClient) ListSecurityFrameworks(_ context.Context, _ *string) (*wiz.SecurityFrameworkConnection, error) {
+	return &wiz.SecurityFrameworkConnection{Nodes: c.frameworks}, nil
+}
+
+func (c *frameworksClient) ListIssues(ctx context.Context, scope wiz.IssueScope, cursor *string) (*wiz.IssueConnection, error) {
+	c.frameworkIDs = append(c.frameworkIDs, scope.FrameworkIDs)
+	return c.expiringCursorClient.ListIssues(ctx, scope, cursor)
+}
+
+func TestIssueBuilderFiltersFrameworks(t *testing.T) {
+	ctx := context.Background()
+
+	client := &frameworksClient{
+		expiringCursorClient: expiringCursorClient{issues: []wiz.Issue{
+			mappedIssue("a", "CIS AWS 2.0.0"),
+			mappedIssue("b", "SOC 2"),
+		}},
+		frameworks: []wiz.SecurityFramework{
+			{ID: "cis-aws", Name: "CIS AWS 2.0.0"},
+			{ID: "soc-2", Name: "SOC 2"},
+			{ID: "cis-gcp", Name: "cis gcp 1.3.0"},
+		},
+	}
+	builder := newIssueBuilder(client, issueSyncOptions{Filter: issueFilter{Frameworks: []string{"CIS"}}})
+
+	resources, _, err := builder.List(ctx, nil, resource.SyncOpAttrs{})
+	require.NoError(t, err)
+
+	// Issues are filtered by the matching frameworks in Wiz, and again by
+	// their mappings.
+	assert.Equal(t, [][]string{{"cis-aws", "cis-gcp"}}, client.frameworkIDs)
+	require.Len(t, resources, 1)
+	assert.Equal(t, "a", resources[0].GetId().GetResource())
+
+	frameworks := profileOf(t, resources[0].GetAnnotations())["frameworks"].GetListValue().GetValues()
+	require.Len(t, frameworks, 1)
+	mapping := frameworks[0].GetStructValue().GetFields()
+	assert.Equal(t, "CIS AWS 2.0.0", mapping["framework"].GetStringValue())
+	assert.Equal(t, "Identity and Access Management", mapping["category"].GetStringValue())
+	assert.Equal(t, "MFA is enabled", mapping["subcategory"].GetStringValue())
+}
+
+func TestIssueBuilderFailsWithoutMatchingFrameworks(t *testing.T) {
+	ctx := context.Background()
+
+	client := &frameworksClient{frameworks: []wiz.SecurityFramework{{ID: "soc-2", Name: "SOC 2"}}}
+	builder := newIssueBuilder(client, issueSyncOptions{Filter: issueFilter{Frameworks: []string{"CIS"}}})
+
+	_, _, err := builder.List(ctx, nil, resource.SyncOpAttrs{})
+	require.Error(t, err)
+	assert.Empty(t, client.frameworkIDs)
+}
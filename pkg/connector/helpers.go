@@ -0,0 +1,42 @@
+package connector
+
+import (
+	"fmt"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// principalExternalID returns the identifier used to match a Wiz principal to
+// app users synced by other connectors. The cloud provider's external ID is
+// preferred, falling back to the Wiz entity ID.
+func principalExternalID(externalID, entityID string) string {
+	if externalID != "" {
+		return externalID
+	}
+	return entityID
+}
+
+// withInsightProfile attaches a profile of additional details to a security
+// insight, or to a resource without a trait. The security insight trait has no
+// profile of its own, so the profile is carried as a separate struct annotation
+// on the resource.
+func withInsightProfile(profile map[string]interface{}) resource.ResourceOption {
+	return func(r *v2.Resource) error {
+		p, err := structpb.NewStruct(profile)
+		if err != nil {
+			return fmt.Errorf("baton-wiz-insights: failed to build insight profile: %w", err)
+		}
+		return resource.WithAnnotation(p)(r)
+	}
+}
+
+// newWarningAnnotation returns a struct annotation describing a recoverable
+// problem, so callers can surface it without failing the request.
+func newWarningAnnotation(code, message string) *structpb.Struct {
+	return &structpb.Struct{Fields: map[string]*structpb.Value{
+		"warning": structpb.NewStringValue(code),
+		"message": structpb.NewStringValue(message),
+	}}
+}
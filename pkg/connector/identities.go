@@ -0,0 +1,113 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+)
+
+type identityBuilder struct {
+	client wiz.Client
+}
+
+func (i *identityBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
+	return identityResourceType
+}
+
+// List returns Wiz principals as identity resources, one page at a time.
+func (i *identityBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, attr resource.SyncOpAttrs) ([]*v2.Resource, *resource.SyncOpResults, error) {
+	var resources []*v2.Resource
+
+	var cursor *string
+	if attr.PageToken.Token != "" {
+		cursor = &attr.PageToken.Token
+	}
+
+	resp, err := i.client.ListIdentities(ctx, cursor)
+	if err != nil {
+		return nil, nil, fmt.Errorf("baton-wiz-insights: failed to list identities: %w", err)
+	}
+
+	for _, node := range resp.Nodes {
+		if len(node.Entities) == 0 {
+			continue
+		}
+
+		identityResource, err := newIdentityResource(node.Entities[0])
+		if err != nil {
+			return nil, nil, err
+		}
+
+		resources = append(resources, identityResource)
+	}
+
+	// If EndCursor is empty despite HasNextPage, stop paginating to avoid an infinite loop.
+	syncResults := &resource.SyncOpResults{}
+	if resp.PageInfo.HasNextPage && resp.PageInfo.EndCursor != "" {
+		syncResults.NextPageToken = resp.PageInfo.EndCursor
+	}
+
+	return resources, syncResults, nil
+}
+
+// Entitlements returns an empty slice for identities.
+func (i *identityBuilder) Entitlements(_ context.Context, _ *v2.Resource, _ resource.SyncOpAttrs) ([]*v2.Entitlement, *resource.SyncOpResults, error) {
+	return nil, nil, nil
+}
+
+// Grants returns an empty slice for identities.
+func (i *identityBuilder) Grants(_ context.Context, _ *v2.Resource, _ resource.SyncOpAttrs) ([]*v2.Grant, *resource.SyncOpResults, error) {
+	return nil, nil, nil
+}
+
+func newIdentityBuilder(client wiz.Client) *identityBuilder {
+	return &identityBuilder{client: client}
+}
+
+// newIdentityResource converts a Wiz principal graph entity into an identity resource.
+func newIdentityResource(entity wiz.GraphEntity) (*v2.Resource, error) {
+	accountType := v2.UserTrait_ACCOUNT_TYPE_HUMAN
+	if entity.Type == "SERVICE_ACCOUNT" {
+		accountType = v2.UserTrait_ACCOUNT_TYPE_SERVICE
+	}
+
+	profile := map[string]interface{}{
+		"wiz_id":          entity.ID,
+		"wiz_type":        entity.Type,
+		"external_id":     entity.ExternalID(),
+		"native_type":     entity.NativeType(),
+		"cloud_platform":  entity.CloudPlatform(),
+		"subscription_id": entity.SubscriptionExternalID(),
+	}
+
+	userOpts := []resource.UserTraitOption{
+		resource.WithUserProfile(profile),
+		resource.WithAccountType(accountType),
+	}
+	if createdAt := entity.CreatedAt(); !createdAt.IsZero() {
+		userOpts = append(userOpts, resource.WithCreatedAt(createdAt))
+	}
+
+	identityResource, err := resource.NewUserResource(
+		entity.Name,
+		identityResourceType,
+		entity.ID,
+		userOpts,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("baton-wiz-insights: failed to create identity resource for entity %s: %w", entity.ID, err)
+	}
+
+	return identityResource, nil
+}
+
+// identityResourceID returns the resource ID of the identity for a Wiz principal entity ID.
+func identityResourceID(entityID string) *v2.ResourceId {
+	return v2.ResourceId_builder{
+		ResourceType: identityResourceType.GetId(),
+		Resource:     entityID,
+	}.Build()
+}
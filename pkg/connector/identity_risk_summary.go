@@ -0,0 +1,394 @@
+package connector
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+)
+
+// maxSummaryTopRules is the number of source rules listed in a risk summary.
+const maxSummaryTopRules = 5
+
+// riskScoreScale controls how quickly the normalized risk score saturates: an
+// identity whose weighted issue score equals the scale scores 63 out of 100.
+const riskScoreScale = 20.0
+
+// severityWeights is the contribution of one open issue of each severity to
+// an identity's weighted risk score.
+var severityWeights = map[string]int{
+	wiz.IssueSeverityCritical:      10,
+	wiz.IssueSeverityHigh:          5,
+	wiz.IssueSeverityMedium:        2,
+	wiz.IssueSeverityLow:           1,
+	wiz.IssueSeverityInformational: 0,
+}
+
+// riskFactorSeverities maps Wiz issue severities to risk factor severities.
+// Risk factors have no informational severity, so informational issues are low.
+var riskFactorSeverities = map[string]v2.RiskFactor_Severity{
+	wiz.IssueSeverityCritical:      v2.RiskFactor_SEVERITY_CRITICAL,
+	wiz.IssueSeverityHigh:          v2.RiskFactor_SEVERITY_HIGH,
+	wiz.IssueSeverityMedium:        v2.RiskFactor_SEVERITY_MEDIUM,
+	wiz.IssueSeverityLow:           v2.RiskFactor_SEVERITY_LOW,
+	wiz.IssueSeverityInformational: v2.RiskFactor_SEVERITY_LOW,
+}
+
+// identityRiskSummary aggregates the open issues of one identity.
+type identityRiskSummary struct {
+	TargetID string
+	Name     string
+
+	SeverityCounts map[string]int
+	Rules          map[string]*ruleIssueCount
+	Frameworks     map[string]*ruleIssueCount
+	OldestIssueAt  time.Time
+}
+
+// ruleIssueCount counts the open issues of an identity raised by one source
+// rule, or mapped to one security framework.
+type ruleIssueCount struct {
+	Name     string
+	Severity string
+	Count    int
+}
+
+func (s *identityRiskSummary) add(issue wiz.Issue) {
+	s.SeverityCounts[issue.Severity]++
+
+	key := cmp.Or(issue.SourceRule.ID, issue.SourceRule.Name)
+	rule, ok := s.Rules[key]
+	if !ok {
+		rule = &ruleIssueCount{Name: issue.SourceRule.Name, Severity: issue.Severity}
+		s.Rules[key] = rule
+	}
+	rule.count(issue)
+
+	for _, name := range issueFrameworks(issue) {
+		framework, ok := s.Frameworks[name]
+		if !ok {
+			framework = &ruleIssueCount{Name: name, Severity: issue.Severity}
+			s.Frameworks[name] = framework
+		}
+		framework.count(issue)
+	}
+
+	if !issue.CreatedAt.IsZero() && (s.OldestIssueAt.IsZero() || issue.CreatedAt.Before(s.OldestIssueAt)) {
+		s.OldestIssueAt = issue.CreatedAt
+	}
+}
+
+// count adds an issue, keeping the severity of the most severe issue.
+func (r *ruleIssueCount) count(issue wiz.Issue) {
+	r.Count++
+	if severityRank(issue.Severity) < severityRank(r.Severity) {
+		r.Severity = issue.Severity
+	}
+}
+
+// openIssues returns the number of open issues in the summary.
+func (s *identityRiskSummary) openIssues() int {
+	total := 0
+	for _, count := range s.SeverityCounts {
+		total += count
+	}
+	return total
+}
+
+// weightedScore returns the sum of the severity weights of the open issues.
+func (s *identityRiskSummary) weightedScore() int {
+	score := 0
+	for severity, count := range s.SeverityCounts {
+		score += severityWeights[severity] * count
+	}
+	return score
+}
+
+// normalizedScore maps the weighted score onto 0-100, saturating so that a
+// handful of critical issues already scores high and more issues still raise it.
+func (s *identityRiskSummary) normalizedScore() uint32 {
+	return uint32(math.Round(100 * (1 - math.Exp(-float64(s.weightedScore())/riskScoreScale))))
+}
+
+// topRules returns the source rules with the most severe, then most numerous,
+// issues, up to maxSummaryTopRules.
+func (s *identityRiskSummary) topRules() []*ruleIssueCount {
+	rules := sortedIssueCounts(s.Rules)
+	return rules[:min(len(rules), maxSummaryTopRules)]
+}
+
+// sortedIssueCounts orders issue counts by most severe, then most numerous, issues.
+func sortedIssueCounts(counts map[string]*ruleIssueCount) []*ruleIssueCount {
+	sorted := make([]*ruleIssueCount, 0, len(counts))
+	for _, c := range counts {
+		sorted = append(sorted, c)
+	}
+	slices.SortFunc(sorted, func(a, b *ruleIssueCount) int {
+		return cmp.Or(
+			cmp.Compare(severityRank(a.Severity), severityRank(b.Severity)),
+			cmp.Compare(b.Count, a.Count),
+			strings.Compare(a.Name, b.Name),
+		)
+	})
+	return sorted
+}
+
+// severityRank orders severities from most severe (0) to unknown (last).
+func severityRank(severity string) int {
+	if i := slices.Index(wiz.IssueSeverities, severity); i >= 0 {
+		return i
+	}
+	return len(wiz.IssueSeverities)
+}
+
+// riskIdentity is the identity a risk summary is about: the principal entity
+// that the identity's other entities belong to.
+type riskIdentity struct {
+	EntityID   string
+	ExternalID string
+	Name       string
+}
+
+// riskIdentitiesByEntity maps the ID of every entity of a page of identities
+// to its identity, whose first entity is the principal.
+func riskIdentitiesByEntity(nodes []wiz.GraphSearchNode) map[string]riskIdentity {
+	identities := make(map[string]riskIdentity)
+	for _, node := range nodes {
+		if len(node.Entities) == 0 {
+			continue
+		}
+		principal := node.Entities[0]
+		identity := riskIdentity{EntityID: principal.ID, ExternalID: principal.ExternalID(), Name: principal.Name}
+		for _, entity := range node.Entities {
+			if _, ok := identities[entity.ID]; entity.ID != "" && !ok {
+				identities[entity.ID] = identity
+			}
+		}
+	}
+	return identities
+}
+
+// summarizeIssuesByIdentity groups open issues by the identity that their
+// entity belongs to, or by their entity if it is not in identities, skipping
+// issues that fail validation or that the filter does not select. A summary
+// targets its principal like the principal's issue insights do, by external
+// ID if the graph or one of its issues has it. Summaries are ordered by
+// target ID.
+func summarizeIssuesByIdentity(issues []wiz.Issue, filter issueFilter, identities map[string]riskIdentity) []*identityRiskSummary {
+	summaries := map[string]*identityRiskSummary{}
+	for _, issue := range issues {
+		if !wiz.IsActiveIssueStatus(issue.Status) || validateIssue(issue) != nil || !filter.matches(issue) {
+			continue
+		}
+
+		identity, ok := identities[issue.EntitySnapshot.ID]
+		if !ok {
+			identity = riskIdentity{EntityID: issue.EntitySnapshot.ID}
+		}
+		if issue.EntitySnapshot.ID == identity.EntityID {
+			identity.ExternalID = cmp.Or(identity.ExternalID, issue.EntitySnapshot.ExternalID)
+			identity.Name = cmp.Or(identity.Name, issue.EntitySnapshot.Name)
+		}
+
+		summary, ok := summaries[identity.EntityID]
+		if !ok {
+			summary = &identityRiskSummary{
+				SeverityCounts: map[string]int{},
+				Rules:          map[string]*ruleIssueCount{},
+				Frameworks:     map[string]*ruleIssueCount{},
+			}
+			summaries[identity.EntityID] = summary
+		}
+		// Only the principal's own issues fill in its external ID and name,
+		// not those of the identity's other entities.
+		summary.TargetID = cmp.Or(summary.TargetID, identity.ExternalID)
+		summary.Name = cmp.Or(summary.Name, identity.Name)
+		summary.add(issue)
+	}
+
+	for entityID, summary := range summaries {
+		summary.TargetID = principalExternalID(summary.TargetID, entityID)
+	}
+
+	result := make([]*identityRiskSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		result = append(result, summary)
+	}
+	slices.SortFunc(result, func(a, b *identityRiskSummary) int {
+		return strings.Compare(a.TargetID, b.TargetID)
+	})
+	return result
+}
+
+type identityRiskSummaryBuilder struct {
+	client wiz.Client
+
+	// filter selects the issues summarized, like the issues synced.
+	filter issueFilter
+}
+
+func (s *identityRiskSummaryBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
+	return identityRiskSummaryResourceType
+}
+
+// List returns the risk summaries of a page of identities, one page at a
+// time. A summary needs every open issue of its identity, so each page lists
+// a page of identities and then the open issues of only those identities,
+// which keeps both the memory used and the queries made per page bounded.
+func (s *identityRiskSummaryBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, attr resource.SyncOpAttrs) ([]*v2.Resource, *resource.SyncOpResults, error) {
+	var cursor *string
+	if attr.PageToken.Token != "" {
+		cursor = &attr.PageToken.Token
+	}
+
+	identities, err := s.client.ListIdentities(ctx, cursor)
+	if err != nil {
+		return nil, nil, fmt.Errorf("baton-wiz-insights: failed to list identities for risk summaries: %w", err)
+	}
+
+	// An identity's issues can be about any of its entities.
+	identitiesByEntity := riskIdentitiesByEntity(identities.Nodes)
+	entityIDs := slices.Sorted(maps.Keys(identitiesByEntity))
+
+	issues, err := s.listOpenIssues(ctx, entityIDs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("baton-wiz-insights: failed to list issues for risk summaries: %w", err)
+	}
+
+	now := time.Now()
+	var resources []*v2.Resource
+	for _, summary := range summarizeIssuesByIdentity(issues, s.filter, identitiesByEntity) {
+		summaryResource, err := newIdentityRiskSummaryResource(summary, now)
+		if err != nil {
+			return nil, nil, err
+		}
+		resources = append(resources, summaryResource)
+	}
+
+	// If EndCursor is empty despite HasNextPage, stop paginating to avoid an infinite loop.
+	syncResults := &resource.SyncOpResults{}
+	if identities.PageInfo.HasNextPage && identities.PageInfo.EndCursor != "" {
+		syncResults.NextPageToken = identities.PageInfo.EndCursor
+	}
+
+	return resources, syncResults, nil
+}
+
+// listOpenIssues lists the open and in-progress issues of the entities with
+// the given IDs.
+func (s *identityRiskSummaryBuilder) listOpenIssues(ctx context.Context, entityIDs []string) ([]wiz.Issue, error) {
+	if len(entityIDs) == 0 {
+		return nil, nil
+	}
+
+	scope := wiz.IssueScope{ActiveOnly: true, EntityIDs: entityIDs}
+	var issues []wiz.Issue
+	pos := &issuePosition{}
+	for {
+		page, hasMore, err := listIssuesAt(ctx, s.client, scope, pos)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, page...)
+		if !hasMore {
+			return issues, nil
+		}
+	}
+}
+
+// Entitlements returns an empty slice for risk summaries.
+func (s *identityRiskSummaryBuilder) Entitlements(_ context.Context, _ *v2.Resource, _ resource.SyncOpAttrs) ([]*v2.Entitlement, *resource.SyncOpResults, error) {
+	return nil, nil, nil
+}
+
+// Grants returns an empty slice for risk summaries.
+func (s *identityRiskSummaryBuilder) Grants(_ context.Context, _ *v2.Resource, _ resource.SyncOpAttrs) ([]*v2.Grant, *resource.SyncOpResults, error) {
+	return nil, nil, nil
+}
+
+func newIdentityRiskSummaryBuilder(client wiz.Client, filter issueFilter) *identityRiskSummaryBuilder {
+	return &identityRiskSummaryBuilder{client: client, filter: filter}
+}
+
+// newIdentityRiskSummaryResource converts a risk summary into a security
+// insight with a normalized risk score, targeted at the same app user as the
+// identity's issue insights. The top rules, and the security frameworks that
+// the issues map to, are its risk factors.
+func newIdentityRiskSummaryResource(summary *identityRiskSummary, now time.Time) (*v2.Resource, error) {
+	topRules := summary.topRules()
+
+	factors := make([]*v2.RiskFactor, 0, len(topRules))
+	profileRules := make([]interface{}, 0, len(topRules))
+	for _, rule := range topRules {
+		factors = append(factors, resource.NewRiskFactor(
+			fmt.Sprintf("%s (%d open)", rule.Name, rule.Count),
+			riskFactorSeverities[rule.Severity],
+		))
+		profileRules = append(profileRules, map[string]interface{}{
+			"name":     rule.Name,
+			"severity": rule.Severity,
+			"count":    rule.Count,
+		})
+	}
+
+	frameworks := sortedIssueCounts(summary.Frameworks)
+	profileFrameworks := make([]interface{}, 0, len(frameworks))
+	for _, framework := range frameworks {
+		factors = append(factors, resource.NewRiskFactor(
+			fmt.Sprintf("%d open issues mapped to %s", framework.Count, framework.Name),
+			riskFactorSeverities[framework.Severity],
+		))
+		profileFrameworks = append(profileFrameworks, map[string]interface{}{
+			"name":     framework.Name,
+			"severity": framework.Severity,
+			"count":    framework.Count,
+		})
+	}
+
+	insightOpts := []resource.SecurityInsightTraitOption{
+		resource.WithNormalizedRiskScore(summary.normalizedScore(), fmt.Sprint(summary.weightedScore())),
+		resource.WithRiskFactors(factors...),
+		resource.WithInsightObservedAt(now),
+		resource.WithInsightAppUserTarget(summary.Name, summary.TargetID),
+	}
+
+	severityCounts := make(map[string]interface{}, len(wiz.IssueSeverities))
+	for _, severity := range wiz.IssueSeverities {
+		severityCounts[strings.ToLower(severity)] = summary.SeverityCounts[severity]
+	}
+
+	profile := map[string]interface{}{
+		"open_issues":     summary.openIssues(),
+		"severity_counts": severityCounts,
+		"top_rules":       profileRules,
+		"frameworks":      profileFrameworks,
+		"weighted_score":  summary.weightedScore(),
+	}
+	if !summary.OldestIssueAt.IsZero() {
+		profile["oldest_open_issue_created_at"] = summary.OldestIssueAt.Format(time.RFC3339)
+		profile["oldest_open_issue_age_days"] = int(now.Sub(summary.OldestIssueAt).Hours() / 24)
+	}
+
+	name := cmp.Or(summary.Name, summary.TargetID)
+	summaryResource, err := resource.NewResource(
+		fmt.Sprintf("Risk summary: %s", name),
+		identityRiskSummaryResourceType,
+		summary.TargetID,
+		resource.WithSecurityInsightTrait(insightOpts...),
+		resource.WithDescription(fmt.Sprintf("%d open issues on %s", summary.openIssues(), name)),
+		withInsightProfile(profile),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("baton-wiz-insights: failed to create risk summary resource for identity %s: %w", summary.TargetID, err)
+	}
+
+	return summaryResource, nil
+}
@@ -0,0 +1,180 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// riskSummaryClient serves identities in pages of two, and the issues of the
+// identities a query is scoped to. An identity with several entities lists
+// their IDs separated by commas, its principal first.
+type riskSummaryClient struct {
+	wiz.Client
+
+	identities []string
+	issues     []wiz.Issue
+	scopes     [][]string
+}
+
+func (c *riskSummaryClient) ListIdentities(_ context.Context, cursor *string) (*wiz.GraphSearchConnection, error) {
+	offset := 0
+	if cursor != nil {
+		if _, err := fmt.Sscanf(*cursor, "%d", &offset); err != nil {
+			return nil, err
+		}
+	}
+	end := min(offset+2, len(c.identities))
+	resp := &wiz.GraphSearchConnection{}
+	for _, id := range c.identities[offset:end] {
+		var entities []wiz.GraphEntity
+		for _, entityID := range strings.Split(id, ",") {
+			entities = append(entities, wiz.GraphEntity{ID: entityID})
+		}
+		resp.Nodes = append(resp.Nodes, wiz.GraphSearchNode{Entities: entities})
+	}
+	if end < len(c.identities) {
+		resp.PageInfo = wiz.PageInfo{HasNextPage: true, EndCursor: fmt.Sprint(end)}
+	}
+	return resp, nil
+}
+
+func (c *riskSummaryClient) ListIssues(_ context.Context, scope wiz.IssueScope, _ *string) (*wiz.IssueConnection, error) {
+	if !scope.ActiveOnly {
+		return nil, fmt.Errorf("risk summaries must only list active issues")
+	}
+	c.scopes = append(c.scopes, scope.EntityIDs)
+
+	resp := &wiz.IssueConnection{}
+	for _, issue := range c.issues {
+		if slices.Contains(scope.EntityIDs, issue.EntitySnapshot.ID) {
+			resp.Nodes = append(resp.Nodes, issue)
+		}
+	}
+	return resp, nil
+}
+
+func TestIdentityRiskSummaryAggregatesIssues(t *testing.T) {
+	ctx := context.Background()
+
+	createdAt := time.Now().Add(-10 * 24 * time.Hour)
+	issue := func(id, entity, severity, rule string, age time.Duration) wiz.Issue {
+		return wiz.Issue{
+			ID:             id,
+			Status:         wiz.IssueStatusOpen,
+			Severity:       severity,
+			CreatedAt:      createdAt.Add(-age),
+			SourceRule:     wiz.SourceRule{ID: rule, Name: "Rule " + rule},
+			EntitySnapshot: wiz.EntitySnapshot{ID: "entity-" + entity, ExternalID: "arn:" + entity, Name: entity},
+		}
+	}
+	invalid := issue("e", "alice", wiz.IssueSeverityCritical, "x", 0)
+	invalid.SourceRule = wiz.SourceRule{}
+	client := &riskSummaryClient{
+		identities: []string{"entity-alice", "entity-carol", "entity-bob"},
+		issues: []wiz.Issue{
+			issue("a", "alice", wiz.IssueSeverityHigh, "mfa", 0),
+			issue("b", "bob", wiz.IssueSeverityLow, "stale", 0),
+			issue("c", "alice", wiz.IssueSeverityCritical, "admin", 48*time.Hour),
+			issue("d", "alice", wiz.IssueSeverityHigh, "mfa", 0),
+			issue("f", "bob", wiz.IssueSeverityInformational, "unused", 0),
+			invalid,
+		},
+	}
+
+	builder := newIdentityRiskSummaryBuilder(client, issueFilter{})
+	var resources []*v2.Resource
+	token := ""
+	for {
+		page, results, err := builder.List(ctx, nil, resource.SyncOpAttrs{PageToken: pagination.Token{Token: token}})
+		require.NoError(t, err)
+		resources = append(resources, page...)
+		if token = results.NextPageToken; token == "" {
+			break
+		}
+	}
+
+	// Each page only lists the open issues of its page of identities.
+	assert.Equal(t, [][]string{{"entity-alice", "entity-carol"}, {"entity-bob"}}, client.scopes)
+	require.Len(t, resources, 2)
+	assert.Equal(t, "arn:alice", resources[0].GetId().GetResource())
+	assert.Equal(t, "arn:bob", resources[1].GetId().GetResource())
+
+	trait, err := resource.GetSecurityInsightTrait(resources[0])
+	require.NoError(t, err)
+	assert.Equal(t, "arn:alice", trait.GetAppUser().GetExternalId())
+
+	// One critical and two high issues weigh 20, the scale of the score.
+	score := trait.GetRiskScore()
+	assert.Equal(t, uint32(63), score.GetNormalizedScore())
+	assert.Equal(t, "20", score.GetSourceScore())
+	require.Len(t, score.GetRiskFactors(), 2)
+	assert.Equal(t, "Rule admin (1 open)", score.GetRiskFactors()[0].GetDescription())
+	assert.Equal(t, v2.RiskFactor_SEVERITY_CRITICAL, score.GetRiskFactors()[0].GetSeverity())
+	assert.Equal(t, "Rule mfa (2 open)", score.GetRiskFactors()[1].GetDescription())
+
+	profile := &structpb.Struct{}
+	annos := annotations.Annotations(resources[0].GetAnnotations())
+	ok, err := annos.Pick(profile)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, float64(3), profile.GetFields()["open_issues"].GetNumberValue())
+	assert.Equal(t, float64(12), profile.GetFields()["oldest_open_issue_age_days"].GetNumberValue())
+	counts := profile.GetFields()["severity_counts"].GetStructValue().GetFields()
+	assert.Equal(t, float64(1), counts["critical"].GetNumberValue())
+	assert.Equal(t, float64(2), counts["high"].GetNumberValue())
+	assert.Equal(t, float64(0), counts["low"].GetNumberValue())
+
+	// Informational issues are low risk factors rather than unspecified ones.
+	trait, err = resource.GetSecurityInsightTrait(resources[1])
+	require.NoError(t, err)
+	for _, factor := range trait.GetRiskScore().GetRiskFactors() {
+		assert.Equal(t, v2.RiskFactor_SEVERITY_LOW, factor.GetSeverity())
+	}
+}
+
+func TestIdentityRiskSummaryCoversEveryEntityOfAnIdentity(t *testing.T) {
+	issue := func(id, entity, externalID string) wiz.Issue {
+		return wiz.Issue{
+			ID:             id,
+			Status:         wiz.IssueStatusOpen,
+			Severity:       wiz.IssueSeverityHigh,
+			SourceRule:     wiz.SourceRule{ID: "rule-" + id, Name: "Rule " + id},
+			EntitySnapshot: wiz.EntitySnapshot{ID: entity, ExternalID: externalID, Name: entity},
+		}
+	}
+	client := &riskSummaryClient{
+		identities: []string{"entity-dave,entity-dave-role"},
+		issues: []wiz.Issue{
+			issue("a", "entity-dave-role", "arn:dave-role"),
+			issue("b", "entity-dave", "arn:dave"),
+		},
+	}
+
+	resources, _, err := newIdentityRiskSummaryBuilder(client, issueFilter{}).List(context.Background(), nil, resource.SyncOpAttrs{})
+	require.NoError(t, err)
+
+	// The issues of both entities are listed and summarized together, for the
+	// identity's principal.
+	assert.Equal(t, [][]string{{"entity-dave", "entity-dave-role"}}, client.scopes)
+	require.Len(t, resources, 1)
+	assert.Equal(t, "arn:dave", resources[0].GetId().GetResource())
+	assert.Equal(t, "Risk summary: entity-dave", resources[0].GetDisplayName())
+
+	trait, err := resource.GetSecurityInsightTrait(resources[0])
+	require.NoError(t, err)
+	assert.Equal(t, "arn:dave", trait.GetAppUser().GetExternalId())
+	assert.Len(t, trait.GetRiskScore().GetRiskFactors(), 2)
+}
@@ -0,0 +1,70 @@
+package connector
+
+import (
+	"strings"
+
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+)
+
+// issueFilter selects the issues synced as security insights, beyond the
+// principal and status filters applied by the Wiz queries. The zero value
+// selects every issue.
+type issueFilter struct {
+	// Frameworks keeps issues whose source rule maps to one of these security
+	// frameworks.
+	Frameworks []string
+
+	// IncludeTags keeps issues whose entity has one of these tags, and
+	// ExcludeTags drops issues whose entity has any of them. Each tag is
+	// either key=value, or a key alone to match any value.
+	IncludeTags []string
+	ExcludeTags []string
+}
+
+// matches reports whether an issue is selected by the filter.
+func (f issueFilter) matches(issue wiz.Issue) bool {
+	if !inFrameworks(issue, f.Frameworks) {
+		return false
+	}
+	tags := issue.EntitySnapshot.Tags
+	if len(f.IncludeTags) > 0 && !hasAnyTag(tags, f.IncludeTags) {
+		return false
+	}
+	return !hasAnyTag(tags, f.ExcludeTags)
+}
+
+// hasAnyTag reports whether tags contain any of the given key=value or key
+// selectors. Keys and values are compared exactly, as cloud providers treat
+// tags as case-sensitive.
+func hasAnyTag(tags map[string]string, selectors []string) bool {
+	for _, selector := range selectors {
+		key, value, hasValue := strings.Cut(selector, "=")
+		v, ok := tags[strings.TrimSpace(key)]
+		if ok && (!hasValue || v == strings.TrimSpace(value)) {
+			return true
+		}
+	}
+	return false
+}
+
+// tagProfile converts an entity's tags into profile values.
+func tagProfile(tags map[string]string) map[string]interface{} {
+	profile := make(map[string]interface{}, len(tags))
+	for key, value := range tags {
+		profile[key] = value
+	}
+	return profile
+}
+
+// ownerEmail returns the value of the owner tag of an entity, if it is set to
+// an email address that can be resolved to a ConductorOne user.
+func ownerEmail(tags map[string]string, ownerTag string) string {
+	if ownerTag == "" {
+		return ""
+	}
+	owner := strings.TrimSpace(tags[ownerTag])
+	if !strings.Contains(owner, "@") {
+		return ""
+	}
+	return owner
+}
@@ -0,0 +1,74 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func taggedIssue(id string, tags map[string]string) wiz.Issue {
+	issue := mappedIssue(id)
+	issue.EntitySnapshot.Name = "svc-" + id
+	issue.EntitySnapshot.ExternalID = "arn:" + id
+	issue.EntitySnapshot.Tags = tags
+	return issue
+}
+
+func TestIssueFilterTags(t *testing.T) {
+	prod := taggedIssue("a", map[string]string{"env": "prod", "team": "payments"})
+	dev := taggedIssue("b", map[string]string{"env": "dev"})
+	untagged := taggedIssue("c", nil)
+
+	include := issueFilter{IncludeTags: []string{"env=prod"}}
+	assert.True(t, include.matches(prod))
+	assert.False(t, include.matches(dev))
+	assert.False(t, include.matches(untagged))
+
+	exclude := issueFilter{ExcludeTags: []string{"team"}}
+	assert.False(t, exclude.matches(prod))
+	assert.True(t, exclude.matches(dev))
+	assert.True(t, exclude.matches(untagged))
+}
+
+func TestIssueResourceOwnerTag(t *testing.T) {
+	ctx := context.Background()
+
+	client := &expiringCursorClient{issues: []wiz.Issue{
+		taggedIssue("a", map[string]string{"env": "prod", "owner": "jane@example.com"}),
+		taggedIssue("b", map[string]string{"env": "prod", "owner": "payments-team"}),
+		taggedIssue("c", map[string]string{"env": "dev"}),
+	}}
+	builder := newIssueBuilder(client, issueSyncOptions{
+		Filter:   issueFilter{IncludeTags: []string{"env=prod"}},
+		OwnerTag: "owner",
+	})
+
+	var resources []string
+	owned := map[string]string{}
+	token := ""
+	for {
+		page, results, err := builder.List(ctx, nil, resource.SyncOpAttrs{PageToken: pagination.Token{Token: token}})
+		require.NoError(t, err)
+		for _, r := range page {
+			trait, err := resource.GetSecurityInsightTrait(r)
+			require.NoError(t, err)
+			resources = append(resources, r.GetId().GetResource())
+			owned[r.GetId().GetResource()] = trait.GetUser().GetEmail() + trait.GetAppUser().GetExternalId()
+
+			assert.Equal(t, "prod", profileOf(t, r.GetAnnotations())["tags"].GetStructValue().GetFields()["env"].GetStringValue())
+		}
+		if token = results.NextPageToken; token == "" {
+			break
+		}
+	}
+
+	assert.Equal(t, []string{"a", "b"}, resources)
+	// Only an owner tag set to an email replaces the entity target.
+	assert.Equal(t, "jane@example.com", owned["a"])
+	assert.Equal(t, "arn:b", owned["b"])
+}
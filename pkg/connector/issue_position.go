@@ -0,0 +1,131 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// issuePosition is the position of a full issue sync in the list of issues,
+// which is ordered by creation time. Besides the Wiz cursor, it records the
+// last issues listed, so that the position can be rebuilt with a filter when
+// Wiz rejects the cursor because it has expired.
+type issuePosition struct {
+	Cursor string `json:"cursor,omitempty"`
+
+	// CreatedAfter is the creation time filter that Cursor was issued for. It
+	// is zero until the position has been rebuilt.
+	CreatedAfter time.Time `json:"created_after,omitempty"`
+
+	// LastCreatedAt is the creation time of the last issue listed, and LastIDs
+	// the IDs of the issues listed with exactly that creation time.
+	LastCreatedAt time.Time `json:"last_created_at,omitempty"`
+	LastIDs       []string  `json:"last_ids,omitempty"`
+}
+
+// decodeIssuePosition parses the page token of an unsharded issue sync. Tokens
+// from before positions were recorded are a bare Wiz cursor.
+func decodeIssuePosition(token string) (*issuePosition, error) {
+	if token == "" {
+		return &issuePosition{}, nil
+	}
+	if !strings.HasPrefix(token, "{") {
+		return &issuePosition{Cursor: token}, nil
+	}
+
+	var p issuePosition
+	if err := json.Unmarshal([]byte(token), &p); err != nil {
+		return nil, fmt.Errorf("baton-wiz-insights: invalid issue sync page token: %w", err)
+	}
+	return &p, nil
+}
+
+func (p *issuePosition) encode() (string, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return "", fmt.Errorf("baton-wiz-insights: failed to encode issue sync page token: %w", err)
+	}
+	return string(data), nil
+}
+
+// seen reports whether an issue was listed before the position.
+func (p *issuePosition) seen(issue wiz.Issue) bool {
+	if issue.CreatedAt.Before(p.LastCreatedAt) {
+		return true
+	}
+	return issue.CreatedAt.Equal(p.LastCreatedAt) && slices.Contains(p.LastIDs, issue.ID)
+}
+
+// advance moves the position past a page of issues, returning the issues
+// that had not been listed yet and whether there are more pages.
+func (p *issuePosition) advance(resp *wiz.IssueConnection) ([]wiz.Issue, bool) {
+	var issues []wiz.Issue
+	for _, issue := range resp.Nodes {
+		// Only a rebuilt position can list issues again.
+		if !p.CreatedAfter.IsZero() && p.seen(issue) {
+			continue
+		}
+		if issue.CreatedAt.After(p.LastCreatedAt) {
+			p.LastCreatedAt = issue.CreatedAt
+			p.LastIDs = nil
+		}
+		p.LastIDs = append(p.LastIDs, issue.ID)
+		issues = append(issues, issue)
+	}
+
+	// If EndCursor is empty despite HasNextPage, stop paginating to avoid an infinite loop.
+	hasMore := resp.PageInfo.HasNextPage && resp.PageInfo.EndCursor != ""
+	if hasMore {
+		p.Cursor = resp.PageInfo.EndCursor
+	} else {
+		p.Cursor = ""
+	}
+	return issues, hasMore
+}
+
+// listIssuesAt lists the page of issues in scope at a position, and advances
+// the position past it.
+func (i *issueBuilder) listIssuesAt(ctx context.Context, scope wiz.IssueScope, pos *issuePosition) ([]wiz.Issue, bool, error) {
+	scope.ActiveOnly = i.opts.ActiveOnly
+	scope.FrameworkIDs = i.frameworkIDs
+	return listIssuesAt(ctx, i.client, scope, pos)
+}
+
+// listIssuesAt lists the page of issues in scope at a position, and advances
+// the position past it. If Wiz rejects the cursor as expired, the position is
+// rebuilt by listing the issues created since the last issue listed, skipping
+// the ones that were already listed. This is the only place that recovers
+// from an expired cursor, for every listing of issues.
+func listIssuesAt(ctx context.Context, client wiz.Client, scope wiz.IssueScope, pos *issuePosition) ([]wiz.Issue, bool, error) {
+	var cursor *string
+	if pos.Cursor != "" {
+		cursor = &pos.Cursor
+	}
+
+	scope.CreatedAfter = pos.CreatedAfter
+	resp, err := client.ListIssues(ctx, scope, cursor)
+	if errors.Is(err, wiz.ErrCursorExpired) && !pos.LastCreatedAt.IsZero() {
+		ctxzap.Extract(ctx).Info("wiz-issues: cursor expired, resuming after the last issue listed",
+			zap.String("last_created_at", pos.LastCreatedAt.Format(time.RFC3339Nano)),
+			zap.Strings("last_ids", pos.LastIDs))
+
+		pos.Cursor = ""
+		pos.CreatedAfter = pos.LastCreatedAt
+		scope.CreatedAfter = pos.CreatedAfter
+		resp, err = client.ListIssues(ctx, scope, nil)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	issues, hasMore := pos.advance(resp)
+	return issues, hasMore, nil
+}
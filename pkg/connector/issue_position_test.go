@@ -0,0 +1,133 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expiringCursorClient serves issues ordered by creation time in pages of two,
+// and rejects the cursors it issued once expired is set.
+type expiringCursorClient struct {
+	wiz.Client
+
+	issues  []wiz.Issue
+	expired bool
+}
+
+func (c *expiringCursorClient) ListIssues(_ context.Context, scope wiz.IssueScope, cursor *string) (*wiz.IssueConnection, error) {
+	if cursor != nil && c.expired {
+		return nil, fmt.Errorf("failed to list issues: %w", wiz.ErrCursorExpired)
+	}
+
+	var issues []wiz.Issue
+	for _, issue := range c.issues {
+		// Like Wiz, the filter has a resolution of one second.
+		if scope.CreatedAfter.IsZero() || !issue.CreatedAt.Before(scope.CreatedAfter.Truncate(time.Second).Add(-time.Second)) {
+			issues = append(issues, issue)
+		}
+	}
+
+	offset := 0
+	if cursor != nil {
+		if _, err := fmt.Sscanf(*cursor, "%d", &offset); err != nil {
+			return nil, err
+		}
+	}
+	end := min(offset+2, len(issues))
+	resp := &wiz.IssueConnection{Nodes: issues[offset:end]}
+	if end < len(issues) {
+		resp.PageInfo = wiz.PageInfo{HasNextPage: true, EndCursor: fmt.Sprint(end)}
+	}
+	return resp, nil
+}
+
+func TestIssueBuilderRebuildsExpiredCursor(t *testing.T) {
+	ctx := context.Background()
+
+	createdAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	issue := func(id string, offset time.Duration) wiz.Issue {
+		return wiz.Issue{ID: id, Severity: wiz.IssueSeverityHigh, CreatedAt: createdAt.Add(offset), SourceRule: wiz.SourceRule{Name: "User without MFA"}, EntitySnapshot: wiz.EntitySnapshot{ID: "entity-" + id}}
+	}
+	client := &expiringCursorClient{issues: []wiz.Issue{
+		issue("a", 0),
+		issue("b", time.Second),
+		issue("c", time.Second),
+		issue("d", 2*time.Second),
+		issue("e", 3*time.Second),
+	}}
+	builder := newIssueBuilder(client, issueSyncOptions{})
+
+	list := func(token string) ([]string, string) {
+		resources, results, err := builder.List(ctx, nil, resource.SyncOpAttrs{PageToken: pagination.Token{Token: token}})
+		require.NoError(t, err)
+		var ids []string
+		for _, r := range resources {
+			ids = append(ids, r.GetId().GetResource())
+		}
+		return ids, results.NextPageToken
+	}
+
+	ids, token := list("")
+	assert.Equal(t, []string{"a", "b"}, ids)
+
+	// The sync is interrupted and the cursor expires before it resumes. The
+	// position is rebuilt from the last issue listed, without listing the
+	// issues created at the same time twice.
+	client.expired = true
+	var rest []string
+	for token != "" {
+		ids, token = list(token)
+		rest = append(rest, ids...)
+		client.expired = false
+	}
+	assert.Equal(t, []string{"c", "d", "e"}, rest)
+}
+
+func TestDecodeIssuePositionAcceptsBareCursor(t *testing.T) {
+	pos, err := decodeIssuePosition("YXJyYXljb25uZWN0aW9uOjk5")
+	require.NoError(t, err)
+	assert.Equal(t, &issuePosition{Cursor: "YXJyYXljb25uZWN0aW9uOjk5"}, pos)
+}
+
+// expiresOnceClient rejects the cursor of the first page it served as
+// expired, once.
+type expiresOnceClient struct {
+	*expiringCursorClient
+
+	calls *int
+}
+
+func (c expiresOnceClient) ListIssues(ctx context.Context, scope wiz.IssueScope, cursor *string) (*wiz.IssueConnection, error) {
+	*c.calls++
+	c.expired = *c.calls == 2
+	return c.expiringCursorClient.ListIssues(ctx, scope, cursor)
+}
+
+func TestListOpenIssuesRebuildsExpiredCursor(t *testing.T) {
+	createdAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	var issues []wiz.Issue
+	for n, id := range []string{"a", "b", "c", "d", "e"} {
+		issues = append(issues, wiz.Issue{ID: id, CreatedAt: createdAt.Add(time.Duration(n/2) * time.Second)})
+	}
+	client := expiresOnceClient{&expiringCursorClient{issues: issues}, new(int)}
+	builder := newIdentityRiskSummaryBuilder(client, issueFilter{})
+
+	listed, err := builder.listOpenIssues(context.Background(), []string{"entity"})
+	require.NoError(t, err)
+
+	// The pages after the first are listed after the cursor expired, without
+	// listing an issue twice.
+	var ids []string
+	for _, issue := range listed {
+		ids = append(ids, issue.ID)
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, ids)
+}
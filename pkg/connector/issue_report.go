@@ -0,0 +1,143 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+const (
+	// issueReportTokenPrefix marks page tokens that read a downloaded issues
+	// report, as opposed to GraphQL cursors.
+	issueReportTokenPrefix = "report:"
+
+	// issueReportPageSize is the number of report rows read per page.
+	issueReportPageSize = 500
+)
+
+// issueReportToken is the position of a sync in a downloaded issues report.
+type issueReportToken struct {
+	Path   string `json:"path"`
+	Offset int64  `json:"offset,omitempty"`
+}
+
+func (t issueReportToken) encode() (string, error) {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return "", fmt.Errorf("baton-wiz-insights: failed to encode issues report page token: %w", err)
+	}
+	return issueReportTokenPrefix + string(data), nil
+}
+
+// startReport exports issues with a Wiz issues report into a temporary file
+// and returns its first page. If the report fails, the sync falls back to the
+// GraphQL API and reports a warning.
+func (i *issueBuilder) startReport(ctx context.Context) ([]*v2.Resource, *resource.SyncOpResults, error) {
+	l := ctxzap.Extract(ctx)
+
+	f, err := os.CreateTemp("", "wiz-issues-report-*.csv")
+	if err != nil {
+		return nil, nil, fmt.Errorf("baton-wiz-insights: failed to create issues report file: %w", err)
+	}
+
+	reportID, err := i.client.ExportIssuesReport(ctx, i.opts.ReportID, i.opts.ActiveOnly, i.opts.ReportTimeout, f)
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write issues report: %w", closeErr)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return i.fallbackToGraphQL(ctx, err)
+	}
+
+	l.Info("wiz-issues: exported issues with a report", zap.String("report_id", reportID))
+
+	token, err := issueReportToken{Path: f.Name()}.encode()
+	if err != nil {
+		return nil, nil, err
+	}
+	return i.listReport(ctx, token)
+}
+
+// listReport returns the next page of a downloaded issues report.
+func (i *issueBuilder) listReport(ctx context.Context, token string) ([]*v2.Resource, *resource.SyncOpResults, error) {
+	var t issueReportToken
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(token, issueReportTokenPrefix)), &t); err != nil {
+		return nil, nil, fmt.Errorf("baton-wiz-insights: invalid issues report page token: %w", err)
+	}
+
+	page, err := wiz.ReadIssueReport(t.Path, t.Offset, issueReportPageSize)
+	if err != nil {
+		os.Remove(t.Path)
+		// Before any row has been synced, the GraphQL API can list the issues
+		// instead. Past that, restarting it would list the synced issues
+		// again, so the page fails; the report may have been removed, for
+		// example if the sync resumed on another host.
+		if t.Offset == 0 {
+			return i.fallbackToGraphQL(ctx, err)
+		}
+		return nil, nil, fmt.Errorf("baton-wiz-insights: failed to read issues report past its first page: %w", err)
+	}
+
+	var issues []wiz.Issue
+	for _, issue := range page.Issues {
+		// The report is filtered like the GraphQL query, but skip any row that
+		// the query would not have returned, including rows without a
+		// resource type.
+		if !i.opts.syncsStatus(issue.Status) || !wiz.IsPrincipalIssue(issue) {
+			continue
+		}
+		issues = append(issues, issue)
+	}
+
+	// Report rows have no framework mappings to filter by.
+	if len(i.opts.Filter.Frameworks) > 0 {
+		var err error
+		issues, err = withFrameworkMappings(ctx, i.client, issues)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	syncResults := &resource.SyncOpResults{}
+	resources, err := i.newInsights(ctx, issues, syncResults)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if page.Done {
+		os.Remove(t.Path)
+		return resources, syncResults, nil
+	}
+
+	t.Offset = page.NextOffset
+	syncResults.NextPageToken, err = t.encode()
+	if err != nil {
+		return nil, nil, err
+	}
+	return resources, syncResults, nil
+}
+
+// fallbackToGraphQL restarts the sync with the GraphQL API after the issues
+// report failed, reporting the failure as a warning.
+func (i *issueBuilder) fallbackToGraphQL(ctx context.Context, reportErr error) ([]*v2.Resource, *resource.SyncOpResults, error) {
+	ctxzap.Extract(ctx).Warn("wiz-issues: issues report failed, falling back to the GraphQL API", zap.Error(reportErr))
+
+	resources, syncResults, err := i.listGraphQL(ctx, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	syncResults.Annotations.Append(newWarningAnnotation(
+		"issues_report_failed",
+		fmt.Sprintf("the issues report failed, so issues were listed with the GraphQL API instead: %s", reportErr),
+	))
+	return resources, syncResults, nil
+}
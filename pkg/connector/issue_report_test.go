@@ -0,0 +1,117 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// reportClient serves an issues report, or fails it, and a single GraphQL page.
+type reportClient struct {
+	wiz.Client
+
+	report    string
+	reportErr error
+}
+
+func (c *reportClient) ExportIssuesReport(_ context.Context, _ string, _ bool, _ time.Duration, w io.Writer) (string, error) {
+	if c.reportErr != nil {
+		return "", c.reportErr
+	}
+	_, err := io.WriteString(w, c.report)
+	return "report-1", err
+}
+
+func (c *reportClient) ListIssues(_ context.Context, _ wiz.IssueScope, _ *string) (*wiz.IssueConnection, error) {
+	return &wiz.IssueConnection{Nodes: []wiz.Issue{
+		{ID: "graphql-1", Severity: wiz.IssueSeverityLow, SourceRule: wiz.SourceRule{Name: "Stale key"}, EntitySnapshot: wiz.EntitySnapshot{ID: "entity-1"}},
+	}}, nil
+}
+
+func listAllIssues(t *testing.T, builder *issueBuilder) ([]string, []*resource.SyncOpResults) {
+	t.Helper()
+
+	var ids []string
+	var pages []*resource.SyncOpResults
+	token := ""
+	for {
+		resources, results, err := builder.List(context.Background(), nil, resource.SyncOpAttrs{PageToken: pagination.Token{Token: token}})
+		require.NoError(t, err)
+		for _, r := range resources {
+			ids = append(ids, r.GetId().GetResource())
+		}
+		pages = append(pages, results)
+		if results.NextPageToken == "" {
+			return ids, pages
+		}
+		token = results.NextPageToken
+	}
+}
+
+func TestIssueBuilderListsReport(t *testing.T) {
+	var report strings.Builder
+	report.WriteString("\ufeffIssue ID,Title,Severity,Status,Created At,Resource Type,Resource Name,Resource external ID\n")
+	for _, row := range []string{
+		`a,User without MFA,HIGH,Open,2025-01-01T12:00:00Z,USER_ACCOUNT,alice,AIDAALICE`,
+		`b,"Key, unused",LOW,In Progress,2025-01-02T12:00:00Z,SERVICE_ACCOUNT,ci,AIDACI`,
+		`c,Public bucket,HIGH,Open,2025-01-03T12:00:00Z,BUCKET,logs,arn:aws:s3:::logs`,
+		`d,User without MFA,HIGH,Resolved,2025-01-04T12:00:00Z,USER_ACCOUNT,bob,AIDABOB`,
+		`f,User without MFA,HIGH,Open,2025-01-04T12:00:00Z,,frank,AIDAFRANK`,
+	} {
+		report.WriteString(row + "\n")
+	}
+	// Read several pages of the report.
+	for n := 0; n < issueReportPageSize; n++ {
+		report.WriteString("e,User without MFA,HIGH,OPEN,2025-01-05T12:00:00Z,USER_ACCOUNT,eve,AIDAEVE\n")
+	}
+
+	builder := newIssueBuilder(&reportClient{report: report.String()}, issueSyncOptions{Report: true, ReportTimeout: time.Minute, ActiveOnly: true})
+	ids, pages := listAllIssues(t, builder)
+
+	require.Len(t, pages, 2)
+	require.Len(t, ids, 2+issueReportPageSize)
+	assert.Equal(t, []string{"a", "b", "e"}, ids[:3])
+	assert.True(t, strings.HasPrefix(pages[0].NextPageToken, issueReportTokenPrefix))
+	assert.Empty(t, pages[1].Annotations)
+}
+
+func TestIssueBuilderFallsBackToGraphQL(t *testing.T) {
+	builder := newIssueBuilder(&reportClient{reportErr: errors.New("report run failed")}, issueSyncOptions{Report: true, ReportTimeout: time.Minute})
+	ids, pages := listAllIssues(t, builder)
+
+	assert.Equal(t, []string{"graphql-1"}, ids)
+	require.Len(t, pages, 1)
+	warning := &structpb.Struct{}
+	ok, err := pages[0].Annotations.Pick(warning)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "issues_report_failed", warning.GetFields()["warning"].GetStringValue())
+
+	// A report that has gone away before its first page also falls back.
+	token, err := issueReportToken{Path: t.TempDir() + "/missing.csv"}.encode()
+	require.NoError(t, err)
+	resources, _, err := builder.List(context.Background(), nil, resource.SyncOpAttrs{PageToken: pagination.Token{Token: token}})
+	require.NoError(t, err)
+	require.Len(t, resources, 1)
+}
+
+func TestIssueBuilderFailsReportGoneMidway(t *testing.T) {
+	builder := newIssueBuilder(&reportClient{}, issueSyncOptions{Report: true, ReportTimeout: time.Minute})
+
+	// Listing the GraphQL API from its first page would sync the issues of
+	// the pages already read again.
+	token, err := issueReportToken{Path: t.TempDir() + "/missing.csv", Offset: 100}.encode()
+	require.NoError(t, err)
+	_, _, err = builder.List(context.Background(), nil, resource.SyncOpAttrs{PageToken: pagination.Token{Token: token}})
+	require.ErrorContains(t, err, "past its first page")
+}
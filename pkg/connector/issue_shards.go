@@ -0,0 +1,196 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+	"golang.org/x/sync/errgroup"
+)
+
+// Ways of splitting the full issue sync into shards.
+const (
+	issueShardBySeverity = "severity"
+	issueShardByProject  = "project"
+)
+
+// validIssueShardBy reports whether shardBy is a supported way of sharding the
+// issue sync, or empty for an unsharded sync.
+func validIssueShardBy(shardBy string) bool {
+	switch shardBy {
+	case "", issueShardBySeverity, issueShardByProject:
+		return true
+	}
+	return false
+}
+
+// issueShard is one independently paginated slice of the issue sync.
+type issueShard struct {
+	Severity  string `json:"severity,omitempty"`
+	ProjectID string `json:"project_id,omitempty"`
+	// NoProject marks the catch-all shards of a sync sharded by project, which
+	// sync the issues that are not in any project. Wiz cannot filter issues
+	// by the absence of a project, so the catch-all is split by severity and
+	// its shards are listed in parallel like the project shards.
+	NoProject bool `json:"no_project,omitempty"`
+	Done      bool `json:"done,omitempty"`
+	issuePosition
+}
+
+func (s issueShard) scope() wiz.IssueScope {
+	return wiz.IssueScope{Severity: s.Severity, ProjectID: s.ProjectID}
+}
+
+// owns reports whether the shard syncs an issue it lists. An issue in several
+// projects is listed by each of them, but only synced by the shard of its
+// owning project, and the catch-all shards only sync issues in no project.
+func (s issueShard) owns(issue wiz.Issue) bool {
+	if s.ProjectID == "" && !s.NoProject {
+		return true
+	}
+	return issueOwningProject(issue) == s.ProjectID
+}
+
+// issueShardToken is the page token of a sharded issue sync. It holds the
+// position of every shard, so the sync can resume from any page.
+type issueShardToken struct {
+	Shards []issueShard `json:"shards"`
+}
+
+func decodeIssueShardToken(token string) (*issueShardToken, error) {
+	var t issueShardToken
+	if err := json.Unmarshal([]byte(token), &t); err != nil {
+		return nil, fmt.Errorf("baton-wiz-insights: invalid issue sync page token: %w", err)
+	}
+	return &t, nil
+}
+
+func (t *issueShardToken) encode() (string, error) {
+	for _, shard := range t.Shards {
+		if !shard.Done {
+			data, err := json.Marshal(t)
+			if err != nil {
+				return "", fmt.Errorf("baton-wiz-insights: failed to encode issue sync page token: %w", err)
+			}
+			return string(data), nil
+		}
+	}
+	// Every shard is exhausted, so there is no next page.
+	return "", nil
+}
+
+// newIssueShardToken returns the starting position of a sync split into the
+// configured shards.
+func (i *issueBuilder) newIssueShardToken(ctx context.Context) (*issueShardToken, error) {
+	t := &issueShardToken{}
+	switch i.opts.ShardBy {
+	case issueShardBySeverity:
+		for _, severity := range wiz.IssueSeverities {
+			t.Shards = append(t.Shards, issueShard{Severity: severity})
+		}
+	case issueShardByProject:
+		var cursor *string
+		for {
+			resp, err := i.client.ListProjects(ctx, cursor)
+			if err != nil {
+				return nil, fmt.Errorf("baton-wiz-insights: failed to list projects: %w", err)
+			}
+			for _, project := range resp.Nodes {
+				t.Shards = append(t.Shards, issueShard{ProjectID: project.ID})
+			}
+			// If EndCursor is empty despite HasNextPage, stop paginating to avoid an infinite loop.
+			if !resp.PageInfo.HasNextPage || resp.PageInfo.EndCursor == "" {
+				break
+			}
+			cursor = &resp.PageInfo.EndCursor
+		}
+		// Order shards by project ID so that the sync does not depend on the
+		// order projects are listed in.
+		slices.SortFunc(t.Shards, func(a, b issueShard) int {
+			return strings.Compare(a.ProjectID, b.ProjectID)
+		})
+		for _, severity := range wiz.IssueSeverities {
+			t.Shards = append(t.Shards, issueShard{NoProject: true, Severity: severity})
+		}
+	}
+	return t, nil
+}
+
+// listSharded returns the next page of every pending shard, up to the
+// configured concurrency, fetching the pages in parallel. Pages are merged in
+// shard order, so the resources returned for a token are deterministic.
+func (i *issueBuilder) listSharded(ctx context.Context, token string) ([]*v2.Resource, *resource.SyncOpResults, error) {
+	var t *issueShardToken
+	var err error
+	if token == "" {
+		t, err = i.newIssueShardToken(ctx)
+	} else {
+		t, err = decodeIssueShardToken(token)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var pending []int
+	for idx, shard := range t.Shards {
+		if !shard.Done && len(pending) < max(i.opts.Concurrency, 1) {
+			pending = append(pending, idx)
+		}
+	}
+
+	pages := make([][]wiz.Issue, len(pending))
+	g, gctx := errgroup.WithContext(ctx)
+	for n, idx := range pending {
+		shard := &t.Shards[idx]
+		g.Go(func() error {
+			issues, hasMore, err := i.listIssuesAt(gctx, shard.scope(), &shard.issuePosition)
+			if err != nil {
+				return fmt.Errorf("baton-wiz-insights: failed to list issues in shard %+v: %w", shard.scope(), err)
+			}
+			// Drop the issues owned by another shard as each page is fetched,
+			// so that only the issues synced are kept while shards are merged.
+			pages[n] = slices.DeleteFunc(issues, func(issue wiz.Issue) bool {
+				return !shard.owns(issue)
+			})
+			shard.Done = !hasMore
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, nil, err
+	}
+
+	var issues []wiz.Issue
+	for _, page := range pages {
+		issues = append(issues, page...)
+	}
+
+	syncResults := &resource.SyncOpResults{}
+	resources, err := i.newInsights(ctx, issues, syncResults)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	syncResults.NextPageToken, err = t.encode()
+	if err != nil {
+		return nil, nil, err
+	}
+	return resources, syncResults, nil
+}
+
+// issueOwningProject returns the lowest ID of the projects an issue is in, or
+// an empty string if it is in none.
+func issueOwningProject(issue wiz.Issue) string {
+	owner := ""
+	for _, project := range issue.Projects {
+		if owner == "" || project.ID < owner {
+			owner = project.ID
+		}
+	}
+	return owner
+}
@@ -0,0 +1,117 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// shardedIssuesClient serves issues in pages of two, keyed by project and
+// filtered by severity, with every issue under the empty project ID.
+type shardedIssuesClient struct {
+	wiz.Client
+
+	mu       sync.Mutex
+	projects []wiz.Project
+	issues   map[string][]wiz.Issue
+	calls    int
+}
+
+func (c *shardedIssuesClient) ListProjects(_ context.Context, _ *string) (*wiz.ProjectConnection, error) {
+	return &wiz.ProjectConnection{Nodes: c.projects}, nil
+}
+
+func (c *shardedIssuesClient) ListIssues(_ context.Context, scope wiz.IssueScope, cursor *string) (*wiz.IssueConnection, error) {
+	c.mu.Lock()
+	c.calls++
+	c.mu.Unlock()
+
+	offset := 0
+	if cursor != nil {
+		_, err := fmt.Sscanf(*cursor, "%d", &offset)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var issues []wiz.Issue
+	for _, issue := range c.issues[scope.ProjectID] {
+		if scope.Severity == "" || issue.Severity == scope.Severity {
+			issues = append(issues, issue)
+		}
+	}
+	end := min(offset+2, len(issues))
+	resp := &wiz.IssueConnection{Nodes: issues[offset:end]}
+	if end < len(issues) {
+		resp.PageInfo = wiz.PageInfo{HasNextPage: true, EndCursor: fmt.Sprint(end)}
+	}
+	return resp, nil
+}
+
+func TestIssueBuilderListsShardsInParallel(t *testing.T) {
+	ctx := context.Background()
+
+	issue := func(id string, projects ...string) wiz.Issue {
+		i := wiz.Issue{ID: id, Severity: wiz.IssueSeverityHigh, SourceRule: wiz.SourceRule{Name: "User without MFA"}, EntitySnapshot: wiz.EntitySnapshot{ID: "entity-" + id}}
+		for _, project := range projects {
+			i.Projects = append(i.Projects, wiz.Project{ID: project})
+		}
+		return i
+	}
+	client := &shardedIssuesClient{
+		// Projects are listed out of order.
+		projects: []wiz.Project{{ID: "p2"}, {ID: "p1"}},
+		issues: map[string][]wiz.Issue{
+			"p1": {issue("a", "p1"), issue("b", "p1", "p2"), issue("c", "p1")},
+			"p2": {issue("b", "p1", "p2"), issue("d", "p2")},
+			"":   {issue("a", "p1"), issue("e")},
+		},
+	}
+	builder := newIssueBuilder(client, issueSyncOptions{ShardBy: issueShardByProject, Concurrency: 3})
+
+	var ids, tokens []string
+	token := ""
+	for {
+		resources, results, err := builder.List(ctx, nil, resource.SyncOpAttrs{PageToken: pagination.Token{Token: token}})
+		require.NoError(t, err)
+		for _, r := range resources {
+			ids = append(ids, r.GetId().GetResource())
+		}
+		if results.NextPageToken == "" {
+			break
+		}
+		token = results.NextPageToken
+		tokens = append(tokens, token)
+	}
+
+	// Issues in several projects are only synced by their first project, and
+	// issues in no project by the catch-all shard of their severity.
+	assert.Equal(t, []string{"a", "b", "d", "c", "e"}, ids)
+	assert.Equal(t, 3+len(wiz.IssueSeverities), client.calls)
+
+	// Resuming from a token returns the same page again.
+	resources, _, err := builder.List(ctx, nil, resource.SyncOpAttrs{PageToken: pagination.Token{Token: tokens[0]}})
+	require.NoError(t, err)
+	require.Len(t, resources, 2)
+	assert.Equal(t, "c", resources[0].GetId().GetResource())
+	assert.Equal(t, "e", resources[1].GetId().GetResource())
+}
+
+func TestIssueBuilderShardsBySeverity(t *testing.T) {
+	builder := newIssueBuilder(nil, issueSyncOptions{ShardBy: issueShardBySeverity, Concurrency: 4})
+	token, err := builder.newIssueShardToken(context.Background())
+	require.NoError(t, err)
+
+	var severities []string
+	for _, shard := range token.Shards {
+		severities = append(severities, shard.Severity)
+	}
+	assert.Equal(t, wiz.IssueSeverities, severities)
+}
@@ -0,0 +1,133 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// Record validation modes.
+const (
+	// recordValidationStrict fails the sync on the first invalid record.
+	recordValidationStrict = "strict"
+	// recordValidationLenient skips invalid records with a warning.
+	recordValidationLenient = "lenient"
+)
+
+// maxSkippedSampleIDs bounds the IDs of skipped records kept for the summary.
+const maxSkippedSampleIDs = 20
+
+// validRecordValidation reports whether mode is a supported validation mode,
+// or empty for the lenient default.
+func validRecordValidation(mode string) bool {
+	switch mode {
+	case "", recordValidationStrict, recordValidationLenient:
+		return true
+	}
+	return false
+}
+
+// validateIssue checks that an issue has the fields a security insight needs.
+// Wiz returns null for the source rule and entity snapshot of some issues,
+// which would otherwise produce insights without a name or target.
+func validateIssue(issue wiz.Issue) error {
+	var problems []string
+	if issue.ID == "" {
+		problems = append(problems, "no id")
+	}
+	if issue.SourceRule.Name == "" {
+		problems = append(problems, "no source rule")
+	}
+	if issue.Severity == "" {
+		problems = append(problems, "no severity")
+	}
+	if issue.EntitySnapshot.ID == "" && issue.EntitySnapshot.ExternalID == "" {
+		problems = append(problems, "no entity snapshot")
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// skippedRecords counts the records skipped by lenient validation during a sync.
+type skippedRecords struct {
+	count     int
+	sampleIDs []string
+}
+
+func (s *skippedRecords) add(id string) {
+	s.count++
+	if len(s.sampleIDs) < maxSkippedSampleIDs {
+		s.sampleIDs = append(s.sampleIDs, id)
+	}
+}
+
+// newInsights validates issues and converts them into security insight
+// resources, leaving out issues that the filter does not select. In strict
+// mode an invalid issue fails the page. In lenient mode it is logged and
+// skipped, and the page's results carry a warning listing the skipped issues.
+func (i *issueBuilder) newInsights(ctx context.Context, issues []wiz.Issue, syncResults *resource.SyncOpResults) ([]*v2.Resource, error) {
+	l := ctxzap.Extract(ctx)
+
+	var resources []*v2.Resource
+	var skipped []string
+	for _, issue := range issues {
+		if !i.opts.Filter.matches(issue) {
+			continue
+		}
+
+		insightResource, err := newValidIssueResource(issue, i.opts)
+		if err != nil {
+			if i.opts.Validation == recordValidationStrict {
+				return nil, err
+			}
+
+			l.Warn("wiz-issues: skipping invalid issue", zap.String("issue_id", issue.ID), zap.Error(err))
+			skipped = append(skipped, issue.ID)
+			i.skipped.add(issue.ID)
+			continue
+		}
+		resources = append(resources, insightResource)
+	}
+
+	if len(skipped) > 0 {
+		syncResults.Annotations.Append(newWarningAnnotation(
+			"invalid_records_skipped",
+			fmt.Sprintf("skipped %d invalid issues: %s", len(skipped), strings.Join(skipped, ", ")),
+		))
+	}
+	return resources, nil
+}
+
+// newValidIssueResource validates an issue and converts it into a security insight resource.
+func newValidIssueResource(issue wiz.Issue, opts issueSyncOptions) (*v2.Resource, error) {
+	if err := validateIssue(issue); err != nil {
+		return nil, fmt.Errorf("baton-wiz-insights: invalid issue %q: %w", issue.ID, err)
+	}
+	return newIssueResource(issue, opts)
+}
+
+// summarizeSkipped logs and reports the issues skipped during the sync once
+// its last page has been listed, and resets the count for the next sync.
+func (i *issueBuilder) summarizeSkipped(ctx context.Context, syncResults *resource.SyncOpResults) {
+	if syncResults.NextPageToken != "" || i.skipped.count == 0 {
+		return
+	}
+
+	ctxzap.Extract(ctx).Warn("wiz-issues: skipped invalid issues during sync",
+		zap.Int("count", i.skipped.count),
+		zap.Strings("sample_issue_ids", i.skipped.sampleIDs))
+	syncResults.Annotations.Append(newWarningAnnotation(
+		"invalid_records_summary",
+		fmt.Sprintf("skipped %d invalid issues during sync, including: %s", i.skipped.count, strings.Join(i.skipped.sampleIDs, ", ")),
+	))
+	i.skipped = skippedRecords{}
+}
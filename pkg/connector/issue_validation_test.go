@@ -0,0 +1,69 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func warnings(t *testing.T, annos annotations.Annotations) []string {
+	t.Helper()
+
+	var codes []string
+	for _, a := range annos {
+		s := &structpb.Struct{}
+		require.NoError(t, a.UnmarshalTo(s))
+		codes = append(codes, s.GetFields()["warning"].GetStringValue())
+	}
+	return codes
+}
+
+func TestIssueBuilderValidatesRecords(t *testing.T) {
+	ctx := context.Background()
+
+	valid := func(id string) wiz.Issue {
+		return wiz.Issue{
+			ID:             id,
+			Severity:       wiz.IssueSeverityHigh,
+			SourceRule:     wiz.SourceRule{Name: "User without MFA"},
+			EntitySnapshot: wiz.EntitySnapshot{ID: "entity-" + id},
+		}
+	}
+	noSourceRule := valid("b")
+	noSourceRule.SourceRule = wiz.SourceRule{}
+	noEntity := valid("d")
+	noEntity.EntitySnapshot = wiz.EntitySnapshot{}
+	client := &expiringCursorClient{issues: []wiz.Issue{valid("a"), noSourceRule, valid("c"), noEntity}}
+
+	t.Run("lenient", func(t *testing.T) {
+		builder := newIssueBuilder(client, issueSyncOptions{Validation: recordValidationLenient})
+
+		resources, results, err := builder.List(ctx, nil, resource.SyncOpAttrs{})
+		require.NoError(t, err)
+		require.Len(t, resources, 1)
+		assert.Equal(t, "a", resources[0].GetId().GetResource())
+		assert.Equal(t, []string{"invalid_records_skipped"}, warnings(t, results.Annotations))
+
+		// The last page also summarizes the issues skipped during the sync.
+		resources, results, err = builder.List(ctx, nil, resource.SyncOpAttrs{PageToken: pagination.Token{Token: results.NextPageToken}})
+		require.NoError(t, err)
+		require.Len(t, resources, 1)
+		assert.Empty(t, results.NextPageToken)
+		assert.Equal(t, []string{"invalid_records_skipped", "invalid_records_summary"}, warnings(t, results.Annotations))
+		assert.Equal(t, skippedRecords{}, builder.skipped)
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		builder := newIssueBuilder(client, issueSyncOptions{Validation: recordValidationStrict})
+
+		_, _, err := builder.List(ctx, nil, resource.SyncOpAttrs{})
+		require.ErrorContains(t, err, `invalid issue "b": no source rule`)
+	})
+}
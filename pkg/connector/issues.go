@@ -3,14 +3,73 @@ package connector
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
 	"github.com/conductorone/baton-sdk/pkg/types/resource"
 	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// issueSyncOptions configures how the full issue sync lists issues.
+type issueSyncOptions struct {
+	// ShardBy splits the sync into shards listed in parallel, up to
+	// Concurrency at a time. It is empty for an unsharded sync.
+	ShardBy     string
+	Concurrency int
+
+	// Report exports issues with a Wiz issues report, rerunning ReportID if
+	// set, and waiting up to ReportTimeout before falling back to GraphQL.
+	Report        bool
+	ReportID      string
+	ReportTimeout time.Duration
+
+	// ActiveOnly only syncs open and in-progress issues, so that resolved and
+	// rejected issues leave the synced scope.
+	ActiveOnly bool
+
+	// Validation is the record validation mode, strict or lenient.
+	Validation string
+
+	// Filter selects the issues synced.
+	Filter issueFilter
+
+	// OwnerTag is the entity tag whose value, an email address, is the user
+	// insights are targeted at instead of the entity. It is empty to always
+	// target the entity.
+	OwnerTag string
+
+	// LinkControls makes the control resource of an issue's source rule the
+	// parent of its insight. It is set when controls are synced.
+	LinkControls bool
+}
+
+// syncsStatus reports whether the sync covers issues with a status.
+func (o issueSyncOptions) syncsStatus(issueStatus string) bool {
+	return !o.ActiveOnly || wiz.IsActiveIssueStatus(issueStatus)
+}
+
+// selects reports whether the sync covers an issue: its status is synced and
+// the filter selects it.
+func (o issueSyncOptions) selects(issue wiz.Issue) bool {
+	return o.syncsStatus(issue.Status) && o.Filter.matches(issue)
+}
+
 type issueBuilder struct {
 	client wiz.Client
+	opts   issueSyncOptions
+
+	// skipped counts the invalid issues skipped during the current sync.
+	skipped skippedRecords
+
+	// frameworkIDs are the IDs of the security frameworks selected by the
+	// filter, resolved on the first GraphQL page listed.
+	frameworkIDs []string
 }
 
 func (i *issueBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
@@ -19,63 +78,98 @@ func (i *issueBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
 
 // List returns Wiz issues as security insight resources, one page at a time.
 func (i *issueBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, attr resource.SyncOpAttrs) ([]*v2.Resource, *resource.SyncOpResults, error) {
+	token := attr.PageToken.Token
+	if token == "" {
+		i.skipped = skippedRecords{}
+	}
+
 	var resources []*v2.Resource
+	var syncResults *resource.SyncOpResults
+	var err error
+	switch {
+	case strings.HasPrefix(token, issueReportTokenPrefix):
+		resources, syncResults, err = i.listReport(ctx, token)
+	case token == "" && i.opts.Report:
+		resources, syncResults, err = i.startReport(ctx)
+	default:
+		resources, syncResults, err = i.listGraphQL(ctx, token)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
 
-	// Get the page token from the sync attributes
-	var cursor *string
-	if attr.PageToken.Token != "" {
-		cursor = &attr.PageToken.Token
+	i.summarizeSkipped(ctx, syncResults)
+	return resources, syncResults, nil
+}
+
+// listGraphQL returns a page of issues listed with the GraphQL API.
+func (i *issueBuilder) listGraphQL(ctx context.Context, token string) ([]*v2.Resource, *resource.SyncOpResults, error) {
+	if len(i.opts.Filter.Frameworks) > 0 && i.frameworkIDs == nil {
+		ids, err := frameworkIDs(ctx, i.client, i.opts.Filter.Frameworks)
+		if err != nil {
+			return nil, nil, err
+		}
+		i.frameworkIDs = ids
+	}
+
+	if i.opts.ShardBy != "" {
+		return i.listSharded(ctx, token)
+	}
+
+	pos, err := decodeIssuePosition(token)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	// Fetch one page of issues
-	resp, err := i.client.ListIssues(ctx, cursor)
+	issues, hasMore, err := i.listIssuesAt(ctx, wiz.IssueScope{}, pos)
 	if err != nil {
 		return nil, nil, fmt.Errorf("baton-wiz-insights: failed to list issues: %w", err)
 	}
 
-	for _, issue := range resp.Nodes {
-		insightOpts := []resource.SecurityInsightTraitOption{
-			resource.WithIssue(issue.SourceRule.Name),
-			resource.WithIssueSeverity(issue.Severity),
-			resource.WithInsightObservedAt(issue.StatusChangedAt),
-		}
+	syncResults := &resource.SyncOpResults{}
+	resources, err := i.newInsights(ctx, issues, syncResults)
+	if err != nil {
+		return nil, nil, err
+	}
 
-		// Target the app user (account) that the issue is about.
-		// Use ExternalID if available, otherwise fall back to the entity snapshot ID.
-		targetID := issue.EntitySnapshot.ExternalID
-		if targetID == "" {
-			targetID = issue.EntitySnapshot.ID
-		}
-		insightOpts = append(insightOpts,
-			resource.WithInsightAppUserTarget(
-				issue.EntitySnapshot.Name,
-				targetID,
-			),
-		)
-
-		displayName := fmt.Sprintf("[%s] %s", issue.Severity, issue.SourceRule.Name)
-
-		insightResource, err := resource.NewSecurityInsightResource(
-			displayName,
-			issueResourceType,
-			issue.ID,
-			insightOpts...,
-		)
+	// Prepare the sync results with next page token if there are more pages.
+	if hasMore {
+		syncResults.NextPageToken, err = pos.encode()
 		if err != nil {
-			return nil, nil, fmt.Errorf("baton-wiz-insights: failed to create security insight resource for issue %s: %w", issue.ID, err)
+			return nil, nil, err
 		}
-
-		resources = append(resources, insightResource)
 	}
 
-	// Prepare the sync results with next page token if there are more pages.
-	// If EndCursor is empty despite HasNextPage, stop paginating to avoid an infinite loop.
-	syncResults := &resource.SyncOpResults{}
-	if resp.PageInfo.HasNextPage && resp.PageInfo.EndCursor != "" {
-		syncResults.NextPageToken = resp.PageInfo.EndCursor
+	return resources, syncResults, nil
+}
+
+// Get fetches a single issue by ID, so that an event-driven refresh can rebuild
+// one insight without a full List pass. Issues that no longer exist or that the
+// sync does not select, such as resolved issues when only active issues are
+// synced, are reported as not found.
+func (i *issueBuilder) Get(ctx context.Context, resourceID *v2.ResourceId, _ *v2.ResourceId) (*v2.Resource, annotations.Annotations, error) {
+	issue, err := i.client.GetIssue(ctx, resourceID.GetResource())
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("baton-wiz-insights: failed to get issue %s: %w", resourceID.GetResource(), err)
+	}
+	if !i.opts.selects(*issue) {
+		return nil, nil, nil
 	}
 
-	return resources, syncResults, nil
+	insightResource, err := newValidIssueResource(*issue, i.opts)
+	if err != nil {
+		if i.opts.Validation == recordValidationStrict {
+			return nil, nil, err
+		}
+
+		ctxzap.Extract(ctx).Warn("wiz-issues: skipping invalid issue", zap.String("issue_id", issue.ID), zap.Error(err))
+		return nil, annotations.New(newWarningAnnotation("invalid_records_skipped", err.Error())), nil
+	}
+	return insightResource, nil, nil
 }
 
 // Entitlements returns an empty slice for issues (security insights don't have entitlements).
@@ -88,6 +182,61 @@ func (i *issueBuilder) Grants(_ context.Context, _ *v2.Resource, _ resource.Sync
 	return nil, nil, nil
 }
 
-func newIssueBuilder(client wiz.Client) *issueBuilder {
-	return &issueBuilder{client: client}
+func newIssueBuilder(client wiz.Client, opts issueSyncOptions) *issueBuilder {
+	return &issueBuilder{client: client, opts: opts}
+}
+
+// newIssueResource converts a Wiz issue into a security insight resource that
+// targets the app user (account) the issue is about, or the user whose email
+// is the value of the entity's owner tag. When controls are synced, the
+// control resource of its source rule is the insight's parent, so insights can
+// be grouped by the control that raised them. The profile also names the
+// control, and lists the framework subcategories the rule maps to and the
+// entity's tags. Issue insights cannot carry risk factors, so the mappings are
+// only in the profile.
+func newIssueResource(issue wiz.Issue, opts issueSyncOptions) (*v2.Resource, error) {
+	insightOpts := []resource.SecurityInsightTraitOption{
+		resource.WithIssue(issue.SourceRule.Name),
+		resource.WithIssueSeverity(issue.Severity),
+		resource.WithInsightObservedAt(issue.StatusChangedAt),
+	}
+
+	profile := map[string]interface{}{
+		"control_id":   issue.SourceRule.ID,
+		"control_name": issue.SourceRule.Name,
+		"frameworks":   frameworkMappings(issue.SourceRule.SecuritySubCategories),
+		"tags":         tagProfile(issue.EntitySnapshot.Tags),
+	}
+
+	// Target the app user (account) that the issue is about, unless the
+	// entity's owner tag names the user responsible for it.
+	// Use ExternalID if available, otherwise fall back to the entity snapshot ID.
+	targetID := principalExternalID(issue.EntitySnapshot.ExternalID, issue.EntitySnapshot.ID)
+	if owner := ownerEmail(issue.EntitySnapshot.Tags, opts.OwnerTag); owner != "" {
+		insightOpts = append(insightOpts, resource.WithInsightUserTarget(owner))
+		profile["principal_name"] = issue.EntitySnapshot.Name
+		profile["principal_external_id"] = targetID
+	} else {
+		insightOpts = append(insightOpts, resource.WithInsightAppUserTarget(issue.EntitySnapshot.Name, targetID))
+	}
+
+	displayName := fmt.Sprintf("[%s] %s", issue.Severity, issue.SourceRule.Name)
+
+	resourceOpts := []resource.ResourceOption{
+		resource.WithSecurityInsightTrait(insightOpts...),
+		withInsightProfile(profile),
+	}
+	if opts.LinkControls && issue.SourceRule.ID != "" {
+		resourceOpts = append(resourceOpts, resource.WithParentResourceID(v2.ResourceId_builder{
+			ResourceType: controlResourceType.Id,
+			Resource:     issue.SourceRule.ID,
+		}.Build()))
+	}
+
+	insightResource, err := resource.NewResource(displayName, issueResourceType, issue.ID, resourceOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("baton-wiz-insights: failed to create security insight resource for issue %s: %w", issue.ID, err)
+	}
+
+	return insightResource, nil
 }
@@ -0,0 +1,104 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// getIssueClient serves a single issue by ID, or a fixed error.
+type getIssueClient struct {
+	wiz.Client
+
+	issue *wiz.Issue
+	err   error
+}
+
+func (c *getIssueClient) GetIssue(_ context.Context, id string) (*wiz.Issue, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	if c.issue == nil || c.issue.ID != id {
+		return nil, status.Errorf(codes.NotFound, "issue %s not found", id)
+	}
+	return c.issue, nil
+}
+
+func TestIssueBuilderGet(t *testing.T) {
+	ctx := context.Background()
+
+	open := mappedIssue("a", "CIS AWS 2.0.0")
+	resolved := mappedIssue("a", "CIS AWS 2.0.0")
+	resolved.Status = wiz.IssueStatusResolved
+	tagged := mappedIssue("a", "CIS AWS 2.0.0")
+	tagged.EntitySnapshot.Tags = map[string]string{"env": "dev"}
+
+	tests := []struct {
+		name    string
+		issue   *wiz.Issue
+		err     error
+		opts    issueSyncOptions
+		found   bool
+		wantErr bool
+	}{
+		{
+			name:  "issues are returned",
+			issue: &open,
+			found: true,
+		},
+		{
+			name: "missing issues are not found",
+		},
+		{
+			name:    "errors are returned",
+			err:     fmt.Errorf("unavailable"),
+			wantErr: true,
+		},
+		{
+			name:  "inactive issues are not found when only active issues are synced",
+			issue: &resolved,
+			opts:  issueSyncOptions{ActiveOnly: true},
+		},
+		{
+			name:  "inactive issues are returned when every status is synced",
+			issue: &resolved,
+			found: true,
+		},
+		{
+			name:  "issues dropped by the tag filter are not found",
+			issue: &tagged,
+			opts:  issueSyncOptions{Filter: issueFilter{ExcludeTags: []string{"env=dev"}}},
+		},
+		{
+			name:  "issues outside the selected frameworks are not found",
+			issue: &open,
+			opts:  issueSyncOptions{Filter: issueFilter{Frameworks: []string{"SOC 2"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := newIssueBuilder(&getIssueClient{issue: tt.issue, err: tt.err}, tt.opts)
+
+			r, annos, err := builder.Get(ctx, &v2.ResourceId{ResourceType: issueResourceType.GetId(), Resource: "a"}, nil)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Empty(t, annos)
+			if !tt.found {
+				assert.Nil(t, r)
+				return
+			}
+			require.NotNil(t, r)
+			assert.Equal(t, "a", r.GetId().GetResource())
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	principalActivityEventFeedID = "wiz_principal_activity_feed"
+
+	// defaultActivityBucket is used when no bucket size is configured.
+	defaultActivityBucket = time.Hour
+)
+
+// principalActivityEventFeed implements connectorbuilder.EventFeed by polling
+// cloud events performed by principals and reporting them as USAGE events.
+// Cloud events are high volume, so they are aggregated into one event per
+// identity and time bucket. Only closed buckets are queried, one at a time,
+// and a bucket is reported once all of its pages have been read, or in parts
+// once its pages have more than maxActivityActors identities.
+type principalActivityEventFeed struct {
+	connector *Connector
+	bucket    time.Duration
+}
+
+func newPrincipalActivityEventFeed(connector *Connector) *principalActivityEventFeed {
+	bucket := connector.activityBucket
+	if bucket <= 0 {
+		bucket = defaultActivityBucket
+	}
+	return &principalActivityEventFeed{connector: connector, bucket: bucket}
+}
+
+func (e *principalActivityEventFeed) EventFeedMetadata(_ context.Context) *v2.EventFeedMetadata {
+	return v2.EventFeedMetadata_builder{
+		Id: principalActivityEventFeedID,
+		SupportedEventTypes: []v2.EventType{
+			v2.EventType_EVENT_TYPE_USAGE,
+		},
+	}.Build()
+}
+
+func (e *principalActivityEventFeed) ListEvents(
+	ctx context.Context,
+	earliestEvent *timestamppb.Timestamp,
+	pToken *pagination.StreamToken,
+) ([]*v2.Event, *pagination.StreamState, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	cursor, annos := decodeStreamCursor[activityCursor](ctx, pToken, earliestEvent, e.connector.cursorOptions)
+
+	// Query one bucket at a time, aligned to bucket boundaries, so a long
+	// lookback is walked in small windows and every bucket is queried once.
+	cursor.Since = cursor.Since.Truncate(e.bucket)
+	windowEnd := cursor.Since.Add(e.bucket)
+	now := time.Now()
+
+	// The current bucket is still open; wait until it closes.
+	if windowEnd.After(now) {
+		nextCursor, err := encodeStreamCursor(cursor)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return nil, &pagination.StreamState{Cursor: nextCursor, HasMore: false}, annos, nil
+	}
+
+	l.Debug("wiz-principal-activity-feed: querying cloud events",
+		zap.String("since", cursor.Since.Format(time.RFC3339)),
+		zap.String("until", windowEnd.Format(time.RFC3339)),
+		zap.String("page_cursor", cursor.PageEndCursor))
+
+	var pageCursor *string
+	if cursor.PageEndCursor != "" {
+		pageCursor = &cursor.PageEndCursor
+	}
+
+	cloudEventsResp, err := e.connector.client.ListCloudEvents(ctx, cursor.Since, windowEnd, pageCursor)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cursor.Activity = aggregateActivity(cursor.Activity, cloudEventsResp.Nodes)
+
+	// Keep the running aggregates in the cursor until the bucket's last page,
+	// so each bucket is reported once with all of its activity. Past
+	// maxActivityActors identities, the aggregates so far are reported as a
+	// part of the bucket and the bucket restarts, which keeps the cursor small.
+	// If EndCursor is empty despite HasNextPage, stop paginating to avoid an infinite loop.
+	hasNextPage := cloudEventsResp.PageInfo.HasNextPage && cloudEventsResp.PageInfo.EndCursor != ""
+	var events []*v2.Event
+	if !hasNextPage || len(cursor.Activity) >= maxActivityActors {
+		for _, b := range cursor.Activity {
+			event, err := newPrincipalActivityEvent(b, cursor.Since, e.bucket, cursor.ActivityPart)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			events = append(events, event)
+		}
+		cursor.Activity = nil
+		cursor.ActivityPart++
+	}
+	if hasNextPage {
+		cursor.PageEndCursor = cloudEventsResp.PageInfo.EndCursor
+	} else {
+		cursor.Since = windowEnd
+		cursor.PageEndCursor = ""
+		cursor.ActivityPart = 0
+	}
+
+	// There is more to read while a page or a closed bucket remains.
+	hasMore := cursor.PageEndCursor != "" || !cursor.Since.Add(e.bucket).After(now)
+
+	nextCursor, err := encodeStreamCursor(cursor)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	l.Debug("wiz-principal-activity-feed: processed cloud events",
+		zap.Int("cloud_events", len(cloudEventsResp.Nodes)),
+		zap.Int("count", len(events)),
+		zap.Bool("has_more", hasMore))
+
+	return events, &pagination.StreamState{Cursor: nextCursor, HasMore: hasMore}, annos, nil
+}
+
+const (
+	// maxActivityEventNames bounds the distinct cloud event names kept per
+	// bucket, which keeps the running aggregates in the cursor small.
+	maxActivityEventNames = 50
+
+	// maxActivityActors bounds the identities whose running aggregates are
+	// carried in the cursor between pages of a bucket.
+	maxActivityActors = 100
+)
+
+// activityActorProperties are the actor properties kept in the running
+// aggregates, the ones the identity resource of an event is built from.
+var activityActorProperties = []string{"externalId", "nativeType", "cloudPlatform", "subscriptionExternalId", "creationDate"}
+
+// activityBucket accumulates the cloud events of one identity in the bucket
+// being queried. Buckets are carried in the cursor between pages.
+type activityBucket struct {
+	Actor      wiz.GraphEntity `json:"actor"`
+	LastSeen   time.Time       `json:"last_seen"`
+	Count      int             `json:"count"`
+	EventNames []string        `json:"event_names,omitempty"`
+	Platforms  []string        `json:"cloud_platforms,omitempty"`
+}
+
+// aggregateActivity adds a page of cloud events to the running aggregates of
+// the bucket being queried, one per actor in the order actors are first seen.
+func aggregateActivity(buckets []activityBucket, cloudEvents []wiz.CloudEvent) []activityBucket {
+	index := make(map[string]int, len(buckets))
+	for i, b := range buckets {
+		index[b.Actor.ID] = i
+	}
+
+	for _, cloudEvent := range cloudEvents {
+		if cloudEvent.Actor == nil || !wiz.IsPrincipalEntityType(cloudEvent.Actor.Type) {
+			continue
+		}
+
+		i, ok := index[cloudEvent.Actor.ID]
+		if !ok {
+			actor := *cloudEvent.Actor
+			actor.Properties = make(map[string]interface{}, len(activityActorProperties))
+			for _, key := range activityActorProperties {
+				if v, ok := cloudEvent.Actor.Properties[key]; ok {
+					actor.Properties[key] = v
+				}
+			}
+			i = len(buckets)
+			index[actor.ID] = i
+			buckets = append(buckets, activityBucket{Actor: actor})
+		}
+
+		b := &buckets[i]
+		b.Count++
+		if len(b.EventNames) < maxActivityEventNames {
+			b.EventNames = addSorted(b.EventNames, cloudEvent.Name)
+		}
+		if cloudEvent.CloudPlatform != "" {
+			b.Platforms = addSorted(b.Platforms, cloudEvent.CloudPlatform)
+		}
+		if cloudEvent.Timestamp.After(b.LastSeen) {
+			b.LastSeen = cloudEvent.Timestamp
+		}
+	}
+	return buckets
+}
+
+// addSorted inserts a value into a sorted set of values.
+func addSorted(values []string, value string) []string {
+	i, found := slices.BinarySearch(values, value)
+	if found {
+		return values
+	}
+	return slices.Insert(values, i, value)
+}
+
+// newPrincipalActivityEvent builds a USAGE event for an identity's activity in
+// the bucket starting at start. The identity is both actor and target, and is
+// identified with the same normalized external ID that insights use for their
+// app user target. A bucket reported in parts has an event per identity and
+// part, each counting the activity in its part.
+func newPrincipalActivityEvent(b activityBucket, start time.Time, bucketSize time.Duration, part int) (*v2.Event, error) {
+	identity, err := newIdentityResource(b.Actor)
+	if err != nil {
+		return nil, err
+	}
+
+	externalID := principalExternalID(b.Actor.ExternalID(), b.Actor.ID)
+
+	target := v2.SecurityInsightTrait_AppUserTarget_builder{
+		ExternalId: externalID,
+	}.Build()
+
+	details, err := structpb.NewStruct(map[string]interface{}{
+		"actor_id":          b.Actor.ID,
+		"actor_external_id": externalID,
+		"actor_type":        b.Actor.Type,
+		"bucket_start":      start.Format(time.RFC3339),
+		"bucket_end":        start.Add(bucketSize).Format(time.RFC3339),
+		"bucket_part":       part,
+		"event_count":       b.Count,
+		"event_names":       stringValues(b.EventNames),
+		"cloud_platforms":   stringValues(b.Platforms),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("baton-wiz-insights: failed to build details for activity of %s: %w", b.Actor.ID, err)
+	}
+
+	id := fmt.Sprintf("principal-activity-%s-%s", start.Format(time.RFC3339), b.Actor.ID)
+	if part > 0 {
+		id = fmt.Sprintf("%s-%d", id, part)
+	}
+
+	return v2.Event_builder{
+		Id:         id,
+		OccurredAt: timestamppb.New(b.LastSeen),
+		UsageEvent: v2.UsageEvent_builder{
+			TargetResource: identity,
+			ActorResource:  identity,
+		}.Build(),
+		Annotations: annotations.New(target, details),
+	}.Build(), nil
+}
+
+// stringValues returns strings typed for structpb.
+func stringValues(values []string) []interface{} {
+	result := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		result = append(result, v)
+	}
+	return result
+}
@@ -0,0 +1,185 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// cloudEventsClient serves the cloud events in a window in pages of two, and
+// records the windows queried.
+type cloudEventsClient struct {
+	wiz.Client
+
+	events  []wiz.CloudEvent
+	windows [][2]time.Time
+}
+
+func (c *cloudEventsClient) ListCloudEvents(_ context.Context, after, before time.Time, cursor *string) (*wiz.CloudEventConnection, error) {
+	offset := 0
+	if cursor != nil {
+		if _, err := fmt.Sscanf(*cursor, "%d", &offset); err != nil {
+			return nil, err
+		}
+	} else {
+		c.windows = append(c.windows, [2]time.Time{after, before})
+	}
+
+	var events []wiz.CloudEvent
+	for _, event := range c.events {
+		if !event.Timestamp.Before(after) && event.Timestamp.Before(before) {
+			events = append(events, event)
+		}
+	}
+	end := min(offset+2, len(events))
+	resp := &wiz.CloudEventConnection{Nodes: events[offset:end]}
+	if end < len(events) {
+		resp.PageInfo = wiz.PageInfo{HasNextPage: true, EndCursor: fmt.Sprint(end)}
+	}
+	return resp, nil
+}
+
+func TestAggregateActivity(t *testing.T) {
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	alice := &wiz.GraphEntity{ID: "alice", Type: "USER_ACCOUNT", Properties: map[string]interface{}{"externalId": "AIDAALICE", "tags": "dropped"}}
+	bob := &wiz.GraphEntity{ID: "bob", Type: "SERVICE_ACCOUNT"}
+	bucket := &wiz.GraphEntity{ID: "logs", Type: "BUCKET"}
+
+	tests := []struct {
+		name   string
+		pages  [][]wiz.CloudEvent
+		counts map[string]int
+		names  map[string][]string
+	}{
+		{
+			name:   "no events",
+			pages:  [][]wiz.CloudEvent{nil},
+			counts: map[string]int{},
+		},
+		{
+			name: "non-principal actors are skipped",
+			pages: [][]wiz.CloudEvent{{
+				{Name: "GetObject", Timestamp: at, Actor: bucket},
+				{Name: "GetObject", Timestamp: at, Actor: nil},
+			}},
+			counts: map[string]int{},
+		},
+		{
+			name: "a bucket spanning pages is aggregated across them",
+			pages: [][]wiz.CloudEvent{
+				{{Name: "ListBuckets", Timestamp: at, Actor: alice}, {Name: "AssumeRole", Timestamp: at, Actor: bob}},
+				{{Name: "GetObject", Timestamp: at.Add(time.Minute), Actor: alice}, {Name: "ListBuckets", Timestamp: at, Actor: alice}},
+			},
+			counts: map[string]int{"alice": 3, "bob": 1},
+			names:  map[string][]string{"alice": {"GetObject", "ListBuckets"}, "bob": {"AssumeRole"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buckets []activityBucket
+			for _, page := range tt.pages {
+				buckets = aggregateActivity(buckets, page)
+			}
+
+			counts := map[string]int{}
+			for _, b := range buckets {
+				counts[b.Actor.ID] = b.Count
+				if names, ok := tt.names[b.Actor.ID]; ok {
+					assert.Equal(t, names, b.EventNames)
+				}
+				// Only the properties the identity is built from are kept.
+				assert.NotContains(t, b.Actor.Properties, "tags")
+			}
+			assert.Equal(t, tt.counts, counts)
+		})
+	}
+}
+
+func TestPrincipalActivityEventFeedEmitsBucketsOnce(t *testing.T) {
+	ctx := context.Background()
+
+	start := time.Now().UTC().Truncate(time.Hour).Add(-3 * time.Hour)
+	alice := &wiz.GraphEntity{ID: "alice", Type: "USER_ACCOUNT"}
+	client := &cloudEventsClient{events: []wiz.CloudEvent{
+		{Name: "ListBuckets", Timestamp: start.Add(time.Minute), Actor: alice},
+		{Name: "GetObject", Timestamp: start.Add(2 * time.Minute), Actor: alice},
+		{Name: "PutObject", Timestamp: start.Add(3 * time.Minute), Actor: alice},
+		{Name: "GetObject", Timestamp: start.Add(2 * time.Hour), Actor: alice},
+	}}
+	feed := newPrincipalActivityEventFeed(&Connector{client: client, activityBucket: time.Hour})
+
+	var counts []float64
+	var calls int
+	token := &pagination.StreamToken{}
+	for {
+		events, state, _, err := feed.ListEvents(ctx, timestamppb.New(start), token)
+		require.NoError(t, err)
+		calls++
+		for _, event := range events {
+			details := &structpb.Struct{}
+			annos := annotations.Annotations(event.GetAnnotations())
+			_, err := annos.Pick(details)
+			require.NoError(t, err)
+			counts = append(counts, details.GetFields()["event_count"].GetNumberValue())
+		}
+		if !state.HasMore {
+			break
+		}
+		token = &pagination.StreamToken{Cursor: state.Cursor}
+	}
+
+	// The first bucket spans two pages but is reported once with every event,
+	// the empty bucket is reported by no event, and each bucket is its own window.
+	assert.Equal(t, []float64{3, 1}, counts)
+	assert.Equal(t, 4, calls)
+	require.Len(t, client.windows, 3)
+	for n, window := range client.windows {
+		assert.Equal(t, start.Add(time.Duration(n)*time.Hour), window[0])
+		assert.Equal(t, time.Hour, window[1].Sub(window[0]))
+	}
+}
+
+func TestPrincipalActivityEventFeedReportsBusyBucketsInParts(t *testing.T) {
+	ctx := context.Background()
+
+	start := time.Now().UTC().Truncate(time.Hour).Add(-2 * time.Hour)
+	client := &cloudEventsClient{}
+	for n := range maxActivityActors + 50 {
+		actor := &wiz.GraphEntity{ID: fmt.Sprintf("user-%d", n), Type: "USER_ACCOUNT"}
+		client.events = append(client.events, wiz.CloudEvent{Name: "ListBuckets", Timestamp: start.Add(time.Minute), Actor: actor})
+	}
+	feed := newPrincipalActivityEventFeed(&Connector{client: client, activityBucket: time.Hour})
+
+	ids := map[string]bool{}
+	token := &pagination.StreamToken{}
+	for range 200 {
+		events, state, _, err := feed.ListEvents(ctx, timestamppb.New(start), token)
+		require.NoError(t, err)
+		for _, event := range events {
+			ids[event.GetId()] = true
+		}
+
+		// The cursor never carries more than the bounded number of identities.
+		cursor, annos := decodeStreamCursor[activityCursor](ctx, &pagination.StreamToken{Cursor: state.Cursor}, nil, eventCursorOptions{})
+		require.Empty(t, annos)
+		assert.LessOrEqual(t, len(cursor.Activity), maxActivityActors)
+		if !state.HasMore {
+			break
+		}
+		token = &pagination.StreamToken{Cursor: state.Cursor}
+	}
+
+	// Every identity is reported once, the ones past the bound in a second part.
+	assert.Len(t, ids, maxActivityActors+50)
+	assert.True(t, ids[fmt.Sprintf("principal-activity-%s-user-0", start.Format(time.RFC3339))])
+	assert.True(t, ids[fmt.Sprintf("principal-activity-%s-user-%d-1", start.Format(time.RFC3339), maxActivityActors)])
+}
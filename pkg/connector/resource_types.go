@@ -19,3 +19,134 @@ var issueResourceType = &v2.ResourceType{
 		&v2.SkipEntitlementsAndGrants{},
 	),
 }
+
+// identityRiskSummaryResourceType represents an aggregate risk score of the
+// open Wiz issues on each identity, synced as security insights.
+var identityRiskSummaryResourceType = &v2.ResourceType{
+	Id:          "identity-risk-summary",
+	DisplayName: "Identity Risk Summary",
+	Traits:      []v2.ResourceType_Trait{v2.ResourceType_TRAIT_SECURITY_INSIGHT},
+	Annotations: annotations.New(
+		&v2.CapabilityPermissions{
+			Permissions: []*v2.CapabilityPermission{
+				{Permission: "read:issues"},
+				{Permission: "read:resources"},
+			},
+		},
+		&v2.SkipEntitlementsAndGrants{},
+	),
+}
+
+// identityResourceType represents Wiz principals (user and service accounts).
+var identityResourceType = &v2.ResourceType{
+	Id:          "identity",
+	DisplayName: "Identity",
+	Traits:      []v2.ResourceType_Trait{v2.ResourceType_TRAIT_USER},
+	Annotations: annotations.New(
+		&v2.CapabilityPermissions{
+			Permissions: []*v2.CapabilityPermission{
+				{Permission: "read:resources"},
+			},
+		},
+		&v2.SkipEntitlementsAndGrants{},
+	),
+}
+
+// credentialResourceType represents Wiz access keys (AWS IAM access keys,
+// GCP service account keys, Azure app secrets) synced as secrets.
+var credentialResourceType = &v2.ResourceType{
+	Id:          "credential",
+	DisplayName: "Credential",
+	Traits:      []v2.ResourceType_Trait{v2.ResourceType_TRAIT_SECRET},
+	Annotations: annotations.New(
+		&v2.CapabilityPermissions{
+			Permissions: []*v2.CapabilityPermission{
+				{Permission: "read:resources"},
+			},
+		},
+		&v2.SkipEntitlementsAndGrants{},
+	),
+}
+
+// excessiveAccessResourceType represents Wiz excessive access (CIEM) findings
+// synced as least-privilege security insights on the affected principal.
+var excessiveAccessResourceType = &v2.ResourceType{
+	Id:          "excessive-access-insight",
+	DisplayName: "Excessive Access Insight",
+	Traits:      []v2.ResourceType_Trait{v2.ResourceType_TRAIT_SECURITY_INSIGHT},
+	Annotations: annotations.New(
+		&v2.CapabilityPermissions{
+			Permissions: []*v2.CapabilityPermission{
+				{Permission: "read:excessive_access_findings"},
+			},
+		},
+		&v2.SkipEntitlementsAndGrants{},
+	),
+}
+
+// cloudResourceResourceType represents sensitive cloud resources (data stores,
+// KMS keys, admin roles) that identities with open issues have effective
+// access to.
+var cloudResourceResourceType = &v2.ResourceType{
+	Id:          "cloud-resource",
+	DisplayName: "Cloud Resource",
+	Annotations: annotations.New(
+		&v2.CapabilityPermissions{
+			Permissions: []*v2.CapabilityPermission{
+				{Permission: "read:issues"},
+				{Permission: "read:resources"},
+			},
+		},
+	),
+}
+
+// secretFindingResourceType represents Wiz secret findings (cleartext cloud
+// keys, tokens and passwords) synced as security insights.
+var secretFindingResourceType = &v2.ResourceType{
+	Id:          "secret-finding",
+	DisplayName: "Secret Finding",
+	Traits:      []v2.ResourceType_Trait{v2.ResourceType_TRAIT_SECURITY_INSIGHT},
+	Annotations: annotations.New(
+		&v2.CapabilityPermissions{
+			Permissions: []*v2.CapabilityPermission{
+				{Permission: "read:security_scans"},
+			},
+		},
+		&v2.SkipEntitlementsAndGrants{},
+	),
+}
+
+// controlResourceType represents Wiz controls and cloud configuration rules,
+// the rules that raise the issues synced as security insights.
+var controlResourceType = &v2.ResourceType{
+	Id:          "control",
+	DisplayName: "Control",
+	Annotations: annotations.New(
+		&v2.CapabilityPermissions{
+			Permissions: []*v2.CapabilityPermission{
+				{Permission: "read:controls"},
+				{Permission: "read:cloud_configuration"},
+			},
+		},
+		&v2.SkipEntitlementsAndGrants{},
+	),
+}
+
+// wizUserResourceType represents Wiz console users and Wiz service accounts,
+// the principals that act in the Wiz audit log. They are synced with the
+// audit log feed, so that its events refer to them.
+var wizUserResourceType = &v2.ResourceType{
+	Id:          "wiz-user",
+	DisplayName: "Wiz User",
+	Traits:      []v2.ResourceType_Trait{v2.ResourceType_TRAIT_USER},
+	Annotations: annotations.New(
+		&v2.CapabilityPermissions{
+			Permissions: []*v2.CapabilityPermission{
+				{Permission: "admin:audit"},
+				{Permission: "read:users"},
+				{Permission: "read:service_accounts"},
+			},
+		},
+		&v2.SkipEntitlementsAndGrants{},
+	),
+}
@@ -0,0 +1,121 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+)
+
+type secretFindingBuilder struct {
+	client wiz.Client
+}
+
+func (s *secretFindingBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
+	return secretFindingResourceType
+}
+
+// List returns open Wiz secret findings as security insight resources, one page at a time.
+func (s *secretFindingBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, attr resource.SyncOpAttrs) ([]*v2.Resource, *resource.SyncOpResults, error) {
+	var resources []*v2.Resource
+
+	var cursor *string
+	if attr.PageToken.Token != "" {
+		cursor = &attr.PageToken.Token
+	}
+
+	resp, err := s.client.ListSecretInstances(ctx, cursor)
+	if err != nil {
+		return nil, nil, fmt.Errorf("baton-wiz-insights: failed to list secret findings: %w", err)
+	}
+
+	for _, secret := range resp.Nodes {
+		insightResource, err := newSecretFindingResource(secret)
+		if err != nil {
+			return nil, nil, err
+		}
+		resources = append(resources, insightResource)
+	}
+
+	// If EndCursor is empty despite HasNextPage, stop paginating to avoid an infinite loop.
+	syncResults := &resource.SyncOpResults{}
+	if resp.PageInfo.HasNextPage && resp.PageInfo.EndCursor != "" {
+		syncResults.NextPageToken = resp.PageInfo.EndCursor
+	}
+
+	return resources, syncResults, nil
+}
+
+// Entitlements returns an empty slice for secret findings.
+func (s *secretFindingBuilder) Entitlements(_ context.Context, _ *v2.Resource, _ resource.SyncOpAttrs) ([]*v2.Entitlement, *resource.SyncOpResults, error) {
+	return nil, nil, nil
+}
+
+// Grants returns an empty slice for secret findings.
+func (s *secretFindingBuilder) Grants(_ context.Context, _ *v2.Resource, _ resource.SyncOpAttrs) ([]*v2.Grant, *resource.SyncOpResults, error) {
+	return nil, nil, nil
+}
+
+func newSecretFindingBuilder(client wiz.Client) *secretFindingBuilder {
+	return &secretFindingBuilder{client: client}
+}
+
+// newSecretFindingResource converts a Wiz secret instance into a security
+// insight. The insight targets the principal the secret authenticates as, or
+// the resource it was found on when Wiz has not linked it to an identity.
+func newSecretFindingResource(secret wiz.SecretInstance) (*v2.Resource, error) {
+	insightOpts := []resource.SecurityInsightTraitOption{
+		resource.WithIssue(secret.Name),
+		resource.WithIssueSeverity(secret.Severity),
+		resource.WithInsightObservedAt(secret.LastSeenAt),
+	}
+
+	profile := map[string]interface{}{
+		"secret_type":          secret.Type,
+		"status":               secret.Status,
+		"validation_status":    secret.ValidationStatus,
+		"is_valid":             strings.EqualFold(secret.ValidationStatus, wiz.SecretValidationStatusValid),
+		"path":                 secret.Path,
+		"found_on_id":          secret.Resource.ID,
+		"found_on_name":        secret.Resource.Name,
+		"found_on_type":        secret.Resource.Type,
+		"found_on_external_id": secret.Resource.ExternalID(),
+	}
+
+	if identity := secret.RelatedIdentity; identity != nil {
+		insightOpts = append(insightOpts, resource.WithInsightAppUserTarget(
+			identity.Name,
+			principalExternalID(identity.ExternalID(), identity.ID),
+		))
+		profile["principal_name"] = identity.Name
+		profile["principal_external_id"] = principalExternalID(identity.ExternalID(), identity.ID)
+	} else {
+		targetID := secret.Resource.ExternalID()
+		if targetID == "" {
+			targetID = secret.Resource.ID
+		}
+		insightOpts = append(insightOpts, resource.WithInsightExternalResourceTarget(
+			targetID,
+			strings.ToLower(secret.Resource.CloudPlatform()),
+		))
+	}
+
+	displayName := fmt.Sprintf("[%s] %s", secret.Severity, secret.Name)
+
+	insightResource, err := resource.NewResource(
+		displayName,
+		secretFindingResourceType,
+		secret.ID,
+		resource.WithSecurityInsightTrait(insightOpts...),
+		resource.WithDescription(fmt.Sprintf("%s secret found on %s %s", secret.Type, secret.Resource.Type, secret.Resource.Name)),
+		withInsightProfile(profile),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("baton-wiz-insights: failed to create security insight resource for secret finding %s: %w", secret.ID, err)
+	}
+
+	return insightResource, nil
+}
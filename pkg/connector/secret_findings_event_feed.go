@@ -0,0 +1,110 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const secretFindingsEventFeedID = "wiz_secret_findings_feed"
+
+// secretFindingsEventFeed implements connectorbuilder.EventFeed by polling
+// secret findings updated since the last check.
+type secretFindingsEventFeed struct {
+	connector *Connector
+}
+
+func newSecretFindingsEventFeed(connector *Connector) *secretFindingsEventFeed {
+	return &secretFindingsEventFeed{connector: connector}
+}
+
+func (e *secretFindingsEventFeed) EventFeedMetadata(_ context.Context) *v2.EventFeedMetadata {
+	return v2.EventFeedMetadata_builder{
+		Id: secretFindingsEventFeedID,
+		SupportedEventTypes: []v2.EventType{
+			v2.EventType_EVENT_TYPE_RESOURCE_CHANGE,
+		},
+	}.Build()
+}
+
+func (e *secretFindingsEventFeed) ListEvents(
+	ctx context.Context,
+	earliestEvent *timestamppb.Timestamp,
+	pToken *pagination.StreamToken,
+) ([]*v2.Event, *pagination.StreamState, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	cursor, annos := decodeStreamCursor[feedCursor](ctx, pToken, earliestEvent, e.connector.cursorOptions)
+
+	l.Debug("wiz-secret-findings-feed: querying secret findings",
+		zap.String("since", cursor.Since.Format(time.RFC3339)),
+		zap.String("page_cursor", cursor.PageEndCursor))
+
+	var pageCursor *string
+	if cursor.PageEndCursor != "" {
+		pageCursor = &cursor.PageEndCursor
+	}
+
+	secretsResp, err := e.connector.client.ListSecretInstancesSince(ctx, cursor.Since, pageCursor)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var events []*v2.Event
+	for _, secret := range secretsResp.Nodes {
+		events = append(events, newSecretFindingEvent(secret))
+
+		if secret.UpdatedAt.After(cursor.LatestSeen) {
+			cursor.LatestSeen = secret.UpdatedAt
+		}
+	}
+
+	// If EndCursor is empty despite HasNextPage, stop paginating to avoid an infinite loop.
+	hasMore := secretsResp.PageInfo.HasNextPage && secretsResp.PageInfo.EndCursor != ""
+	if hasMore {
+		cursor.PageEndCursor = secretsResp.PageInfo.EndCursor
+	} else {
+		cursor.Since = cursor.LatestSeen
+		cursor.PageEndCursor = ""
+	}
+
+	nextCursor, err := encodeStreamCursor(cursor)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	l.Debug("wiz-secret-findings-feed: processed secret findings",
+		zap.Int("count", len(events)),
+		zap.Bool("has_more", hasMore))
+
+	return events, &pagination.StreamState{Cursor: nextCursor, HasMore: hasMore}, annos, nil
+}
+
+// newSecretFindingEvent builds a RESOURCE_CHANGE event for a secret finding.
+// Resolved findings are marked as no longer existing.
+func newSecretFindingEvent(secret wiz.SecretInstance) *v2.Event {
+	var annos annotations.Annotations
+	if secret.Status == wiz.SecretStatusResolved {
+		annos.Update(&v2.ResourceDoesNotExist{})
+	}
+
+	return v2.Event_builder{
+		Id:         fmt.Sprintf("secret-finding-change-%s-%s", secret.UpdatedAt.Format(time.RFC3339Nano), secret.ID),
+		OccurredAt: timestamppb.New(secret.UpdatedAt),
+		ResourceChangeEvent: v2.ResourceChangeEvent_builder{
+			ResourceId: v2.ResourceId_builder{
+				ResourceType: secretFindingResourceType.GetId(),
+				Resource:     secret.ID,
+			}.Build(),
+		}.Build(),
+		Annotations: annos,
+	}.Build()
+}
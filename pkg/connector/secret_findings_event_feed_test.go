@@ -0,0 +1,91 @@
+package connector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// secretInstancesClient serves fixed pages of secret findings by cursor, and
+// records the cursors queried.
+type secretInstancesClient struct {
+	wiz.Client
+
+	pages   map[string]*wiz.SecretInstanceConnection
+	cursors []string
+}
+
+func (c *secretInstancesClient) ListSecretInstancesSince(_ context.Context, _ time.Time, cursor *string) (*wiz.SecretInstanceConnection, error) {
+	key := ""
+	if cursor != nil {
+		key = *cursor
+	}
+	c.cursors = append(c.cursors, key)
+	return c.pages[key], nil
+}
+
+func TestSecretFindingsEventFeedPaging(t *testing.T) {
+	ctx := context.Background()
+
+	at := time.Now().UTC().Add(-time.Hour)
+	open := wiz.SecretInstance{ID: "s1", Status: "OPEN", UpdatedAt: at}
+	resolved := wiz.SecretInstance{ID: "s2", Status: wiz.SecretStatusResolved, UpdatedAt: at}
+
+	tests := []struct {
+		name    string
+		pages   map[string]*wiz.SecretInstanceConnection
+		removed map[string]bool
+		cursors []string
+	}{
+		{
+			name: "pages are followed until the last one",
+			pages: map[string]*wiz.SecretInstanceConnection{
+				"":   {Nodes: []wiz.SecretInstance{open}, PageInfo: wiz.PageInfo{HasNextPage: true, EndCursor: "p2"}},
+				"p2": {Nodes: []wiz.SecretInstance{resolved}},
+			},
+			removed: map[string]bool{"s1": false, "s2": true},
+			cursors: []string{"", "p2"},
+		},
+		{
+			name: "a next page without a cursor ends paging",
+			pages: map[string]*wiz.SecretInstanceConnection{
+				"": {Nodes: []wiz.SecretInstance{open}, PageInfo: wiz.PageInfo{HasNextPage: true}},
+			},
+			removed: map[string]bool{"s1": false},
+			cursors: []string{""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &secretInstancesClient{pages: tt.pages}
+			feed := newSecretFindingsEventFeed(&Connector{client: client})
+
+			removed := map[string]bool{}
+			token := &pagination.StreamToken{}
+			for range 10 {
+				events, state, _, err := feed.ListEvents(ctx, timestamppb.New(at.Add(-time.Hour)), token)
+				require.NoError(t, err)
+				for _, event := range events {
+					annos := annotations.Annotations(event.GetAnnotations())
+					removed[event.GetResourceChangeEvent().GetResourceId().GetResource()] = annos.Contains(&v2.ResourceDoesNotExist{})
+				}
+				if !state.HasMore {
+					break
+				}
+				token = &pagination.StreamToken{Cursor: state.Cursor}
+			}
+
+			// Resolved findings are reported as no longer existing.
+			assert.Equal(t, tt.removed, removed)
+			assert.Equal(t, tt.cursors, client.cursors)
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+)
+
+// serviceAccountsTokenPrefix marks page tokens that list Wiz service
+// accounts, which are listed once every Wiz user has been listed.
+const serviceAccountsTokenPrefix = "service-accounts:"
+
+// wizUserBuilder syncs the Wiz users and service accounts that audit log
+// events are attributed to.
+type wizUserBuilder struct {
+	client wiz.Client
+}
+
+func (w *wizUserBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
+	return wizUserResourceType
+}
+
+// List returns Wiz users, then Wiz service accounts, as Wiz user resources,
+// one page at a time.
+func (w *wizUserBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, attr resource.SyncOpAttrs) ([]*v2.Resource, *resource.SyncOpResults, error) {
+	token := attr.PageToken.Token
+	serviceAccounts := strings.HasPrefix(token, serviceAccountsTokenPrefix)
+
+	var cursor *string
+	if token = strings.TrimPrefix(token, serviceAccountsTokenPrefix); token != "" {
+		cursor = &token
+	}
+
+	var resp *wiz.UserConnection
+	var err error
+	if serviceAccounts {
+		resp, err = w.client.ListServiceAccounts(ctx, cursor)
+		if err != nil {
+			return nil, nil, fmt.Errorf("baton-wiz-insights: failed to list service accounts: %w", err)
+		}
+	} else {
+		resp, err = w.client.ListUsers(ctx, cursor)
+		if err != nil {
+			return nil, nil, fmt.Errorf("baton-wiz-insights: failed to list Wiz users: %w", err)
+		}
+	}
+
+	var resources []*v2.Resource
+	for _, user := range resp.Nodes {
+		userResource, err := newWizUserResource(user, serviceAccounts)
+		if err != nil {
+			return nil, nil, err
+		}
+		resources = append(resources, userResource)
+	}
+
+	// If EndCursor is empty despite HasNextPage, stop paginating to avoid an infinite loop.
+	syncResults := &resource.SyncOpResults{}
+	switch {
+	case resp.PageInfo.HasNextPage && resp.PageInfo.EndCursor != "":
+		if serviceAccounts {
+			syncResults.NextPageToken = serviceAccountsTokenPrefix + resp.PageInfo.EndCursor
+		} else {
+			syncResults.NextPageToken = resp.PageInfo.EndCursor
+		}
+	case !serviceAccounts:
+		syncResults.NextPageToken = serviceAccountsTokenPrefix
+	}
+
+	return resources, syncResults, nil
+}
+
+// Entitlements returns an empty slice for Wiz users.
+func (w *wizUserBuilder) Entitlements(_ context.Context, _ *v2.Resource, _ resource.SyncOpAttrs) ([]*v2.Entitlement, *resource.SyncOpResults, error) {
+	return nil, nil, nil
+}
+
+// Grants returns an empty slice for Wiz users.
+func (w *wizUserBuilder) Grants(_ context.Context, _ *v2.Resource, _ resource.SyncOpAttrs) ([]*v2.Grant, *resource.SyncOpResults, error) {
+	return nil, nil, nil
+}
+
+func newWizUserBuilder(client wiz.Client) *wizUserBuilder {
+	return &wizUserBuilder{client: client}
+}
+
+// newWizUserResource converts a Wiz user or service account, such as the
+// actor of an audit log entry, into a Wiz user resource.
+func newWizUserResource(actor wiz.AuditLogActor, serviceAccount bool) (*v2.Resource, error) {
+	accountType := v2.UserTrait_ACCOUNT_TYPE_HUMAN
+	if serviceAccount {
+		accountType = v2.UserTrait_ACCOUNT_TYPE_SERVICE
+	}
+
+	userOpts := []resource.UserTraitOption{
+		resource.WithUserProfile(map[string]interface{}{
+			"wiz_id": actor.ID,
+			"email":  actor.Email,
+		}),
+		resource.WithAccountType(accountType),
+	}
+	if actor.Email != "" {
+		userOpts = append(userOpts, resource.WithEmail(actor.Email, true))
+	}
+
+	displayName := actor.Name
+	if displayName == "" {
+		displayName = actor.Email
+	}
+
+	wizUser, err := resource.NewUserResource(displayName, wizUserResourceType, actor.ID, userOpts)
+	if err != nil {
+		return nil, fmt.Errorf("baton-wiz-insights: failed to create Wiz user resource for %s: %w", actor.ID, err)
+	}
+	return wizUser, nil
+}
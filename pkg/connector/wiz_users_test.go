@@ -0,0 +1,55 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wizUsersClient serves a page of Wiz users and a page of service accounts.
+type wizUsersClient struct {
+	wiz.Client
+}
+
+func (c *wizUsersClient) ListUsers(_ context.Context, _ *string) (*wiz.UserConnection, error) {
+	return &wiz.UserConnection{Nodes: []wiz.AuditLogActor{{ID: "u1", Name: "Admin", Email: "admin@example.com"}}}, nil
+}
+
+func (c *wizUsersClient) ListServiceAccounts(_ context.Context, _ *string) (*wiz.UserConnection, error) {
+	return &wiz.UserConnection{Nodes: []wiz.AuditLogActor{{ID: "sa1", Name: "ci"}}}, nil
+}
+
+func TestWizUserBuilderListsUsersThenServiceAccounts(t *testing.T) {
+	ctx := context.Background()
+	builder := newWizUserBuilder(&wizUsersClient{})
+
+	accountTypes := map[string]v2.UserTrait_AccountType{}
+	token := ""
+	for range 10 {
+		resources, results, err := builder.List(ctx, nil, resource.SyncOpAttrs{PageToken: pagination.Token{Token: token}})
+		require.NoError(t, err)
+		for _, r := range resources {
+			trait := &v2.UserTrait{}
+			annos := annotations.Annotations(r.GetAnnotations())
+			ok, err := annos.Pick(trait)
+			require.NoError(t, err)
+			require.True(t, ok)
+			accountTypes[r.GetId().GetResource()] = trait.GetAccountType()
+		}
+		if token = results.NextPageToken; token == "" {
+			break
+		}
+	}
+
+	assert.Equal(t, map[string]v2.UserTrait_AccountType{
+		"u1":  v2.UserTrait_ACCOUNT_TYPE_HUMAN,
+		"sa1": v2.UserTrait_ACCOUNT_TYPE_SERVICE,
+	}, accountTypes)
+}
@@ -0,0 +1,209 @@
+package webhook
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+)
+
+const bufferFileName = "notifications.jsonl"
+
+// ErrBufferClosed is returned when a notification is appended to a closed buffer.
+var ErrBufferClosed = errors.New("webhook buffer is closed")
+
+// Notification is a Wiz issue notification received by the webhook.
+type Notification struct {
+	// Seq orders notifications in the buffer. It only ever increases, even
+	// across restarts and compaction, so it can be stored as a read offset.
+	Seq        int64     `json:"seq"`
+	ReceivedAt time.Time `json:"received_at"`
+	Trigger    Trigger   `json:"trigger"`
+	Issue      wiz.Issue `json:"issue"`
+}
+
+// Buffer is a durable, append-only queue of notifications stored as JSON lines
+// in a single file. Every append is synced to disk before it is acknowledged.
+type Buffer struct {
+	mu      sync.Mutex
+	path    string
+	lastSeq int64
+	closed  bool
+}
+
+// OpenBuffer opens the buffer stored in dir, creating it if needed.
+func OpenBuffer(dir string) (*Buffer, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create webhook buffer directory: %w", err)
+	}
+
+	b := &Buffer{path: filepath.Join(dir, bufferFileName)}
+	notifications, err := b.readAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(notifications) > 0 {
+		b.lastSeq = notifications[len(notifications)-1].Seq
+	}
+
+	return b, nil
+}
+
+// Append assigns a sequence number to the notification and writes it to disk.
+func (b *Buffer) Append(n Notification) (Notification, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return Notification{}, ErrBufferClosed
+	}
+
+	// Sequence numbers are derived from the clock so they keep increasing after
+	// the buffer has been compacted to empty and the process restarted.
+	n.Seq = max(time.Now().UnixNano(), b.lastSeq+1)
+
+	line, err := json.Marshal(n)
+	if err != nil {
+		return Notification{}, fmt.Errorf("failed to marshal webhook notification: %w", err)
+	}
+
+	f, err := os.OpenFile(b.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return Notification{}, fmt.Errorf("failed to open webhook buffer: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return Notification{}, fmt.Errorf("failed to write webhook notification: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return Notification{}, fmt.Errorf("failed to sync webhook buffer: %w", err)
+	}
+
+	b.lastSeq = n.Seq
+	return n, nil
+}
+
+// Close stops the buffer from accepting notifications, so that a request still
+// in flight when the listener stops is not acknowledged. Buffered notifications
+// stay on disk for the next run.
+func (b *Buffer) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	return nil
+}
+
+// ReadAfter returns up to limit notifications with a sequence number greater than seq.
+func (b *Buffer) ReadAfter(seq int64, limit int) ([]Notification, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	notifications, err := b.readAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Notification
+	for _, n := range notifications {
+		if n.Seq <= seq {
+			continue
+		}
+		if len(result) == limit {
+			break
+		}
+		result = append(result, n)
+	}
+	return result, nil
+}
+
+// Compact removes notifications with a sequence number up to and including seq.
+// The remaining notifications are written to a new file that replaces the
+// buffer atomically.
+func (b *Buffer) Compact(seq int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	notifications, err := b.readAll()
+	if err != nil {
+		return err
+	}
+	if len(notifications) == 0 || notifications[0].Seq > seq {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(b.path), bufferFileName+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create webhook buffer: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	for _, n := range notifications {
+		if n.Seq <= seq {
+			continue
+		}
+		line, err := json.Marshal(n)
+		if err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to marshal webhook notification: %w", err)
+		}
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to write webhook buffer: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write webhook buffer: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync webhook buffer: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close webhook buffer: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), b.path); err != nil {
+		return fmt.Errorf("failed to replace webhook buffer: %w", err)
+	}
+	return nil
+}
+
+// readAll reads every notification in the buffer. A line that cannot be parsed
+// can only be a write torn by a crash, which was never acknowledged, so it is skipped.
+func (b *Buffer) readAll() ([]Notification, error) {
+	f, err := os.Open(b.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open webhook buffer: %w", err)
+	}
+	defer f.Close()
+
+	var notifications []Notification
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxPayloadBytes*2)
+	for scanner.Scan() {
+		var n Notification
+		if err := json.Unmarshal(scanner.Bytes(), &n); err != nil {
+			continue
+		}
+		notifications = append(notifications, n)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read webhook buffer: %w", err)
+	}
+
+	return notifications, nil
+}
@@ -0,0 +1,168 @@
+package webhook
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/conductorone/baton-wiz-insights/pkg/wiz"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxPayloadBytes bounds the size of a webhook request body.
+	maxPayloadBytes = 1 << 20
+
+	// secretHeader carries the shared secret for webhook integrations that
+	// cannot send a bearer token.
+	secretHeader = "X-Wiz-Webhook-Secret"
+)
+
+// Trigger describes the Wiz automation rule that sent a notification.
+type Trigger struct {
+	Source   string `json:"source"`
+	Type     string `json:"type"`
+	RuleID   string `json:"ruleId"`
+	RuleName string `json:"ruleName"`
+}
+
+// payload is the body of a Wiz automation rule webhook. The rule's template
+// must render the issue with the same field names as the issuesV2 API.
+type payload struct {
+	Trigger Trigger    `json:"trigger"`
+	Issue   *wiz.Issue `json:"issue"`
+}
+
+// validate checks that the payload describes an issue the event feed can report.
+func (p *payload) validate() error {
+	if p.Issue == nil {
+		return errors.New("payload has no issue")
+	}
+	if p.Issue.ID == "" {
+		return errors.New("issue has no id")
+	}
+	switch p.Issue.Status {
+	case wiz.IssueStatusOpen, wiz.IssueStatusInProgress, wiz.IssueStatusResolved, wiz.IssueStatusRejected:
+	default:
+		return fmt.Errorf("issue has unknown status %q", p.Issue.Status)
+	}
+	// The status change time identifies the change, so that polling and
+	// repeated notifications do not report it again.
+	if p.Issue.StatusChangedAt.IsZero() {
+		return errors.New("issue has no statusChangedAt")
+	}
+	return nil
+}
+
+// Server receives Wiz automation rule notifications over HTTP, authenticates
+// them with a shared secret and buffers them durably for the event feed.
+type Server struct {
+	buffer *Buffer
+	secret string
+	logger *zap.Logger
+	srv    *http.Server
+}
+
+// NewServer returns a server that listens on addr once started. Requests must
+// present secret as a bearer token or in the X-Wiz-Webhook-Secret header.
+func NewServer(addr, secret string, buffer *Buffer, logger *zap.Logger) (*Server, error) {
+	if secret == "" {
+		return nil, errors.New("a webhook secret is required")
+	}
+
+	s := &Server{
+		buffer: buffer,
+		secret: secret,
+		logger: logger,
+	}
+	s.srv = &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s, nil
+}
+
+// Start begins listening in the background. It returns once the listener is open.
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for webhooks on %s: %w", s.srv.Addr, err)
+	}
+
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("wiz-webhook: server stopped", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+// Close stops the server, waiting briefly for in-flight requests to finish.
+func (s *Server) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.srv.Shutdown(ctx)
+}
+
+// Handler returns the HTTP handler that receives notifications.
+func (s *Server) Handler() http.Handler {
+	return http.HandlerFunc(s.handle)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.authenticated(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var p payload
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPayloadBytes)).Decode(&p); err != nil {
+		http.Error(w, fmt.Sprintf("invalid payload: %s", err), http.StatusBadRequest)
+		return
+	}
+	if err := p.validate(); err != nil {
+		http.Error(w, fmt.Sprintf("invalid payload: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	n := Notification{
+		ReceivedAt: time.Now().UTC(),
+		Trigger:    p.Trigger,
+		Issue:      *p.Issue,
+	}
+
+	stored, err := s.buffer.Append(n)
+	if err != nil {
+		s.logger.Error("wiz-webhook: failed to buffer notification", zap.String("issue_id", n.Issue.ID), zap.Error(err))
+		http.Error(w, "failed to store notification", http.StatusInternalServerError)
+		return
+	}
+
+	s.logger.Debug("wiz-webhook: buffered notification",
+		zap.String("issue_id", stored.Issue.ID),
+		zap.String("status", stored.Issue.Status),
+		zap.Int64("seq", stored.Seq))
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// authenticated reports whether the request presents the shared secret.
+func (s *Server) authenticated(r *http.Request) bool {
+	presented := r.Header.Get(secretHeader)
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		presented = token
+	}
+	return subtle.ConstantTimeCompare([]byte(presented), []byte(s.secret)) == 1
+}
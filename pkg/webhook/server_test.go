@@ -0,0 +1,179 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const testSecret = "test-secret"
+
+func newTestServer(t *testing.T) (*httptest.Server, *Buffer, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	buffer, err := OpenBuffer(dir)
+	require.NoError(t, err)
+
+	s, err := NewServer("127.0.0.1:0", testSecret, buffer, zap.NewNop())
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+	return ts, buffer, dir
+}
+
+func post(t *testing.T, url, body string, headers map[string]string) int {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	return resp.StatusCode
+}
+
+const validPayload = `{
+  "trigger": {"source": "ISSUES", "type": "Created", "ruleId": "rule-1", "ruleName": "Notify ConductorOne"},
+  "issue": {
+    "id": "issue-1",
+    "status": "OPEN",
+    "severity": "HIGH",
+    "createdAt": "2025-01-01T12:00:00Z",
+    "statusChangedAt": "2025-01-01T12:00:00Z",
+    "sourceRule": {"id": "cc-1", "name": "User without MFA"},
+    "entitySnapshot": {"id": "entity-1", "type": "USER_ACCOUNT", "name": "alice", "externalId": "AIDAEXAMPLE"}
+  }
+}`
+
+func TestServerAuthentication(t *testing.T) {
+	ts, buffer, _ := newTestServer(t)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    int
+	}{
+		{name: "bearer token", headers: map[string]string{"Authorization": "Bearer " + testSecret}, want: http.StatusAccepted},
+		{name: "secret header", headers: map[string]string{secretHeader: testSecret}, want: http.StatusAccepted},
+		{name: "wrong token", headers: map[string]string{"Authorization": "Bearer wrong"}, want: http.StatusUnauthorized},
+		{name: "basic auth", headers: map[string]string{"Authorization": "Basic " + testSecret}, want: http.StatusUnauthorized},
+		{name: "no credentials", want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, post(t, ts.URL, validPayload, tt.headers))
+		})
+	}
+
+	notifications, err := buffer.ReadAfter(0, 10)
+	require.NoError(t, err)
+	assert.Len(t, notifications, 2)
+}
+
+func TestServerValidatesPayload(t *testing.T) {
+	ts, buffer, _ := newTestServer(t)
+	auth := map[string]string{"Authorization": "Bearer " + testSecret}
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "not json", body: "issue-1"},
+		{name: "no issue", body: `{"trigger": {"type": "Created"}}`},
+		{name: "no issue id", body: `{"issue": {"status": "OPEN"}}`},
+		{name: "unknown status", body: `{"issue": {"id": "issue-1", "status": "SNOOZED"}}`},
+		{name: "no status change time", body: `{"issue": {"id": "issue-1", "status": "RESOLVED"}}`},
+		{name: "too large", body: `{"issue": {"id": "` + strings.Repeat("a", maxPayloadBytes) + `"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, http.StatusBadRequest, post(t, ts.URL, tt.body, auth))
+		})
+	}
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	notifications, err := buffer.ReadAfter(0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, notifications)
+}
+
+func TestServerBuffersNotificationsDurably(t *testing.T) {
+	ts, _, dir := newTestServer(t)
+	auth := map[string]string{"Authorization": "Bearer " + testSecret}
+
+	require.Equal(t, http.StatusAccepted, post(t, ts.URL, validPayload, auth))
+	require.Equal(t, http.StatusAccepted, post(t, ts.URL, `{"issue": {"id": "issue-2", "status": "RESOLVED", "statusChangedAt": "2025-01-01T13:00:00Z"}}`, auth))
+
+	// Notifications survive reopening the buffer, as after a restart.
+	buffer, err := OpenBuffer(dir)
+	require.NoError(t, err)
+	notifications, err := buffer.ReadAfter(0, 10)
+	require.NoError(t, err)
+	require.Len(t, notifications, 2)
+
+	first, second := notifications[0], notifications[1]
+	assert.Equal(t, "issue-1", first.Issue.ID)
+	assert.Equal(t, "HIGH", first.Issue.Severity)
+	assert.Equal(t, "AIDAEXAMPLE", first.Issue.EntitySnapshot.ExternalID)
+	assert.Equal(t, "Created", first.Trigger.Type)
+	assert.Equal(t, time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC), first.Issue.StatusChangedAt)
+	assert.Equal(t, "issue-2", second.Issue.ID)
+	assert.Equal(t, time.Date(2025, 1, 1, 13, 0, 0, 0, time.UTC), second.Issue.StatusChangedAt)
+	assert.Greater(t, second.Seq, first.Seq)
+
+	// Reading is limited and resumes after a sequence number.
+	rest, err := buffer.ReadAfter(first.Seq, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []Notification{second}, rest)
+
+	limited, err := buffer.ReadAfter(0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []Notification{first}, limited)
+
+	// Compacting drops acknowledged notifications, and sequence numbers keep
+	// increasing after the buffer is compacted to empty and reopened.
+	require.NoError(t, buffer.Compact(first.Seq))
+	rest, err = buffer.ReadAfter(0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []Notification{second}, rest)
+
+	require.NoError(t, buffer.Compact(second.Seq))
+	buffer, err = OpenBuffer(dir)
+	require.NoError(t, err)
+	third, err := buffer.Append(Notification{})
+	require.NoError(t, err)
+	assert.Greater(t, third.Seq, second.Seq)
+}
+
+func TestServerRejectsNotificationsAfterBufferCloses(t *testing.T) {
+	ts, buffer, _ := newTestServer(t)
+	auth := map[string]string{"Authorization": "Bearer " + testSecret}
+
+	require.NoError(t, buffer.Close())
+
+	// The notification is not acknowledged, so Wiz retries it.
+	assert.Equal(t, http.StatusInternalServerError, post(t, ts.URL, validPayload, auth))
+	_, err := buffer.Append(Notification{})
+	assert.ErrorIs(t, err, ErrBufferClosed)
+}
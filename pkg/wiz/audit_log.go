@@ -0,0 +1,61 @@
+package wiz
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// AuditLogStatusSuccess is the status of an audited action that succeeded.
+const AuditLogStatusSuccess = "SUCCESS"
+
+const auditLogEntriesQuery = `query AuditLogEntries($after: String, $first: Int, $filterBy: AuditLogEntryFilters) {
+  auditLogEntries(after: $after, first: $first, filterBy: $filterBy) {
+    nodes {
+      id
+      action
+      requestId
+      status
+      timestamp
+      actionParameters
+      userAgent
+      sourceIP
+      user {
+        id
+        name
+        email
+      }
+      serviceAccount {
+        id
+        name
+      }
+    }
+    pageInfo {
+      hasNextPage
+      endCursor
+    }
+  }
+}`
+
+// ListAuditLogEntriesSince retrieves a paginated list of Wiz audit log entries
+// recorded after since.
+func (c *client) ListAuditLogEntriesSince(ctx context.Context, since time.Time, cursor *string) (*AuditLogEntryConnection, error) {
+	variables := map[string]interface{}{
+		"first": 100,
+		"filterBy": map[string]interface{}{
+			"timestamp": map[string]interface{}{
+				"after": since.Format(time.RFC3339),
+			},
+		},
+	}
+	if cursor != nil && *cursor != "" {
+		variables["after"] = *cursor
+	}
+
+	var result auditLogEntriesQueryResponse
+	if err := c.graphQLRequest(ctx, auditLogEntriesQuery, variables, &result); err != nil {
+		return nil, fmt.Errorf("failed to list audit log entries since %s: %w", since.Format(time.RFC3339), err)
+	}
+
+	return &result.AuditLogEntries, nil
+}
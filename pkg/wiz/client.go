@@ -2,18 +2,40 @@ package wiz
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/clientcredentials"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
+// Issue statuses.
+const (
+	IssueStatusOpen       = "OPEN"
+	IssueStatusInProgress = "IN_PROGRESS"
+	IssueStatusResolved   = "RESOLVED"
+	IssueStatusRejected   = "REJECTED"
+)
+
+// activeIssueStatuses are the statuses of issues that still need attention.
+var activeIssueStatuses = []string{IssueStatusOpen, IssueStatusInProgress}
+
+// IsActiveIssueStatus reports whether an issue with the given status still
+// needs attention, i.e. is synced as an insight.
+func IsActiveIssueStatus(issueStatus string) bool {
+	return slices.Contains(activeIssueStatuses, issueStatus)
+}
+
 // principalEntityTypes are the Wiz entity types that represent user and service
 // accounts — the kinds of identities that match users synced from other Baton
 // connectors (baton-aws, baton-github, baton-okta, etc.).
@@ -23,10 +45,91 @@ var principalEntityTypes = []string{
 	"SERVICE_ACCOUNT",
 }
 
+// Issue severities.
+const (
+	IssueSeverityCritical      = "CRITICAL"
+	IssueSeverityHigh          = "HIGH"
+	IssueSeverityMedium        = "MEDIUM"
+	IssueSeverityLow           = "LOW"
+	IssueSeverityInformational = "INFORMATIONAL"
+)
+
+// IssueSeverities lists every issue severity, from most to least severe.
+var IssueSeverities = []string{
+	IssueSeverityCritical,
+	IssueSeverityHigh,
+	IssueSeverityMedium,
+	IssueSeverityLow,
+	IssueSeverityInformational,
+}
+
+// ErrCursorExpired is returned when Wiz rejects a pagination cursor, usually
+// because it has expired. It wraps the status error of the GraphQL response.
+var ErrCursorExpired = errors.New("pagination cursor expired")
+
+// cursorExpiredMessages are the messages, compared ignoring case, of the
+// GraphQL errors Wiz returns for an expired or otherwise unusable cursor.
+var cursorExpiredMessages = []string{
+	"invalid cursor",
+	"cursor has expired",
+	"cursor expired",
+}
+
+// isCursorExpiredError reports whether a GraphQL error rejects the request's
+// pagination cursor.
+func isCursorExpiredError(gqlErr graphQLError) bool {
+	message := strings.ToLower(gqlErr.Message)
+	return slices.ContainsFunc(cursorExpiredMessages, func(m string) bool {
+		return strings.HasPrefix(message, m)
+	})
+}
+
+// IssueScope narrows ListIssues to a subset of issues, so that a full sync can
+// be split into independent shards. The zero value lists every issue.
+type IssueScope struct {
+	// Severity restricts the list to issues of one severity.
+	Severity string
+	// ProjectID restricts the list to issues in one Wiz project.
+	ProjectID string
+	// ActiveOnly restricts the list to open and in-progress issues.
+	ActiveOnly bool
+	// EntityIDs restricts the list to issues about the entities with these IDs.
+	EntityIDs []string
+	// CreatedAfter restricts the list to issues created at or after a time.
+	// The filter has a resolution of one second, so issues created up to a
+	// second earlier may also be listed.
+	CreatedAfter time.Time
+	// FrameworkIDs restricts the list to issues whose source rule maps to one
+	// of the security frameworks with these IDs.
+	FrameworkIDs []string
+}
+
 // Client defines the interface for interacting with the Wiz API.
 type Client interface {
-	ListIssues(ctx context.Context, cursor *string) (*IssueConnection, error)
-	ListIssuesSince(ctx context.Context, since time.Time, cursor *string) (*IssueConnection, error)
+	ListIssues(ctx context.Context, scope IssueScope, cursor *string) (*IssueConnection, error)
+	GetIssue(ctx context.Context, id string) (*Issue, error)
+	ExportIssuesReport(ctx context.Context, reportID string, activeOnly bool, timeout time.Duration, w io.Writer) (string, error)
+	GetIssues(ctx context.Context, ids []string) ([]Issue, error)
+	ListIssueChangesSince(ctx context.Context, since time.Time, cursor *string) (*IssueChangeConnection, error)
+	ListIssueChangesBetween(ctx context.Context, after, before time.Time, cursor *string) (*IssueChangeConnection, error)
+	ListProjects(ctx context.Context, cursor *string) (*ProjectConnection, error)
+	ListSecurityFrameworks(ctx context.Context, cursor *string) (*SecurityFrameworkConnection, error)
+	ListControls(ctx context.Context, cursor *string) (*ControlConnection, error)
+	ListCloudConfigurationRules(ctx context.Context, cursor *string) (*ControlConnection, error)
+	ListIdentities(ctx context.Context, cursor *string) (*GraphSearchConnection, error)
+	ListAccessKeys(ctx context.Context, cursor *string) (*GraphSearchConnection, error)
+	ListExcessiveAccessFindings(ctx context.Context, cursor *string) (*ExcessiveAccessFindingConnection, error)
+	ListExcessiveAccessFindingsSince(ctx context.Context, since time.Time, cursor *string) (*ExcessiveAccessFindingConnection, error)
+	GetExcessiveAccessFinding(ctx context.Context, id string) (*ExcessiveAccessFinding, error)
+	ListCloudResources(ctx context.Context, resourceTypes, principalIDs []string, cursor *string) (*EffectiveAccessConnection, error)
+	ListEffectiveAccess(ctx context.Context, resourceID string, cursor *string) (*EffectiveAccessConnection, error)
+	ListSecretInstances(ctx context.Context, cursor *string) (*SecretInstanceConnection, error)
+	ListSecretInstancesSince(ctx context.Context, since time.Time, cursor *string) (*SecretInstanceConnection, error)
+	ListDetectionsSince(ctx context.Context, since time.Time, cursor *string) (*DetectionConnection, error)
+	ListCloudEvents(ctx context.Context, after, before time.Time, cursor *string) (*CloudEventConnection, error)
+	ListAuditLogEntriesSince(ctx context.Context, since time.Time, cursor *string) (*AuditLogEntryConnection, error)
+	ListUsers(ctx context.Context, cursor *string) (*UserConnection, error)
+	ListServiceAccounts(ctx context.Context, cursor *string) (*UserConnection, error)
 	ValidateCredentials(ctx context.Context) error
 	Close() error
 }
@@ -35,10 +138,16 @@ type Client interface {
 type client struct {
 	wrapper *uhttp.BaseHttpClient
 	apiURL  string
+
+	// downloader fetches pre-signed report downloads, which must not carry
+	// the API credentials.
+	downloader *uhttp.BaseHttpClient
 }
 
-// NewClient creates a new Wiz API client with OAuth2 authentication.
-func NewClient(ctx context.Context, apiURL, clientID, clientSecret, authEndpoint string) (Client, error) {
+// NewClient creates a new Wiz API client with OAuth2 authentication. When
+// requestsPerSecond is positive, requests are limited to that rate, shared by
+// every caller of the client.
+func NewClient(ctx context.Context, apiURL, clientID, clientSecret, authEndpoint string, requestsPerSecond int) (Client, error) {
 	// Configure OAuth2 client credentials flow
 	// Wiz requires the "audience=wiz-api" parameter for token requests
 	config := clientcredentials.Config{
@@ -55,28 +164,41 @@ func NewClient(ctx context.Context, apiURL, clientID, clientSecret, authEndpoint
 	httpClient := config.Client(ctx)
 
 	// Wrap with baton-sdk's HTTP client wrapper for proper error handling and retries
-	wrapper, err := uhttp.NewBaseHttpClientWithContext(ctx, httpClient)
+	var opts []uhttp.WrapperOption
+	if requestsPerSecond > 0 {
+		opts = append(opts, uhttp.WithRateLimiter(requestsPerSecond, time.Second))
+	}
+	wrapper, err := uhttp.NewBaseHttpClientWithContext(ctx, httpClient, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create http client wrapper: %w", err)
 	}
 
+	downloadClient, err := uhttp.NewClient(ctx, uhttp.WithLogger(true, ctxzap.Extract(ctx)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create download http client: %w", err)
+	}
+	downloader, err := uhttp.NewBaseHttpClientWithContext(ctx, downloadClient, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create download http client wrapper: %w", err)
+	}
+
 	return &client{
-		wrapper: wrapper,
-		apiURL:  apiURL,
+		wrapper:    wrapper,
+		apiURL:     apiURL,
+		downloader: downloader,
 	}, nil
 }
 
 // ValidateCredentials performs a lightweight API call (fetching a single issue)
 // to verify that the configured credentials are valid.
 func (c *client) ValidateCredentials(ctx context.Context) error {
-	variables := map[string]interface{}{
-		"first":    1,
+	query := issuesPageQuery("validate credentials", map[string]interface{}{
 		"filterBy": principalEntityFilter(),
-	}
+	})
+	query.PageSize = 1
 
-	var result issuesQueryResponse
-	if err := c.graphQLRequest(ctx, issuesQuery, variables, &result); err != nil {
-		return fmt.Errorf("baton-wiz-insights: failed to validate credentials: %w", err)
+	if _, err := newPaginator[Issue](c, query, nil).Next(ctx); err != nil {
+		return fmt.Errorf("baton-wiz-insights: %w", err)
 	}
 
 	return nil
@@ -121,14 +243,18 @@ func (c *client) graphQLRequest(ctx context.Context, query string, variables map
 
 	// Check for GraphQL-specific errors in the response
 	if len(gqlResp.Errors) > 0 {
-		return status.Errorf(codes.Unknown, "graphql errors: %+v", gqlResp.Errors)
+		err := status.Errorf(codes.Unknown, "graphql errors: %+v", gqlResp.Errors)
+		if _, paged := variables["after"]; paged && slices.ContainsFunc(gqlResp.Errors, isCursorExpiredError) {
+			return fmt.Errorf("%w: %w", ErrCursorExpired, err)
+		}
+		return err
 	}
 
 	return nil
 }
 
-const issuesQuery = `query IssuesV2($after: String, $first: Int, $filterBy: IssueFilters) {
-  issuesV2(after: $after, first: $first, filterBy: $filterBy) {
+const issuesQuery = `query IssuesV2($after: String, $first: Int, $filterBy: IssueFilters, $orderBy: IssueOrder) {
+  issuesV2(after: $after, first: $first, filterBy: $filterBy, orderBy: $orderBy) {
     nodes {
       id
       status
@@ -138,6 +264,12 @@ const issuesQuery = `query IssuesV2($after: String, $first: Int, $filterBy: Issu
       sourceRule {
         id
         name
+        ... on Control {
+          ` + securitySubCategoriesFields + `
+        }
+        ... on CloudConfigurationRule {
+          ` + securitySubCategoriesFields + `
+        }
       }
       entitySnapshot {
         id
@@ -147,6 +279,11 @@ const issuesQuery = `query IssuesV2($after: String, $first: Int, $filterBy: Issu
         externalId
         cloudPlatform
         subscriptionId
+        tags
+      }
+      projects {
+        id
+        name
       }
     }
     pageInfo {
@@ -156,6 +293,33 @@ const issuesQuery = `query IssuesV2($after: String, $first: Int, $filterBy: Issu
   }
 }`
 
+// issueChangesQuery detects changed issues. It only selects scalar fields, which
+// keeps its cost against the query complexity budget low on every poll.
+const issueChangesQuery = `query IssueChanges($after: String, $first: Int, $filterBy: IssueFilters) {
+  issuesV2(after: $after, first: $first, filterBy: $filterBy) {
+    nodes {
+      id
+      status
+      createdAt
+      statusChangedAt
+    }
+    pageInfo {
+      hasNextPage
+      endCursor
+    }
+  }
+}`
+
+// issuesPageQuery returns the paginated issuesV2 query with the given variables.
+func issuesPageQuery(name string, variables map[string]interface{}) pageQuery {
+	return pageQuery{
+		Name:      name,
+		Field:     "issuesV2",
+		Text:      issuesQuery,
+		Variables: variables,
+	}
+}
+
 // principalEntityFilter returns the relatedEntity filter that restricts
 // results to only principal/identity entity types.
 func principalEntityFilter() map[string]interface{} {
@@ -168,44 +332,116 @@ func principalEntityFilter() map[string]interface{} {
 
 // ListIssues retrieves a paginated list of principal-related issues from Wiz.
 // Results are filtered to only issues whose related entity is a principal type
-// (USER_ACCOUNT, SERVICE_ACCOUNT).
-func (c *client) ListIssues(ctx context.Context, cursor *string) (*IssueConnection, error) {
-	variables := map[string]interface{}{
-		"first":    100,
-		"filterBy": principalEntityFilter(),
+// (USER_ACCOUNT, SERVICE_ACCOUNT), and to the given scope. Issues are ordered by
+// creation time, oldest first.
+func (c *client) ListIssues(ctx context.Context, scope IssueScope, cursor *string) (*IssueConnection, error) {
+	filter := principalEntityFilter()
+	if len(scope.EntityIDs) > 0 {
+		filter["relatedEntity"] = map[string]interface{}{
+			"ids":  scope.EntityIDs,
+			"type": principalEntityTypes,
+		}
+	}
+	if scope.ActiveOnly {
+		filter["status"] = activeIssueStatuses
+	}
+	if scope.Severity != "" {
+		filter["severity"] = []string{scope.Severity}
 	}
-	if cursor != nil && *cursor != "" {
-		variables["after"] = *cursor
+	if scope.ProjectID != "" {
+		filter["project"] = []string{scope.ProjectID}
+	}
+	if len(scope.FrameworkIDs) > 0 {
+		filter["securityFramework"] = scope.FrameworkIDs
+	}
+	if !scope.CreatedAfter.IsZero() {
+		filter["createdAt"] = map[string]interface{}{
+			"after": scope.CreatedAfter.Truncate(time.Second).Add(-time.Second).Format(time.RFC3339),
+		}
 	}
 
-	var result issuesQueryResponse
-	if err := c.graphQLRequest(ctx, issuesQuery, variables, &result); err != nil {
-		return nil, fmt.Errorf("failed to list issues: %w", err)
+	query := issuesPageQuery("list issues", map[string]interface{}{
+		"filterBy": filter,
+		"orderBy": map[string]interface{}{
+			"field":     "CREATED_AT",
+			"direction": "ASC",
+		},
+	})
+	return newPaginator[Issue](c, query, cursor).Next(ctx)
+}
+
+// GetIssue retrieves a single principal-related issue by its Wiz issue ID. It
+// returns a NotFound error if the issue does not exist or is not related to a
+// principal.
+func (c *client) GetIssue(ctx context.Context, id string) (*Issue, error) {
+	issues, err := c.GetIssues(ctx, []string{id})
+	if err != nil {
+		return nil, err
+	}
+	if len(issues) == 0 {
+		return nil, status.Errorf(codes.NotFound, "issue %s not found", id)
 	}
 
-	return &result.IssuesV2, nil
+	return &issues[0], nil
 }
 
-// ListIssuesSince retrieves a paginated list of principal-related issues from Wiz
-// filtered by statusChangedAt >= since. Used by the event feed for incremental sync.
-func (c *client) ListIssuesSince(ctx context.Context, since time.Time, cursor *string) (*IssueConnection, error) {
-	filter := principalEntityFilter()
-	filter["statusChangedAt"] = map[string]interface{}{
-		"after": since.Format(time.RFC3339),
+// GetIssues retrieves the full details of principal-related issues by their
+// Wiz issue IDs, in batches of up to 100 IDs per query. Issues that do not
+// exist or are not related to a principal are left out of the result.
+func (c *client) GetIssues(ctx context.Context, ids []string) ([]Issue, error) {
+	var issues []Issue
+	for batch := range slices.Chunk(ids, defaultPageSize) {
+		filter := principalEntityFilter()
+		filter["id"] = batch
+
+		query := issuesPageQuery(fmt.Sprintf("get %d issues", len(batch)), map[string]interface{}{
+			"filterBy": filter,
+		})
+		for issue, err := range newPaginator[Issue](c, query, nil).All(ctx) {
+			if err != nil {
+				return nil, err
+			}
+			issues = append(issues, issue)
+		}
 	}
 
-	variables := map[string]interface{}{
-		"first":    100,
-		"filterBy": filter,
+	return issues, nil
+}
+
+// ListIssueChangesSince retrieves a paginated list of changes to principal-related
+// issues from Wiz filtered by statusChangedAt >= since. Used by the event feed
+// for incremental sync; details of changed issues are fetched with GetIssues.
+// Issues in every status are returned so the feed can report issues leaving scope.
+func (c *client) ListIssueChangesSince(ctx context.Context, since time.Time, cursor *string) (*IssueChangeConnection, error) {
+	return c.listIssueChanges(ctx, since, time.Time{}, cursor)
+}
+
+// ListIssueChangesBetween is like ListIssueChangesSince, but only returns
+// changes whose statusChangedAt is also before the given time.
+func (c *client) ListIssueChangesBetween(ctx context.Context, after, before time.Time, cursor *string) (*IssueChangeConnection, error) {
+	return c.listIssueChanges(ctx, after, before, cursor)
+}
+
+// listIssueChanges lists changes to principal-related issues by
+// statusChangedAt. A zero before leaves the window open-ended.
+func (c *client) listIssueChanges(ctx context.Context, after, before time.Time, cursor *string) (*IssueChangeConnection, error) {
+	statusChangedAt := map[string]interface{}{
+		"after": after.Format(time.RFC3339),
 	}
-	if cursor != nil && *cursor != "" {
-		variables["after"] = *cursor
+	if !before.IsZero() {
+		statusChangedAt["before"] = before.Format(time.RFC3339)
 	}
 
-	var result issuesQueryResponse
-	if err := c.graphQLRequest(ctx, issuesQuery, variables, &result); err != nil {
-		return nil, fmt.Errorf("failed to list issues since %s: %w", since.Format(time.RFC3339), err)
-	}
+	filter := principalEntityFilter()
+	filter["statusChangedAt"] = statusChangedAt
 
-	return &result.IssuesV2, nil
+	query := pageQuery{
+		Name:  fmt.Sprintf("list issues changed since %s", after.Format(time.RFC3339)),
+		Field: "issuesV2",
+		Text:  issueChangesQuery,
+		Variables: map[string]interface{}{
+			"filterBy": filter,
+		},
+	}
+	return newPaginator[IssueChange](c, query, cursor).Next(ctx)
 }
@@ -0,0 +1,55 @@
+package wiz
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const cloudEventsQuery = `query CloudEvents($after: String, $first: Int, $filterBy: CloudEventFilters) {
+  cloudEvents(after: $after, first: $first, filterBy: $filterBy) {
+    nodes {
+      id
+      name
+      timestamp
+      cloudPlatform
+      actor {
+        id
+        name
+        type
+        properties
+      }
+    }
+    pageInfo {
+      hasNextPage
+      endCursor
+    }
+  }
+}`
+
+// ListCloudEvents retrieves a paginated list of cloud events performed by
+// principals (USER_ACCOUNT, SERVICE_ACCOUNT) with a timestamp in [after, before).
+func (c *client) ListCloudEvents(ctx context.Context, after, before time.Time, cursor *string) (*CloudEventConnection, error) {
+	variables := map[string]interface{}{
+		"first": 500,
+		"filterBy": map[string]interface{}{
+			"timestamp": map[string]interface{}{
+				"after":  after.Format(time.RFC3339),
+				"before": before.Format(time.RFC3339),
+			},
+			"actor": map[string]interface{}{
+				"type": principalEntityTypes,
+			},
+		},
+	}
+	if cursor != nil && *cursor != "" {
+		variables["after"] = *cursor
+	}
+
+	var result cloudEventsQueryResponse
+	if err := c.graphQLRequest(ctx, cloudEventsQuery, variables, &result); err != nil {
+		return nil, fmt.Errorf("failed to list cloud events between %s and %s: %w", after.Format(time.RFC3339), before.Format(time.RFC3339), err)
+	}
+
+	return &result.CloudEvents, nil
+}
@@ -0,0 +1,95 @@
+package wiz
+
+import "context"
+
+// ControlTypeCloudConfiguration is the Type of controls that are cloud
+// configuration rules, which have no control type of their own.
+const ControlTypeCloudConfiguration = "CLOUD_CONFIGURATION"
+
+// securitySubCategoriesFields selects the framework mappings of a rule.
+const securitySubCategoriesFields = `securitySubCategories {
+        id
+        title
+        externalId
+        category {
+          id
+          name
+          framework {
+            id
+            name
+          }
+        }
+      }`
+
+const controlsQuery = `query Controls($after: String, $first: Int) {
+  controls(after: $after, first: $first) {
+    nodes {
+      id
+      name
+      description
+      severity
+      type
+      enabled
+      ` + securitySubCategoriesFields + `
+      scopeProject {
+        id
+        name
+      }
+    }
+    pageInfo {
+      hasNextPage
+      endCursor
+    }
+  }
+}`
+
+const cloudConfigurationRulesQuery = `query CloudConfigurationRules($after: String, $first: Int) {
+  cloudConfigurationRules(after: $after, first: $first) {
+    nodes {
+      id
+      name
+      description
+      severity
+      enabled
+      ` + securitySubCategoriesFields + `
+      scopeProject {
+        id
+        name
+      }
+    }
+    pageInfo {
+      hasNextPage
+      endCursor
+    }
+  }
+}`
+
+// ListControls retrieves a paginated list of Wiz controls, enabled or not.
+func (c *client) ListControls(ctx context.Context, cursor *string) (*ControlConnection, error) {
+	query := pageQuery{
+		Name:  "list controls",
+		Field: "controls",
+		Text:  controlsQuery,
+	}
+	return newPaginator[Control](c, query, cursor).Next(ctx)
+}
+
+// ListCloudConfigurationRules retrieves a paginated list of Wiz cloud
+// configuration rules, enabled or not, as controls of type
+// ControlTypeCloudConfiguration.
+func (c *client) ListCloudConfigurationRules(ctx context.Context, cursor *string) (*ControlConnection, error) {
+	query := pageQuery{
+		Name:  "list cloud configuration rules",
+		Field: "cloudConfigurationRules",
+		Text:  cloudConfigurationRulesQuery,
+	}
+	resp, err := newPaginator[Control](c, query, cursor).Next(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range resp.Nodes {
+		resp.Nodes[i].Type = ControlTypeCloudConfiguration
+	}
+	return resp, nil
+}
@@ -0,0 +1,74 @@
+package wiz
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+const detectionsQuery = `query Detections($after: String, $first: Int, $filterBy: DetectionFilters) {
+  detections(after: $after, first: $first, filterBy: $filterBy) {
+    nodes {
+      id
+      description
+      severity
+      createdAt
+      rule {
+        id
+        name
+      }
+      mitreTechniques {
+        externalId
+        name
+      }
+      primaryActor {
+        id
+        name
+        type
+        properties
+      }
+      primaryResource {
+        id
+        name
+        type
+        properties
+      }
+    }
+    pageInfo {
+      hasNextPage
+      endCursor
+    }
+  }
+}`
+
+// ListDetectionsSince retrieves a paginated list of threat detections created
+// after since whose primary actor is a principal (USER_ACCOUNT, SERVICE_ACCOUNT).
+func (c *client) ListDetectionsSince(ctx context.Context, since time.Time, cursor *string) (*DetectionConnection, error) {
+	variables := map[string]interface{}{
+		"first": 100,
+		"filterBy": map[string]interface{}{
+			"createdAt": map[string]interface{}{
+				"after": since.Format(time.RFC3339),
+			},
+			"primaryActor": map[string]interface{}{
+				"type": principalEntityTypes,
+			},
+		},
+	}
+	if cursor != nil && *cursor != "" {
+		variables["after"] = *cursor
+	}
+
+	var result detectionsQueryResponse
+	if err := c.graphQLRequest(ctx, detectionsQuery, variables, &result); err != nil {
+		return nil, fmt.Errorf("failed to list detections since %s: %w", since.Format(time.RFC3339), err)
+	}
+
+	return &result.Detections, nil
+}
+
+// IsPrincipalEntityType reports whether a Wiz entity type represents a user or service account.
+func IsPrincipalEntityType(entityType string) bool {
+	return slices.Contains(principalEntityTypes, entityType)
+}
@@ -0,0 +1,91 @@
+package wiz
+
+import (
+	"context"
+	"fmt"
+)
+
+const effectiveAccessQuery = `query EffectiveAccess($after: String, $first: Int, $filterBy: EffectiveAccessFilters) {
+  effectiveAccess(after: $after, first: $first, filterBy: $filterBy) {
+    nodes {
+      principal {
+        id
+        name
+        type
+        properties
+      }
+      resource {
+        id
+        name
+        type
+        properties
+      }
+      accessTypes
+    }
+    pageInfo {
+      hasNextPage
+      endCursor
+    }
+  }
+}`
+
+// ListCloudResources retrieves a paginated list of the effective access that
+// the given principals have to cloud resources of the given Wiz entity types
+// (e.g. BUCKET, ENCRYPTION_KEY). Listing resources through the principals
+// that reach them, rather than through the security graph, restricts them to
+// the resources those principals can access. Both lists must be non-empty,
+// since an empty list would leave the query unfiltered.
+func (c *client) ListCloudResources(ctx context.Context, resourceTypes, principalIDs []string, cursor *string) (*EffectiveAccessConnection, error) {
+	if len(resourceTypes) == 0 || len(principalIDs) == 0 {
+		return nil, fmt.Errorf("failed to list cloud resources: resource types and principals are required")
+	}
+
+	variables := map[string]interface{}{
+		"first": 100,
+		"filterBy": map[string]interface{}{
+			"resource": map[string]interface{}{
+				"type": resourceTypes,
+			},
+			"principal": map[string]interface{}{
+				"id":   principalIDs,
+				"type": principalEntityTypes,
+			},
+		},
+	}
+	if cursor != nil && *cursor != "" {
+		variables["after"] = *cursor
+	}
+
+	var result effectiveAccessQueryResponse
+	if err := c.graphQLRequest(ctx, effectiveAccessQuery, variables, &result); err != nil {
+		return nil, fmt.Errorf("failed to list cloud resources: %w", err)
+	}
+
+	return &result.EffectiveAccess, nil
+}
+
+// ListEffectiveAccess retrieves a paginated list of principals (USER_ACCOUNT,
+// SERVICE_ACCOUNT) that have effective access to the given cloud resource.
+func (c *client) ListEffectiveAccess(ctx context.Context, resourceID string, cursor *string) (*EffectiveAccessConnection, error) {
+	variables := map[string]interface{}{
+		"first": 100,
+		"filterBy": map[string]interface{}{
+			"resource": map[string]interface{}{
+				"id": []string{resourceID},
+			},
+			"principal": map[string]interface{}{
+				"type": principalEntityTypes,
+			},
+		},
+	}
+	if cursor != nil && *cursor != "" {
+		variables["after"] = *cursor
+	}
+
+	var result effectiveAccessQueryResponse
+	if err := c.graphQLRequest(ctx, effectiveAccessQuery, variables, &result); err != nil {
+		return nil, fmt.Errorf("failed to list effective access for resource %s: %w", resourceID, err)
+	}
+
+	return &result.EffectiveAccess, nil
+}
@@ -0,0 +1,120 @@
+package wiz
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Excessive access finding statuses.
+const (
+	ExcessiveAccessStatusOpen     = "OPEN"
+	ExcessiveAccessStatusResolved = "RESOLVED"
+)
+
+const excessiveAccessFindingsQuery = `query ExcessiveAccessFindings($after: String, $first: Int, $filterBy: ExcessiveAccessFindingFilters) {
+  excessiveAccessFindings(after: $after, first: $first, filterBy: $filterBy) {
+    nodes {
+      id
+      name
+      description
+      severity
+      status
+      cloudPlatform
+      remediationType
+      unusedPermissionsCount
+      lookbackDays
+      builtInPolicyRemediationName
+      remediationPolicy
+      firstSeenAt
+      updatedAt
+      principal {
+        cloudProviderUniqueId
+        graphEntity {
+          id
+          name
+          type
+          properties
+        }
+      }
+      cloudAccount {
+        id
+        name
+        externalId
+        cloudProvider
+      }
+    }
+    pageInfo {
+      hasNextPage
+      endCursor
+    }
+  }
+}`
+
+func (c *client) listExcessiveAccessFindings(ctx context.Context, filter map[string]interface{}, cursor *string) (*ExcessiveAccessFindingConnection, error) {
+	variables := map[string]interface{}{
+		"first":    100,
+		"filterBy": filter,
+	}
+	if cursor != nil && *cursor != "" {
+		variables["after"] = *cursor
+	}
+
+	var result excessiveAccessFindingsQueryResponse
+	if err := c.graphQLRequest(ctx, excessiveAccessFindingsQuery, variables, &result); err != nil {
+		return nil, err
+	}
+
+	return &result.ExcessiveAccessFindings, nil
+}
+
+// ListExcessiveAccessFindings retrieves a paginated list of open excessive access findings.
+func (c *client) ListExcessiveAccessFindings(ctx context.Context, cursor *string) (*ExcessiveAccessFindingConnection, error) {
+	filter := map[string]interface{}{
+		"status": []string{ExcessiveAccessStatusOpen},
+	}
+
+	result, err := c.listExcessiveAccessFindings(ctx, filter, cursor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list excessive access findings: %w", err)
+	}
+
+	return result, nil
+}
+
+// ListExcessiveAccessFindingsSince retrieves a paginated list of excessive access findings
+// of any status updated after since. Used by the event feed for incremental sync.
+func (c *client) ListExcessiveAccessFindingsSince(ctx context.Context, since time.Time, cursor *string) (*ExcessiveAccessFindingConnection, error) {
+	filter := map[string]interface{}{
+		"updatedAt": map[string]interface{}{
+			"after": since.Format(time.RFC3339),
+		},
+	}
+
+	result, err := c.listExcessiveAccessFindings(ctx, filter, cursor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list excessive access findings since %s: %w", since.Format(time.RFC3339), err)
+	}
+
+	return result, nil
+}
+
+// GetExcessiveAccessFinding retrieves a single excessive access finding by ID.
+func (c *client) GetExcessiveAccessFinding(ctx context.Context, id string) (*ExcessiveAccessFinding, error) {
+	filter := map[string]interface{}{
+		"id": []string{id},
+	}
+
+	result, err := c.listExcessiveAccessFindings(ctx, filter, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get excessive access finding %s: %w", id, err)
+	}
+	if len(result.Nodes) == 0 {
+		return nil, status.Errorf(codes.NotFound, "excessive access finding %s not found", id)
+	}
+
+	return &result.Nodes[0], nil
+}
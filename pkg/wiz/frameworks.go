@@ -0,0 +1,27 @@
+package wiz
+
+import "context"
+
+const securityFrameworksQuery = `query SecurityFrameworks($after: String, $first: Int) {
+  securityFrameworks(after: $after, first: $first) {
+    nodes {
+      id
+      name
+    }
+    pageInfo {
+      hasNextPage
+      endCursor
+    }
+  }
+}`
+
+// ListSecurityFrameworks retrieves a paginated list of the security frameworks
+// Wiz maps its rules to, both built-in and custom.
+func (c *client) ListSecurityFrameworks(ctx context.Context, cursor *string) (*SecurityFrameworkConnection, error) {
+	query := pageQuery{
+		Name:  "list security frameworks",
+		Field: "securityFrameworks",
+		Text:  securityFrameworksQuery,
+	}
+	return newPaginator[SecurityFramework](c, query, cursor).Next(ctx)
+}
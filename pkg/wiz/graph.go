@@ -0,0 +1,91 @@
+package wiz
+
+import (
+	"context"
+	"fmt"
+)
+
+const graphSearchQuery = `query GraphSearch($query: GraphEntityQueryInput, $projectId: String!, $after: String, $first: Int, $quick: Boolean) {
+  graphSearch(query: $query, projectId: $projectId, after: $after, first: $first, quick: $quick) {
+    nodes {
+      entities {
+        id
+        name
+        type
+        properties
+      }
+    }
+    pageInfo {
+      hasNextPage
+      endCursor
+    }
+  }
+}`
+
+// accessKeyEntityType is the Wiz entity type for access keys, service account
+// keys and app secrets.
+const accessKeyEntityType = "ACCESS_KEY"
+
+// graphSearch runs a single page of a security graph search across all projects.
+func (c *client) graphSearch(ctx context.Context, query map[string]interface{}, cursor *string) (*GraphSearchConnection, error) {
+	variables := map[string]interface{}{
+		"first":     100,
+		"query":     query,
+		"projectId": "*",
+		"quick":     true,
+	}
+	if cursor != nil && *cursor != "" {
+		variables["after"] = *cursor
+	}
+
+	var result graphSearchQueryResponse
+	if err := c.graphQLRequest(ctx, graphSearchQuery, variables, &result); err != nil {
+		return nil, err
+	}
+
+	return &result.GraphSearch, nil
+}
+
+// ListIdentities retrieves a paginated list of principal entities
+// (USER_ACCOUNT, SERVICE_ACCOUNT) from the Wiz security graph.
+func (c *client) ListIdentities(ctx context.Context, cursor *string) (*GraphSearchConnection, error) {
+	query := map[string]interface{}{
+		"type":   principalEntityTypes,
+		"select": true,
+	}
+
+	result, err := c.graphSearch(ctx, query, cursor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list identities: %w", err)
+	}
+
+	return result, nil
+}
+
+// ListAccessKeys retrieves a paginated list of access keys together with the
+// principal that owns them. Each node contains the access key entity followed
+// by its owning principal entity.
+func (c *client) ListAccessKeys(ctx context.Context, cursor *string) (*GraphSearchConnection, error) {
+	query := map[string]interface{}{
+		"type":   []string{accessKeyEntityType},
+		"select": true,
+		"relationships": []map[string]interface{}{
+			{
+				"type": []map[string]interface{}{
+					{"type": "OWNS", "reverse": true},
+				},
+				"with": map[string]interface{}{
+					"type":   principalEntityTypes,
+					"select": true,
+				},
+			},
+		},
+	}
+
+	result, err := c.graphSearch(ctx, query, cursor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list access keys: %w", err)
+	}
+
+	return result, nil
+}
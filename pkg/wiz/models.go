@@ -12,6 +12,9 @@ type PageInfo struct {
 type SourceRule struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
+	// SecuritySubCategories are the security framework subcategories that the
+	// rule maps to. Issues read from a report do not include them.
+	SecuritySubCategories []SecuritySubCategory `json:"securitySubCategories"`
 }
 
 // EntitySnapshot represents the cloud resource entity associated with an issue.
@@ -23,6 +26,20 @@ type EntitySnapshot struct {
 	ExternalID     string `json:"externalId"`
 	CloudPlatform  string `json:"cloudPlatform"`
 	SubscriptionID string `json:"subscriptionId"`
+	// Tags are the entity's cloud tags or labels, by key.
+	Tags map[string]string `json:"tags"`
+}
+
+// Project represents a Wiz project, a group of cloud resources owned by a team.
+type Project struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// ProjectConnection represents a paginated list of projects.
+type ProjectConnection struct {
+	Nodes    []Project `json:"nodes"`
+	PageInfo PageInfo  `json:"pageInfo"`
 }
 
 // Issue represents a Wiz security issue.
@@ -34,12 +51,99 @@ type Issue struct {
 	StatusChangedAt time.Time      `json:"statusChangedAt"`
 	SourceRule      SourceRule     `json:"sourceRule"`
 	EntitySnapshot  EntitySnapshot `json:"entitySnapshot"`
+	Projects        []Project      `json:"projects"`
 }
 
 // IssueConnection represents a paginated list of issues.
-type IssueConnection struct {
-	Nodes    []Issue  `json:"nodes"`
-	PageInfo PageInfo `json:"pageInfo"`
+type IssueConnection = Connection[Issue]
+
+// IssueChange is the minimal record of a change to an issue's status, used to
+// detect changes without fetching the issue's details.
+type IssueChange struct {
+	ID              string    `json:"id"`
+	Status          string    `json:"status"`
+	CreatedAt       time.Time `json:"createdAt"`
+	StatusChangedAt time.Time `json:"statusChangedAt"`
+}
+
+// IssueChangeConnection represents a paginated list of issue changes.
+type IssueChangeConnection = Connection[IssueChange]
+
+// GraphEntity represents a node in the Wiz security graph. Wiz returns
+// normalized entity attributes in the free-form Properties map.
+type GraphEntity struct {
+	ID         string                 `json:"id"`
+	Name       string                 `json:"name"`
+	Type       string                 `json:"type"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// ExternalID returns the cloud provider's unique identifier for the entity.
+func (e GraphEntity) ExternalID() string {
+	return e.stringProperty("externalId")
+}
+
+// NativeType returns the provider-specific type of the entity (e.g. "iam_user").
+func (e GraphEntity) NativeType() string {
+	return e.stringProperty("nativeType")
+}
+
+// CloudPlatform returns the cloud platform the entity belongs to.
+func (e GraphEntity) CloudPlatform() string {
+	return e.stringProperty("cloudPlatform")
+}
+
+// SubscriptionExternalID returns the external ID of the cloud account the entity belongs to.
+func (e GraphEntity) SubscriptionExternalID() string {
+	return e.stringProperty("subscriptionExternalId")
+}
+
+// CreatedAt returns when the entity was created in the cloud provider, or the zero time.
+func (e GraphEntity) CreatedAt() time.Time {
+	return e.timeProperty("creationDate")
+}
+
+// LastUsedAt returns when the entity (typically an access key) was last used, or the zero time.
+func (e GraphEntity) LastUsedAt() time.Time {
+	return e.timeProperty("lastUsedAt")
+}
+
+// ExpiresAt returns when the entity (typically an access key) expires, or the zero time.
+func (e GraphEntity) ExpiresAt() time.Time {
+	return e.timeProperty("validBefore")
+}
+
+func (e GraphEntity) stringProperty(key string) string {
+	v, ok := e.Properties[key].(string)
+	if !ok {
+		return ""
+	}
+	return v
+}
+
+func (e GraphEntity) timeProperty(key string) time.Time {
+	v := e.stringProperty(key)
+	if v == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// GraphSearchNode is a single graph search match. Entities are ordered as
+// they are selected in the query: the root entity first, followed by any
+// selected related entities.
+type GraphSearchNode struct {
+	Entities []GraphEntity `json:"entities"`
+}
+
+// GraphSearchConnection represents a paginated list of graph search matches.
+type GraphSearchConnection struct {
+	Nodes    []GraphSearchNode `json:"nodes"`
+	PageInfo PageInfo          `json:"pageInfo"`
 }
 
 // GraphQL response wrapper types.
@@ -54,6 +158,279 @@ type graphQLError struct {
 }
 
 // Specific response types for each query.
-type issuesQueryResponse struct {
-	IssuesV2 IssueConnection `json:"issuesV2"`
+type projectsQueryResponse struct {
+	Projects ProjectConnection `json:"projects"`
+}
+
+type graphSearchQueryResponse struct {
+	GraphSearch GraphSearchConnection `json:"graphSearch"`
+}
+
+// CloudAccount represents the cloud account (AWS account, GCP project,
+// Azure subscription) a finding belongs to.
+type CloudAccount struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	ExternalID    string `json:"externalId"`
+	CloudProvider string `json:"cloudProvider"`
+}
+
+// ExcessiveAccessPrincipal identifies the principal an excessive access finding is about.
+type ExcessiveAccessPrincipal struct {
+	CloudProviderUniqueID string       `json:"cloudProviderUniqueId"`
+	GraphEntity           *GraphEntity `json:"graphEntity"`
+}
+
+// ExcessiveAccessFinding represents a Wiz CIEM finding listing permissions a
+// principal holds but has not used within the lookback window.
+type ExcessiveAccessFinding struct {
+	ID                           string                   `json:"id"`
+	Name                         string                   `json:"name"`
+	Description                  string                   `json:"description"`
+	Severity                     string                   `json:"severity"`
+	Status                       string                   `json:"status"`
+	CloudPlatform                string                   `json:"cloudPlatform"`
+	RemediationType              string                   `json:"remediationType"`
+	UnusedPermissionsCount       int                      `json:"unusedPermissionsCount"`
+	LookbackDays                 int                      `json:"lookbackDays"`
+	BuiltInPolicyRemediationName string                   `json:"builtInPolicyRemediationName"`
+	RemediationPolicy            string                   `json:"remediationPolicy"`
+	FirstSeenAt                  time.Time                `json:"firstSeenAt"`
+	UpdatedAt                    time.Time                `json:"updatedAt"`
+	Principal                    ExcessiveAccessPrincipal `json:"principal"`
+	CloudAccount                 CloudAccount             `json:"cloudAccount"`
+}
+
+// ExcessiveAccessFindingConnection represents a paginated list of excessive access findings.
+type ExcessiveAccessFindingConnection struct {
+	Nodes    []ExcessiveAccessFinding `json:"nodes"`
+	PageInfo PageInfo                 `json:"pageInfo"`
+}
+
+type excessiveAccessFindingsQueryResponse struct {
+	ExcessiveAccessFindings ExcessiveAccessFindingConnection `json:"excessiveAccessFindings"`
+}
+
+// EffectiveAccess represents a principal's effective access to a cloud
+// resource as computed by the Wiz security graph. AccessTypes holds the Wiz
+// access classification of the permissions (e.g. "DATA_READ", "HIGH_PRIVILEGE").
+type EffectiveAccess struct {
+	Principal   GraphEntity `json:"principal"`
+	Resource    GraphEntity `json:"resource"`
+	AccessTypes []string    `json:"accessTypes"`
+}
+
+// EffectiveAccessConnection represents a paginated list of effective access relationships.
+type EffectiveAccessConnection struct {
+	Nodes    []EffectiveAccess `json:"nodes"`
+	PageInfo PageInfo          `json:"pageInfo"`
+}
+
+type effectiveAccessQueryResponse struct {
+	EffectiveAccess EffectiveAccessConnection `json:"effectiveAccess"`
+}
+
+// SecretInstance represents a cleartext secret (cloud key, token, password)
+// detected by Wiz on a host, container image or repository.
+type SecretInstance struct {
+	ID               string       `json:"id"`
+	Name             string       `json:"name"`
+	Type             string       `json:"type"`
+	Severity         string       `json:"severity"`
+	Status           string       `json:"status"`
+	ValidationStatus string       `json:"validationStatus"`
+	Path             string       `json:"path"`
+	FirstSeenAt      time.Time    `json:"firstSeenAt"`
+	LastSeenAt       time.Time    `json:"lastSeenAt"`
+	UpdatedAt        time.Time    `json:"updatedAt"`
+	Resource         GraphEntity  `json:"resource"`
+	RelatedIdentity  *GraphEntity `json:"relatedIdentity"`
+}
+
+// SecretInstanceConnection represents a paginated list of secret instances.
+type SecretInstanceConnection struct {
+	Nodes    []SecretInstance `json:"nodes"`
+	PageInfo PageInfo         `json:"pageInfo"`
+}
+
+type secretInstancesQueryResponse struct {
+	SecretInstances SecretInstanceConnection `json:"secretInstances"`
+}
+
+// MitreTechnique represents a MITRE ATT&CK technique associated with a detection.
+type MitreTechnique struct {
+	ID   string `json:"externalId"`
+	Name string `json:"name"`
+}
+
+// DetectionRule represents the Wiz threat detection rule that matched.
+type DetectionRule struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Detection represents a Wiz Defend runtime threat detection.
+type Detection struct {
+	ID              string           `json:"id"`
+	Description     string           `json:"description"`
+	Severity        string           `json:"severity"`
+	CreatedAt       time.Time        `json:"createdAt"`
+	Rule            DetectionRule    `json:"rule"`
+	MitreTechniques []MitreTechnique `json:"mitreTechniques"`
+	PrimaryActor    *GraphEntity     `json:"primaryActor"`
+	PrimaryResource *GraphEntity     `json:"primaryResource"`
+}
+
+// DetectionConnection represents a paginated list of detections.
+type DetectionConnection struct {
+	Nodes    []Detection `json:"nodes"`
+	PageInfo PageInfo    `json:"pageInfo"`
+}
+
+type detectionsQueryResponse struct {
+	Detections DetectionConnection `json:"detections"`
 }
+
+// CloudEvent represents a cloud audit log event (CloudTrail, GCP audit logs,
+// Azure activity) collected by Wiz.
+type CloudEvent struct {
+	ID            string       `json:"id"`
+	Name          string       `json:"name"`
+	Timestamp     time.Time    `json:"timestamp"`
+	CloudPlatform string       `json:"cloudPlatform"`
+	Actor         *GraphEntity `json:"actor"`
+}
+
+// CloudEventConnection represents a paginated list of cloud events.
+type CloudEventConnection struct {
+	Nodes    []CloudEvent `json:"nodes"`
+	PageInfo PageInfo     `json:"pageInfo"`
+}
+
+type cloudEventsQueryResponse struct {
+	CloudEvents CloudEventConnection `json:"cloudEvents"`
+}
+
+// AuditLogActor is a Wiz user or Wiz service account, such as the one that
+// performed an audited action.
+type AuditLogActor struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// UserConnection represents a paginated list of Wiz users or service accounts.
+type UserConnection = Connection[AuditLogActor]
+
+// AuditLogEntry represents an action performed in the Wiz console or API.
+type AuditLogEntry struct {
+	ID               string                 `json:"id"`
+	Action           string                 `json:"action"`
+	RequestID        string                 `json:"requestId"`
+	Status           string                 `json:"status"`
+	Timestamp        time.Time              `json:"timestamp"`
+	ActionParameters map[string]interface{} `json:"actionParameters"`
+	UserAgent        string                 `json:"userAgent"`
+	SourceIP         string                 `json:"sourceIP"`
+	User             *AuditLogActor         `json:"user"`
+	ServiceAccount   *AuditLogActor         `json:"serviceAccount"`
+}
+
+// Actor returns the Wiz user or service account that performed the action, or
+// nil for system actions.
+func (e AuditLogEntry) Actor() *AuditLogActor {
+	if e.User != nil {
+		return e.User
+	}
+	return e.ServiceAccount
+}
+
+// AuditLogEntryConnection represents a paginated list of audit log entries.
+type AuditLogEntryConnection struct {
+	Nodes    []AuditLogEntry `json:"nodes"`
+	PageInfo PageInfo        `json:"pageInfo"`
+}
+
+type auditLogEntriesQueryResponse struct {
+	AuditLogEntries AuditLogEntryConnection `json:"auditLogEntries"`
+}
+
+// ReportRun is the most recent run of a Wiz report.
+type ReportRun struct {
+	Status string `json:"status"`
+	URL    string `json:"url"`
+}
+
+// Report is a Wiz report, such as a bulk export of issues.
+type Report struct {
+	ID      string     `json:"id"`
+	Name    string     `json:"name"`
+	LastRun *ReportRun `json:"lastRun"`
+}
+
+type createReportResponse struct {
+	CreateReport struct {
+		Report Report `json:"report"`
+	} `json:"createReport"`
+}
+
+type rerunReportResponse struct {
+	RerunReport struct {
+		Report Report `json:"report"`
+	} `json:"rerunReport"`
+}
+
+type reportQueryResponse struct {
+	Report Report `json:"report"`
+}
+
+type reportsQueryResponse struct {
+	Reports Connection[Report] `json:"reports"`
+}
+
+// SecurityFramework is a security framework, such as CIS or NIST 800-53, that
+// Wiz maps its rules to.
+type SecurityFramework struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// SecurityFrameworkConnection represents a paginated list of security
+// frameworks.
+type SecurityFrameworkConnection = Connection[SecurityFramework]
+
+// SecurityCategory is a category of a security framework.
+type SecurityCategory struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	Framework SecurityFramework `json:"framework"`
+}
+
+// SecuritySubCategory is a subcategory of a security framework, the level a
+// Wiz rule is mapped at.
+type SecuritySubCategory struct {
+	ID         string           `json:"id"`
+	Title      string           `json:"title"`
+	ExternalID string           `json:"externalId"`
+	Category   SecurityCategory `json:"category"`
+}
+
+// Control represents a Wiz control or cloud configuration rule, the rules that
+// raise issues.
+type Control struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Severity    string `json:"severity"`
+	// Type is the control type, or ControlTypeCloudConfiguration for cloud
+	// configuration rules.
+	Type                  string                `json:"type"`
+	Enabled               bool                  `json:"enabled"`
+	SecuritySubCategories []SecuritySubCategory `json:"securitySubCategories"`
+	// ScopeProject is the project that owns the rule, or nil for rules that
+	// apply to every project.
+	ScopeProject *Project `json:"scopeProject"`
+}
+
+// ControlConnection represents a paginated list of controls.
+type ControlConnection = Connection[Control]
@@ -0,0 +1,146 @@
+package wiz
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"maps"
+	"time"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// defaultPageSize is the number of nodes requested per page unless a query
+// sets its own page size.
+const defaultPageSize = 100
+
+// Connection is a page of nodes returned by a Wiz GraphQL connection.
+type Connection[T any] struct {
+	Nodes    []T      `json:"nodes"`
+	PageInfo PageInfo `json:"pageInfo"`
+}
+
+// pageQuery describes a paginated Wiz GraphQL query. The query text must
+// accept $first and $after variables for the page size and cursor.
+type pageQuery struct {
+	// Name identifies the query in errors and logs.
+	Name string
+	// Field is the connection field of the response, such as issuesV2.
+	Field string
+	Text  string
+	// Variables are passed with every page, besides first and after.
+	Variables map[string]interface{}
+	// PageSize is the number of nodes per page, or defaultPageSize if zero.
+	PageSize int
+}
+
+// paginator fetches the pages of a Wiz GraphQL connection of nodes of type T.
+type paginator[T any] struct {
+	client *client
+	query  pageQuery
+	cursor string
+	page   int
+	done   bool
+}
+
+// newPaginator returns a paginator that starts after cursor, or at the first
+// page if cursor is nil or empty.
+func newPaginator[T any](c *client, query pageQuery, cursor *string) *paginator[T] {
+	p := &paginator[T]{client: c, query: query}
+	if cursor != nil {
+		p.cursor = *cursor
+	}
+	return p
+}
+
+// Next fetches the next page. It returns nil once every page has been fetched.
+func (p *paginator[T]) Next(ctx context.Context) (*Connection[T], error) {
+	if p.done {
+		return nil, nil
+	}
+
+	start := time.Now()
+	conn, err := p.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.page++
+	ctxzap.Extract(ctx).Debug("wiz: fetched page",
+		zap.String("query", p.query.Name),
+		zap.Int("page", p.page),
+		zap.Int("nodes", len(conn.Nodes)),
+		zap.Duration("duration", time.Since(start)))
+
+	// If EndCursor is empty despite HasNextPage, stop paginating to avoid an infinite loop.
+	if conn.PageInfo.HasNextPage && conn.PageInfo.EndCursor != "" {
+		p.cursor = conn.PageInfo.EndCursor
+	} else {
+		p.done = true
+	}
+	return conn, nil
+}
+
+func (p *paginator[T]) fetch(ctx context.Context) (*Connection[T], error) {
+	pageSize := p.query.PageSize
+	if pageSize == 0 {
+		pageSize = defaultPageSize
+	}
+
+	variables := maps.Clone(p.query.Variables)
+	if variables == nil {
+		variables = map[string]interface{}{}
+	}
+	variables["first"] = pageSize
+	if p.cursor != "" {
+		variables["after"] = p.cursor
+	}
+
+	var result map[string]*Connection[T]
+	if err := p.client.graphQLRequest(ctx, p.query.Text, variables, &result); err != nil {
+		return nil, fmt.Errorf("failed to %s: %w", p.query.Name, err)
+	}
+
+	conn := result[p.query.Field]
+	if conn == nil {
+		return nil, fmt.Errorf("failed to %s: response has no %s", p.query.Name, p.query.Field)
+	}
+	return conn, nil
+}
+
+// Pages returns an iterator over the remaining pages. Iteration stops after
+// the first error.
+func (p *paginator[T]) Pages(ctx context.Context) iter.Seq2[*Connection[T], error] {
+	return func(yield func(*Connection[T], error) bool) {
+		for {
+			conn, err := p.Next(ctx)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if conn == nil || !yield(conn, nil) {
+				return
+			}
+		}
+	}
+}
+
+// All returns an iterator over the nodes of the remaining pages. Iteration
+// stops after the first error.
+func (p *paginator[T]) All(ctx context.Context) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for conn, err := range p.Pages(ctx) {
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, node := range conn.Nodes {
+				if !yield(node, nil) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package wiz
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newTestClient returns a client for a fake GraphQL API that serves the
+// numbers 1 to total as nodes of a "numbers" connection. The cursor is the
+// last number served, the cursor "stale" is rejected as expired, and the
+// cursor "broken" fails with an unrelated error that mentions the cursor.
+func newTestClient(t *testing.T, total int) (*client, *[]map[string]interface{}) {
+	t.Helper()
+
+	var requests []map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Variables map[string]interface{} `json:"variables"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		requests = append(requests, body.Variables)
+
+		w.Header().Set("Content-Type", "application/json")
+		after, _ := body.Variables["after"].(string)
+		if after == "stale" {
+			fmt.Fprint(w, `{"errors": [{"message": "Invalid cursor: the cursor has expired"}]}`)
+			return
+		}
+		if after == "broken" {
+			fmt.Fprint(w, `{"errors": [{"message": "Internal error while resolving numbers after cursor"}]}`)
+			return
+		}
+
+		start := 0
+		if after != "" {
+			start, _ = strconv.Atoi(after)
+		}
+		end := min(start+int(body.Variables["first"].(float64)), total)
+		var nodes []int
+		for n := start + 1; n <= end; n++ {
+			nodes = append(nodes, n)
+		}
+		conn := Connection[int]{Nodes: nodes, PageInfo: PageInfo{HasNextPage: end < total, EndCursor: strconv.Itoa(end)}}
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"numbers": conn}}))
+	}))
+	t.Cleanup(ts.Close)
+
+	wrapper, err := uhttp.NewBaseHttpClientWithContext(context.Background(), ts.Client())
+	require.NoError(t, err)
+	return &client{wrapper: wrapper, apiURL: ts.URL}, &requests
+}
+
+func TestPaginatorIteratesPages(t *testing.T) {
+	ctx := context.Background()
+	c, requests := newTestClient(t, 5)
+
+	p := newPaginator[int](c, pageQuery{
+		Name:      "list numbers",
+		Field:     "numbers",
+		Variables: map[string]interface{}{"filterBy": "odd"},
+		PageSize:  2,
+	}, nil)
+
+	var numbers []int
+	for n, err := range p.All(ctx) {
+		require.NoError(t, err)
+		numbers = append(numbers, n)
+	}
+
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, numbers)
+	require.Len(t, *requests, 3)
+	assert.Equal(t, map[string]interface{}{"filterBy": "odd", "first": float64(2), "after": "4"}, (*requests)[2])
+
+	// Once exhausted, the paginator returns no more pages.
+	conn, err := p.Next(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, conn)
+}
+
+func TestPaginatorHandlesExpiredCursor(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestClient(t, 3)
+	stale := "stale"
+
+	_, err := newPaginator[int](c, pageQuery{Name: "list numbers", Field: "numbers"}, &stale).Next(ctx)
+	require.ErrorIs(t, err, ErrCursorExpired)
+	assert.Equal(t, codes.Unknown, status.Code(err))
+
+	// Other errors are not mistaken for an expired cursor.
+	broken := "broken"
+	_, err = newPaginator[int](c, pageQuery{Name: "list numbers", Field: "numbers"}, &broken).Next(ctx)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrCursorExpired)
+	assert.Equal(t, codes.Unknown, status.Code(err))
+}
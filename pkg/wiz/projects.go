@@ -0,0 +1,37 @@
+package wiz
+
+import (
+	"context"
+	"fmt"
+)
+
+const projectsQuery = `query Projects($after: String, $first: Int) {
+  projects(after: $after, first: $first) {
+    nodes {
+      id
+      name
+    }
+    pageInfo {
+      hasNextPage
+      endCursor
+    }
+  }
+}`
+
+// ListProjects retrieves a paginated list of the Wiz projects visible to the
+// service account.
+func (c *client) ListProjects(ctx context.Context, cursor *string) (*ProjectConnection, error) {
+	variables := map[string]interface{}{
+		"first": 100,
+	}
+	if cursor != nil && *cursor != "" {
+		variables["after"] = *cursor
+	}
+
+	var result projectsQueryResponse
+	if err := c.graphQLRequest(ctx, projectsQuery, variables, &result); err != nil {
+		return nil, fmt.Errorf("failed to list projects: %w", err)
+	}
+
+	return &result.Projects, nil
+}
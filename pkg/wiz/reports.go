@@ -0,0 +1,415 @@
+package wiz
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Report run statuses.
+const (
+	ReportRunStatusCompleted = "COMPLETED"
+	ReportRunStatusFailed    = "FAILED"
+	ReportRunStatusExpired   = "EXPIRED"
+)
+
+// Names of the issues reports created when no existing report is configured,
+// of issues in every status and of active issues only. A report created by an
+// earlier sync is found by its name and rerun, so that reports do not pile up
+// in the tenant.
+const (
+	issuesReportName       = "ConductorOne principal issues export"
+	activeIssuesReportName = "ConductorOne active principal issues export"
+)
+
+// reportPollInterval is how often a running report is checked for completion.
+var reportPollInterval = 10 * time.Second
+
+const createReportMutation = `mutation CreateReport($input: CreateReportInput!) {
+  createReport(input: $input) {
+    report {
+      id
+    }
+  }
+}`
+
+const rerunReportMutation = `mutation RerunReport($reportId: ID!) {
+  rerunReport(input: {id: $reportId}) {
+    report {
+      id
+    }
+  }
+}`
+
+const reportsQuery = `query Reports($after: String, $first: Int, $filterBy: ReportFilters) {
+  reports(after: $after, first: $first, filterBy: $filterBy) {
+    nodes {
+      id
+      name
+    }
+    pageInfo {
+      hasNextPage
+      endCursor
+    }
+  }
+}`
+
+const reportQuery = `query ReportRun($reportId: ID!) {
+  report(id: $reportId) {
+    id
+    lastRun {
+      status
+      url
+    }
+  }
+}`
+
+// Columns of a Wiz issues report read into an Issue.
+const (
+	reportColumnIssueID          = "Issue ID"
+	reportColumnStatus           = "Status"
+	reportColumnSeverity         = "Severity"
+	reportColumnCreatedAt        = "Created At"
+	reportColumnStatusChangedAt  = "Status Changed At"
+	reportColumnControlID        = "Control ID"
+	reportColumnTitle            = "Title"
+	reportColumnResourceID       = "Resource vertex ID"
+	reportColumnResourceType     = "Resource Type"
+	reportColumnResourceName     = "Resource Name"
+	reportColumnResourceExternal = "Resource external ID"
+	reportColumnResourcePlatform = "Resource Platform"
+	reportColumnSubscriptionID   = "Subscription ID"
+	reportColumnProjectIDs       = "Project IDs"
+	reportColumnResourceTags     = "Resource Tags"
+)
+
+// ExportIssuesReport runs a Wiz issues report of principal-related issues, or
+// only open and in-progress ones if activeOnly is set, waits up to timeout for
+// it to complete, and writes the uncompressed CSV to w. The report with the
+// given ID is rerun; if reportID is empty, the report created by an earlier
+// export is found by name and rerun, or created if there is none. It returns
+// the ID of the report that ran.
+//
+// The CSV is copied to w as it downloads rather than parsed here, because the
+// connector reads it over many List calls that may resume after a restart,
+// which a download stream cannot outlive.
+func (c *client) ExportIssuesReport(ctx context.Context, reportID string, activeOnly bool, timeout time.Duration, w io.Writer) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	name := issuesReportName
+	if activeOnly {
+		name = activeIssuesReportName
+	}
+	if reportID == "" {
+		var err error
+		reportID, err = c.findReport(ctx, name)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if reportID == "" {
+		filter := principalEntityFilter()
+		if activeOnly {
+			filter["status"] = activeIssueStatuses
+		}
+		variables := map[string]interface{}{
+			"input": map[string]interface{}{
+				"name":      name,
+				"type":      "ISSUES",
+				"projectId": "*",
+				"issueParams": map[string]interface{}{
+					"type":         "DETAILED",
+					"issueFilters": filter,
+				},
+			},
+		}
+
+		var result createReportResponse
+		if err := c.graphQLRequest(ctx, createReportMutation, variables, &result); err != nil {
+			return "", fmt.Errorf("failed to create issues report: %w", err)
+		}
+		reportID = result.CreateReport.Report.ID
+	} else {
+		var result rerunReportResponse
+		if err := c.graphQLRequest(ctx, rerunReportMutation, map[string]interface{}{"reportId": reportID}, &result); err != nil {
+			return "", fmt.Errorf("failed to rerun issues report %s: %w", reportID, err)
+		}
+	}
+
+	url, err := c.waitForReport(ctx, reportID)
+	if err != nil {
+		return reportID, err
+	}
+
+	if err := c.downloadReport(ctx, url, w); err != nil {
+		return reportID, fmt.Errorf("failed to download issues report %s: %w", reportID, err)
+	}
+	return reportID, nil
+}
+
+// findReport returns the ID of the issues report with the given name, or an
+// empty string if there is none.
+func (c *client) findReport(ctx context.Context, name string) (string, error) {
+	query := pageQuery{
+		Name:  "find issues report",
+		Field: "reports",
+		Text:  reportsQuery,
+		Variables: map[string]interface{}{
+			"filterBy": map[string]interface{}{
+				"search": name,
+				"type":   []string{"ISSUES"},
+			},
+		},
+	}
+	for report, err := range newPaginator[Report](c, query, nil).All(ctx) {
+		if err != nil {
+			return "", fmt.Errorf("failed to find issues report %q: %w", name, err)
+		}
+		// The search also matches reports whose name contains the name.
+		if report.Name == name {
+			return report.ID, nil
+		}
+	}
+	return "", nil
+}
+
+// waitForReport polls a report until its last run completes, and returns the
+// download URL of the result.
+func (c *client) waitForReport(ctx context.Context, reportID string) (string, error) {
+	ticker := time.NewTicker(reportPollInterval)
+	defer ticker.Stop()
+
+	for {
+		var result reportQueryResponse
+		if err := c.graphQLRequest(ctx, reportQuery, map[string]interface{}{"reportId": reportID}, &result); err != nil {
+			return "", fmt.Errorf("failed to get issues report %s: %w", reportID, err)
+		}
+
+		run := result.Report.LastRun
+		switch {
+		case run == nil:
+		case run.Status == ReportRunStatusCompleted && run.URL != "":
+			return run.URL, nil
+		case run.Status == ReportRunStatusFailed || run.Status == ReportRunStatusExpired:
+			return "", status.Errorf(codes.Unavailable, "issues report %s run %s", reportID, strings.ToLower(run.Status))
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("issues report %s did not complete: %w", reportID, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// downloadReport copies a report's content to w. The download URL is
+// pre-signed, so it is fetched without the API credentials, but with the same
+// rate limit, timeouts and retryable errors as API requests. Compressed
+// reports are decompressed.
+func (c *client) downloadReport(ctx context.Context, downloadURL string, w io.Writer) error {
+	parsedURL, err := url.Parse(downloadURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse download URL: %w", err)
+	}
+
+	req, err := c.downloader.NewRequest(ctx, http.MethodGet, parsedURL, uhttp.WithNoCache())
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.downloader.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body := bufio.NewReader(resp.Body)
+	var r io.Reader = body
+	if magic, err := body.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return fmt.Errorf("failed to decompress report: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	if _, err := io.Copy(w, r); err != nil {
+		return fmt.Errorf("failed to read report: %w", err)
+	}
+	return nil
+}
+
+// IssueReportPage is a page of issues read from a downloaded issues report.
+type IssueReportPage struct {
+	Issues []Issue
+	// NextOffset is the byte offset of the next row, to read the next page from.
+	NextOffset int64
+	// Done is set once the last row of the report has been read.
+	Done bool
+}
+
+// ReadIssueReport reads up to limit issues from the CSV issues report at path,
+// starting at the row at the given byte offset, or the first row if offset is
+// zero. Only the rows of the page are parsed, so a large report is read
+// page by page without holding it in memory.
+func ReadIssueReport(path string, offset int64, limit int) (*IssueReportPage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open issues report: %w", err)
+	}
+	defer f.Close()
+
+	header := csv.NewReader(f)
+	columns, err := header.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read issues report header: %w", err)
+	}
+	columnIndex := make(map[string]int, len(columns))
+	for i, column := range columns {
+		columnIndex[strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))] = i
+	}
+	// Rows without a resource type cannot be told apart from issues about
+	// other resources, so a report without the column cannot be synced.
+	for _, column := range []string{reportColumnIssueID, reportColumnResourceType} {
+		if _, ok := columnIndex[column]; !ok {
+			return nil, fmt.Errorf("issues report has no %q column", column)
+		}
+	}
+
+	if offset == 0 {
+		offset = header.InputOffset()
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to seek issues report: %w", err)
+	}
+
+	rows := csv.NewReader(f)
+	rows.FieldsPerRecord = len(columns)
+	rows.ReuseRecord = true
+
+	page := &IssueReportPage{}
+	for len(page.Issues) < limit {
+		record, err := rows.Read()
+		if errors.Is(err, io.EOF) {
+			page.Done = true
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read issues report: %w", err)
+		}
+
+		issue, err := issueFromReportRecord(columnIndex, record)
+		if err != nil {
+			return nil, err
+		}
+		page.Issues = append(page.Issues, issue)
+	}
+	page.NextOffset = offset + rows.InputOffset()
+
+	// A page that ends on the last row is the last page.
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat issues report: %w", err)
+	}
+	if page.NextOffset >= info.Size() {
+		page.Done = true
+	}
+
+	return page, nil
+}
+
+// issueFromReportRecord converts a row of an issues report into an Issue.
+func issueFromReportRecord(columnIndex map[string]int, record []string) (Issue, error) {
+	value := func(column string) string {
+		if i, ok := columnIndex[column]; ok {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	timestamp := func(column string) (time.Time, error) {
+		v := value(column)
+		if v == "" {
+			return time.Time{}, nil
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("issues report has an invalid %q value %q for issue %s: %w", column, v, value(reportColumnIssueID), err)
+		}
+		return t, nil
+	}
+
+	createdAt, err := timestamp(reportColumnCreatedAt)
+	if err != nil {
+		return Issue{}, err
+	}
+	statusChangedAt, err := timestamp(reportColumnStatusChangedAt)
+	if err != nil {
+		return Issue{}, err
+	}
+	if statusChangedAt.IsZero() {
+		statusChangedAt = createdAt
+	}
+
+	issue := Issue{
+		ID:              value(reportColumnIssueID),
+		Status:          reportEnumValue(value(reportColumnStatus)),
+		Severity:        reportEnumValue(value(reportColumnSeverity)),
+		CreatedAt:       createdAt,
+		StatusChangedAt: statusChangedAt,
+		SourceRule: SourceRule{
+			ID:   value(reportColumnControlID),
+			Name: value(reportColumnTitle),
+		},
+		EntitySnapshot: EntitySnapshot{
+			ID:             value(reportColumnResourceID),
+			Type:           value(reportColumnResourceType),
+			Name:           value(reportColumnResourceName),
+			ExternalID:     value(reportColumnResourceExternal),
+			CloudPlatform:  value(reportColumnResourcePlatform),
+			SubscriptionID: value(reportColumnSubscriptionID),
+		},
+	}
+	// Tags are a JSON object of tag keys to values. Rows whose tags cannot be
+	// parsed are kept without tags rather than failing the report.
+	if tags := value(reportColumnResourceTags); tags != "" {
+		if err := json.Unmarshal([]byte(tags), &issue.EntitySnapshot.Tags); err != nil {
+			issue.EntitySnapshot.Tags = nil
+		}
+	}
+	for _, projectID := range strings.Split(value(reportColumnProjectIDs), ",") {
+		if projectID = strings.TrimSpace(projectID); projectID != "" {
+			issue.Projects = append(issue.Projects, Project{ID: projectID})
+		}
+	}
+	return issue, nil
+}
+
+// reportEnumValue converts a value as displayed in a report, such as
+// "In Progress", into the API's enum value, such as IN_PROGRESS.
+func reportEnumValue(v string) string {
+	return strings.ReplaceAll(strings.ToUpper(v), " ", "_")
+}
+
+// IsPrincipalIssue reports whether an issue is about a principal entity
+// (USER_ACCOUNT, SERVICE_ACCOUNT), the issues the connector syncs.
+func IsPrincipalIssue(issue Issue) bool {
+	return slices.Contains(principalEntityTypes, issue.EntitySnapshot.Type)
+}
@@ -0,0 +1,65 @@
+package wiz
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/conductorone/baton-sdk/pkg/uhttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportIssuesReportRerunsReportFoundByName(t *testing.T) {
+	ctx := context.Background()
+
+	var operations []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/download", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "Issue ID\na\n")
+	})
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Query string `json:"query"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		operation, _, _ := strings.Cut(strings.Fields(body.Query)[1], "(")
+		operations = append(operations, operation)
+
+		w.Header().Set("Content-Type", "application/json")
+		switch operation {
+		case "Reports":
+			// The search also matches a report whose name only contains the name.
+			fmt.Fprintf(w, `{"data": {"reports": {"nodes": [{"id": "r0", "name": %q}, {"id": "r1", "name": %q}]}}}`,
+				issuesReportName+" (copy)", issuesReportName)
+		case "RerunReport":
+			fmt.Fprint(w, `{"data": {"rerunReport": {"report": {"id": "r1"}}}}`)
+		case "ReportRun":
+			fmt.Fprintf(w, `{"data": {"report": {"id": "r1", "lastRun": {"status": "COMPLETED", "url": %q}}}}`, "http://"+r.Host+"/download")
+		default:
+			t.Errorf("unexpected operation %s", operation)
+		}
+	})
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	wrapper, err := uhttp.NewBaseHttpClientWithContext(ctx, ts.Client())
+	require.NoError(t, err)
+	downloader, err := uhttp.NewBaseHttpClientWithContext(ctx, ts.Client())
+	require.NoError(t, err)
+	c := &client{wrapper: wrapper, apiURL: ts.URL + "/graphql", downloader: downloader}
+
+	var report strings.Builder
+	reportID, err := c.ExportIssuesReport(ctx, "", false, time.Minute, &report)
+	require.NoError(t, err)
+
+	// No report is created, the existing one is rerun.
+	assert.Equal(t, "r1", reportID)
+	assert.Equal(t, []string{"Reports", "RerunReport", "ReportRun"}, operations)
+	assert.Equal(t, "Issue ID\na\n", report.String())
+}
@@ -0,0 +1,97 @@
+package wiz
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Secret instance statuses.
+const (
+	SecretStatusOpen     = "OPEN"
+	SecretStatusResolved = "RESOLVED"
+)
+
+// SecretValidationStatusValid indicates Wiz verified the secret still authenticates.
+const SecretValidationStatusValid = "VALID"
+
+const secretInstancesQuery = `query SecretInstances($after: String, $first: Int, $filterBy: SecretInstanceFilters) {
+  secretInstances(after: $after, first: $first, filterBy: $filterBy) {
+    nodes {
+      id
+      name
+      type
+      severity
+      status
+      validationStatus
+      path
+      firstSeenAt
+      lastSeenAt
+      updatedAt
+      resource {
+        id
+        name
+        type
+        properties
+      }
+      relatedIdentity {
+        id
+        name
+        type
+        properties
+      }
+    }
+    pageInfo {
+      hasNextPage
+      endCursor
+    }
+  }
+}`
+
+func (c *client) listSecretInstances(ctx context.Context, filter map[string]interface{}, cursor *string) (*SecretInstanceConnection, error) {
+	variables := map[string]interface{}{
+		"first":    100,
+		"filterBy": filter,
+	}
+	if cursor != nil && *cursor != "" {
+		variables["after"] = *cursor
+	}
+
+	var result secretInstancesQueryResponse
+	if err := c.graphQLRequest(ctx, secretInstancesQuery, variables, &result); err != nil {
+		return nil, err
+	}
+
+	return &result.SecretInstances, nil
+}
+
+// ListSecretInstances retrieves a paginated list of open secret findings.
+func (c *client) ListSecretInstances(ctx context.Context, cursor *string) (*SecretInstanceConnection, error) {
+	filter := map[string]interface{}{
+		"status": []string{SecretStatusOpen},
+	}
+
+	result, err := c.listSecretInstances(ctx, filter, cursor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secret instances: %w", err)
+	}
+
+	return result, nil
+}
+
+// ListSecretInstancesSince retrieves a paginated list of secret findings of any
+// status updated after since. Used by the event feed for incremental sync.
+func (c *client) ListSecretInstancesSince(ctx context.Context, since time.Time, cursor *string) (*SecretInstanceConnection, error) {
+	filter := map[string]interface{}{
+		"updatedAt": map[string]interface{}{
+			"after": since.Format(time.RFC3339),
+		},
+	}
+
+	result, err := c.listSecretInstances(ctx, filter, cursor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secret instances since %s: %w", since.Format(time.RFC3339), err)
+	}
+
+	return result, nil
+}
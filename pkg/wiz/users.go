@@ -0,0 +1,51 @@
+package wiz
+
+import "context"
+
+const usersQuery = `query Users($after: String, $first: Int) {
+  users(after: $after, first: $first) {
+    nodes {
+      id
+      name
+      email
+    }
+    pageInfo {
+      hasNextPage
+      endCursor
+    }
+  }
+}`
+
+const serviceAccountsQuery = `query ServiceAccounts($after: String, $first: Int) {
+  serviceAccounts(after: $after, first: $first) {
+    nodes {
+      id
+      name
+    }
+    pageInfo {
+      hasNextPage
+      endCursor
+    }
+  }
+}`
+
+// ListUsers retrieves a paginated list of the users of the Wiz console.
+func (c *client) ListUsers(ctx context.Context, cursor *string) (*UserConnection, error) {
+	query := pageQuery{
+		Name:  "list users",
+		Field: "users",
+		Text:  usersQuery,
+	}
+	return newPaginator[AuditLogActor](c, query, cursor).Next(ctx)
+}
+
+// ListServiceAccounts retrieves a paginated list of the Wiz service accounts
+// that call the Wiz API.
+func (c *client) ListServiceAccounts(ctx context.Context, cursor *string) (*UserConnection, error) {
+	query := pageQuery{
+		Name:  "list service accounts",
+		Field: "serviceAccounts",
+		Text:  serviceAccountsQuery,
+	}
+	return newPaginator[AuditLogActor](c, query, cursor).Next(ctx)
+}